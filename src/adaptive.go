@@ -0,0 +1,118 @@
+package main
+
+import (
+	"context"
+	"sync"
+)
+
+const (
+	// Above this share of 429 responses the concurrency is halved, below it's raised by one
+	throttledRateHigh = 0.1
+	throttledRateLow  = 0.01
+)
+
+// Adapts the concurrency of the OSRM route calls, of /routes and of jobs alike, to the observed 429 rate when set
+var adaptiveConcurrency *adaptiveLimiter
+
+// adaptiveLimiter bounds the number of calls in flight, adjusting the bound between min and max
+// each time a full window of OSRM responses has been observed
+type adaptiveLimiter struct {
+	mu       sync.Mutex
+	cond     *sync.Cond
+	min      int
+	max      int
+	limit    int
+	inFlight int
+
+	window    int
+	observed  int
+	throttled int
+}
+
+func newAdaptiveLimiter(minLimit int, maxLimit int, window int) *adaptiveLimiter {
+	l := &adaptiveLimiter{
+		min:    minLimit,
+		max:    maxLimit,
+		limit:  maxLimit,
+		window: window,
+	}
+	l.cond = sync.NewCond(&l.mu)
+
+	return l
+}
+
+// acquire blocks until a call can be made within the current limit, or returns the context's error
+// as soon as ctx is done
+func (l *adaptiveLimiter) acquire(ctx context.Context) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.inFlight >= l.limit {
+		// A waiter only wakes up on a broadcast, so one is sent when ctx is done
+		waiting := make(chan struct{})
+		defer close(waiting)
+		go func() {
+			select {
+			case <-ctx.Done():
+				l.mu.Lock()
+				l.cond.Broadcast()
+				l.mu.Unlock()
+			case <-waiting:
+			}
+		}()
+	}
+
+	for l.inFlight >= l.limit {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		l.cond.Wait()
+	}
+	l.inFlight++
+
+	return nil
+}
+
+func (l *adaptiveLimiter) release() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.inFlight--
+	l.cond.Broadcast()
+}
+
+// observe records whether an OSRM response was a 429
+func (l *adaptiveLimiter) observe(throttled bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.observed++
+	if throttled {
+		l.throttled++
+	}
+
+	if l.observed < l.window {
+		return
+	}
+
+	rate := float64(l.throttled) / float64(l.observed)
+	switch {
+	case rate > throttledRateHigh:
+		l.limit /= 2
+		if l.limit < l.min {
+			l.limit = l.min
+		}
+	case rate < throttledRateLow && l.limit < l.max:
+		l.limit++
+	}
+
+	l.observed, l.throttled = 0, 0
+	l.cond.Broadcast()
+}
+
+func (l *adaptiveLimiter) currentLimit() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return l.limit
+}
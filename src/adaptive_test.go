@@ -0,0 +1,110 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func observeMany(l *adaptiveLimiter, n int, throttledEvery int) {
+	for i := 1; i <= n; i++ {
+		l.observe(throttledEvery > 0 && i%throttledEvery == 0)
+	}
+}
+
+func TestAdaptiveLimiterLowersConcurrencyOnHigh429Rate(t *testing.T) {
+	l := newAdaptiveLimiter(2, 16, 10)
+
+	// Every other response is a 429
+	observeMany(l, 10, 2)
+	assert.Equal(t, 8, l.currentLimit())
+	observeMany(l, 10, 2)
+	assert.Equal(t, 4, l.currentLimit())
+	observeMany(l, 20, 2)
+	assert.Equal(t, 2, l.currentLimit())
+}
+
+func TestAdaptiveLimiterRaisesConcurrencyOnLow429Rate(t *testing.T) {
+	l := newAdaptiveLimiter(1, 3, 10)
+	observeMany(l, 20, 1)
+	assert.Equal(t, 1, l.currentLimit())
+
+	observeMany(l, 10, 0)
+	assert.Equal(t, 2, l.currentLimit())
+	observeMany(l, 30, 0)
+	assert.Equal(t, 3, l.currentLimit())
+
+	// A moderate rate keeps the current limit
+	l = newAdaptiveLimiter(1, 8, 20)
+	observeMany(l, 20, 20)
+	assert.Equal(t, 8, l.currentLimit())
+}
+
+func TestAdaptiveLimiterBlocksOverLimit(t *testing.T) {
+	l := newAdaptiveLimiter(1, 1, 10)
+	assert.Nil(t, l.acquire(context.Background()))
+
+	var acquired int32
+	go func() {
+		assert.Nil(t, l.acquire(context.Background()))
+		atomic.StoreInt32(&acquired, 1)
+	}()
+
+	time.Sleep(20 * time.Millisecond)
+	assert.Equal(t, int32(0), atomic.LoadInt32(&acquired))
+
+	l.release()
+	assert.Eventually(t, func() bool { return atomic.LoadInt32(&acquired) == 1 }, time.Second, time.Millisecond)
+}
+
+func TestAdaptiveLimiterGivesUpWhenContextIsDone(t *testing.T) {
+	l := newAdaptiveLimiter(1, 1, 10)
+	assert.Nil(t, l.acquire(context.Background()))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	errs := make(chan error)
+	go func() { errs <- l.acquire(ctx) }()
+
+	time.Sleep(20 * time.Millisecond)
+	cancel()
+	assert.ErrorIs(t, <-errs, context.Canceled)
+}
+
+func TestGetRoutesIsBoundedByAdaptiveConcurrency(t *testing.T) {
+	var inFlight, maxInFlight atomic.Int64
+	mockOsrmApi := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := inFlight.Add(1)
+		defer inFlight.Add(-1)
+		for {
+			highest := maxInFlight.Load()
+			if n <= highest || maxInFlight.CompareAndSwap(highest, n) {
+				break
+			}
+		}
+		time.Sleep(20 * time.Millisecond)
+
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"code":"Ok", "routes": [{"duration":260.1,"distance":1886.3}]}`))
+	}))
+	defer mockOsrmApi.Close()
+
+	defer func() { adaptiveConcurrency = nil }()
+	adaptiveConcurrency = newAdaptiveLimiter(1, 2, 100)
+	router = setupRouter(Config{OsrmBaseURL: mockOsrmApi.URL, MaxConcurrency: 16})
+
+	url := "/routes?src=13.388860,52.517037"
+	for i := 0; i < 8; i++ {
+		url += fmt.Sprintf("&dst=13.%d,52.529407", 100+i)
+	}
+	rec := mockGetRoutesRequest(url)
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.NotContains(t, rec.Body.String(), "failures")
+	assert.Equal(t, int64(2), maxInFlight.Load())
+}
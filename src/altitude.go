@@ -0,0 +1,52 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Policies for coordinates given with an altitude, such as 13.388860,52.517037,34.5
+const (
+	altitudeReject = "reject"
+	altitudeStrip  = "strip"
+	altitudeKeep   = "keep"
+)
+
+// splitAltitude splits a coordinate with a third value into the coordinate and its altitude. ok is
+// false for any coordinate without exactly three values, which is left to validation.
+func splitAltitude(s string) (coordinate string, altitude float64, ok bool, err error) {
+	parts := strings.Split(s, ",")
+	if len(parts) != 3 {
+		return s, 0, false, nil
+	}
+
+	altitude, err = strconv.ParseFloat(strings.TrimSpace(parts[2]), 64)
+	if err != nil || math.IsNaN(altitude) || math.IsInf(altitude, 0) {
+		return s, 0, false, fmt.Errorf("altitude of %s is not a number", s)
+	}
+
+	return parts[0] + "," + parts[1], altitude, true, nil
+}
+
+// stripAltitudes removes the altitude from every 3D coordinate of the slice in place, recording it
+// in altitudes by the coordinate it was removed from unless altitudes is nil
+func stripAltitudes(coordinates []string, altitudes map[string]float64) error {
+	for i, s := range coordinates {
+		coordinate, altitude, ok, err := splitAltitude(s)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			continue
+		}
+
+		coordinates[i] = coordinate
+		if altitudes != nil {
+			altitudes[coordinate] = altitude
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSplitAltitude(t *testing.T) {
+	coordinate, altitude, ok, err := splitAltitude("13.388860,52.517037,34.5")
+	assert.Nil(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, "13.388860,52.517037", coordinate)
+	assert.Equal(t, 34.5, altitude)
+
+	coordinate, altitude, ok, err = splitAltitude("13.388860,52.517037,-2")
+	assert.Nil(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, "13.388860,52.517037", coordinate)
+	assert.Equal(t, -2.0, altitude)
+
+	coordinate, _, ok, err = splitAltitude("13.388860,52.517037")
+	assert.Nil(t, err)
+	assert.False(t, ok)
+	assert.Equal(t, "13.388860,52.517037", coordinate)
+
+	for _, s := range []string{"13.388860,52.517037,high", "13.388860,52.517037,", "13.388860,52.517037,NaN", "13.388860,52.517037,Inf"} {
+		_, _, _, err = splitAltitude(s)
+		assert.EqualError(t, err, "altitude of "+s+" is not a number")
+	}
+}
+
+func TestStripAltitudes(t *testing.T) {
+	coordinates := []string{"13.388860,52.517037,34.5", "13.397634,52.529407", "13.1,52.2,3.4,52.5"}
+	altitudes := make(map[string]float64)
+
+	assert.Nil(t, stripAltitudes(coordinates, altitudes))
+	assert.Equal(t, []string{"13.388860,52.517037", "13.397634,52.529407", "13.1,52.2,3.4,52.5"}, coordinates)
+	assert.Equal(t, map[string]float64{"13.388860,52.517037": 34.5}, altitudes)
+
+	assert.NotNil(t, stripAltitudes([]string{"13.388860,52.517037,x"}, nil))
+}
+
+func TestGetRoutesHandlesAltitudes(t *testing.T) {
+	var path string
+	mockOsrmApi := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path = r.URL.Path
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"code":"Ok", "routes": [{"duration":260.1,"distance":1886.3}]}`))
+	}))
+	defer mockOsrmApi.Close()
+
+	router = setupRouter(Config{OsrmBaseURL: mockOsrmApi.URL})
+	url := "/routes?src=13.388860,52.517037,34.5&dst=13.397634,52.529407,-2"
+
+	rec := mockGetRoutesRequest(url)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+	router = setupRouter(Config{OsrmBaseURL: mockOsrmApi.URL, CoordinateAltitudes: altitudeStrip})
+	rec = mockGetRoutesRequest(url)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "/route/v1/driving/13.388860,52.517037;13.397634,52.529407", path)
+
+	var resp GetRoutesResp
+	assert.Nil(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	assert.Equal(t, "13.388860,52.517037", resp.Source)
+	assert.Equal(t, "13.397634,52.529407", resp.Routes[0].Destination)
+	assert.Nil(t, resp.Metadata.Altitudes)
+
+	router = setupRouter(Config{OsrmBaseURL: mockOsrmApi.URL, CoordinateAltitudes: altitudeKeep})
+	rec = mockGetRoutesRequest(url)
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	resp = GetRoutesResp{}
+	assert.Nil(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	assert.Equal(t, "13.397634,52.529407", resp.Routes[0].Destination)
+	assert.Equal(t, map[string]float64{"13.388860,52.517037": 34.5, "13.397634,52.529407": -2}, resp.Metadata.Altitudes)
+}
+
+func TestGetRoutesRejectsNonNumericAltitude(t *testing.T) {
+	router = setupRouter(Config{OsrmBaseURL: "http://localhost", CoordinateAltitudes: altitudeStrip})
+
+	rec := mockGetRoutesRequest("/routes?src=13.388860,52.517037&dst=13.397634,52.529407,high")
+
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.JSONEq(t, `{"code":400,"message":"Dst is not valid: altitude of 13.397634,52.529407,high is not a number","error_code":"invalid_coordinate"}`, rec.Body.String())
+}
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"crypto/subtle"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+var (
+	// Requests must carry this key in apiKeyHeader when set
+	apiKey       = ""
+	apiKeyHeader = "X-API-Key"
+)
+
+// Context key of the API key a request was authenticated with, only set once it has been validated
+const apiKeyContextKey = "apiKey"
+
+func requireAPIKey(key string, header string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if subtle.ConstantTimeCompare([]byte(c.GetHeader(header)), []byte(key)) != 1 {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, ErrResp{
+				Code:      http.StatusUnauthorized,
+				Message:   "Missing or invalid " + header + " header",
+				ErrorCode: errCodeUnauthorized,
+			})
+			return
+		}
+
+		c.Set(apiKeyContextKey, key)
+		c.Next()
+	}
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetRoutesRequiresAPIKeyWhenConfigured(t *testing.T) {
+	mockOsrmApi := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"code":"Ok", "routes": [{"duration":2490.1,"distance":3286.3}]}`))
+	}))
+	defer mockOsrmApi.Close()
+
+	apiKey = "secret"
+	defer func() { apiKey = "" }()
+	r := setupRouter(Config{OsrmBaseURL: mockOsrmApi.URL})
+
+	tests := map[string]int{
+		"":       http.StatusUnauthorized,
+		"wrong":  http.StatusUnauthorized,
+		"secret": http.StatusOK,
+	}
+
+	for key, expectedCode := range tests {
+		rec := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodGet, "/routes?src=13.388860,52.517037&dst=13.397634,52.529407", nil)
+		if key != "" {
+			req.Header.Set("X-API-Key", key)
+		}
+		r.ServeHTTP(rec, req)
+
+		assert.Equal(t, expectedCode, rec.Code, key)
+		if expectedCode == http.StatusUnauthorized {
+			assert.Equal(t, `{"code":401,"message":"Missing or invalid X-API-Key header","error_code":"unauthorized"}`, rec.Body.String())
+		}
+	}
+}
+
+func TestGetRoutesDoesNotRequireAPIKeyByDefault(t *testing.T) {
+	rec := mockGetRoutesRequest("/routes")
+
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sort"
+)
+
+// Header the backend hash of GET and POST /routes is sent in, unset leaves it out
+var backendHashHeader = ""
+
+// osrmRouteURLs returns the OSRM calls routing src to every destination with each of the profiles
+func osrmRouteURLs(cfg Config, src string, dsts []string, opts RouteOptions, profiles []string) []string {
+	urls := make([]string, 0, len(dsts)*len(profiles))
+	for _, profile := range profiles {
+		opts.Profile = profile
+		for _, dst := range dsts {
+			url, _ := osrmRouteURL(cfg, src, dst, opts)
+			urls = append(urls, url)
+		}
+	}
+
+	return urls
+}
+
+// backendHash hashes the set of OSRM calls a request is answered with. Requests that differ only in
+// what is done with the routes, such as their sort order or output format, make the same calls and
+// share the hash, so an edge cache can key on it rather than on the client request.
+func backendHash(urls []string) string {
+	sorted := append([]string(nil), urls...)
+	sort.Strings(sorted)
+
+	h := sha256.New()
+	for i, url := range sorted {
+		if i > 0 && url == sorted[i-1] {
+			continue
+		}
+		fmt.Fprintf(h, "%s\n", url)
+	}
+
+	return hex.EncodeToString(h.Sum(nil)[:16])
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBackendHashIgnoresOrderAndRepetitions(t *testing.T) {
+	a := "http://osrm/route/v1/driving/13.388860,52.517037;13.397634,52.529407?overview=false"
+	b := "http://osrm/route/v1/driving/13.388860,52.517037;13.428555,52.523219?overview=false"
+
+	assert.Equal(t, backendHash([]string{a, b}), backendHash([]string{b, a}))
+	assert.Equal(t, backendHash([]string{a, b}), backendHash([]string{a, b, a}))
+	assert.NotEqual(t, backendHash([]string{a, b}), backendHash([]string{a}))
+	assert.Len(t, backendHash([]string{a}), 32)
+}
+
+func TestGetRoutesSendsBackendHash(t *testing.T) {
+	mockOsrmApi := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"code":"Ok", "routes": [{"duration":260.1,"distance":1886.3}]}`))
+	}))
+	defer mockOsrmApi.Close()
+
+	defer func() { backendHashHeader = "" }()
+	router = setupRouter(Config{OsrmBaseURL: mockOsrmApi.URL})
+
+	rec := mockGetRoutesRequest("/routes?src=13.388860,52.517037&dst=13.397634,52.529407")
+	assert.Empty(t, rec.Header().Get("X-Backend-Hash"))
+
+	backendHashHeader = "X-Backend-Hash"
+	hash := mockGetRoutesRequest("/routes?src=13.388860,52.517037&dst=13.397634,52.529407&dst=13.428555,52.523219").Header().Get("X-Backend-Hash")
+	assert.Len(t, hash, 32)
+
+	// Different client requests making the same OSRM calls
+	for _, url := range []string{
+		"/routes?src=13.388860,52.517037&dst=13.428555,52.523219&dst=13.397634,52.529407",
+		"/routes?src=13.388860,52.517037&dst=13.397634,52.529407&dst=13.428555,52.523219&dst=13.397634,52.529407",
+		"/routes?src=13.388860,52.517037&dst=13.397634,52.529407&dst=13.428555,52.523219&profile=driving",
+		"/routes?src=13.388860,52.517037&dst=13.397634,52.529407&dst=13.428555,52.523219&sort=distance&limit=1&format=kml",
+		"/routes?src=13.388860%2052.517037&dst=13.397634%3B52.529407&dst=13.428555,52.523219",
+	} {
+		assert.Equal(t, hash, mockGetRoutesRequest(url).Header().Get("X-Backend-Hash"), url)
+	}
+
+	// Different OSRM calls
+	for _, url := range []string{
+		"/routes?src=13.388860,52.517037&dst=13.397634,52.529407",
+		"/routes?src=13.388860,52.517037&dst=13.397634,52.529407&dst=13.428555,52.523219&profile=cycling",
+		"/routes?src=13.388860,52.517037&dst=13.397634,52.529407&dst=13.428555,52.523219&turns=true",
+	} {
+		assert.NotEqual(t, hash, mockGetRoutesRequest(url).Header().Get("X-Backend-Hash"), url)
+	}
+}
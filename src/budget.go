@@ -0,0 +1,105 @@
+package main
+
+import (
+	"container/list"
+	"context"
+	"sync"
+)
+
+// weightedSemaphore hands out up to size units, serving the waiters in the order they arrived so
+// a large acquisition isn't starved by smaller ones
+type weightedSemaphore struct {
+	mu      sync.Mutex
+	size    int64
+	cur     int64
+	waiters list.List
+}
+
+type semaphoreWaiter struct {
+	n     int64
+	ready chan struct{}
+}
+
+func newWeightedSemaphore(size int64) *weightedSemaphore {
+	return &weightedSemaphore{size: size}
+}
+
+// acquire blocks until n units are free or ctx is done, returning ctx.Err() in the latter case
+func (s *weightedSemaphore) acquire(ctx context.Context, n int64) error {
+	s.mu.Lock()
+	if s.size-s.cur >= n && s.waiters.Len() == 0 {
+		s.cur += n
+		s.mu.Unlock()
+		return nil
+	}
+
+	waiter := semaphoreWaiter{n: n, ready: make(chan struct{})}
+	elem := s.waiters.PushBack(waiter)
+	s.mu.Unlock()
+
+	select {
+	case <-waiter.ready:
+		return nil
+	case <-ctx.Done():
+		s.mu.Lock()
+		select {
+		case <-waiter.ready:
+			// Acquired just as ctx was done, the units go to the next waiters
+			s.cur -= n
+		default:
+			s.waiters.Remove(elem)
+		}
+		s.notifyWaiters()
+		s.mu.Unlock()
+		return ctx.Err()
+	}
+}
+
+func (s *weightedSemaphore) release(n int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.cur -= n
+	s.notifyWaiters()
+}
+
+// notifyWaiters wakes the waiters at the front of the queue for as long as their units are free
+func (s *weightedSemaphore) notifyWaiters() {
+	for {
+		next := s.waiters.Front()
+		if next == nil {
+			return
+		}
+
+		waiter := next.Value.(semaphoreWaiter)
+		if s.size-s.cur < waiter.n {
+			return
+		}
+
+		s.cur += waiter.n
+		s.waiters.Remove(next)
+		close(waiter.ready)
+	}
+}
+
+// inUse returns the units currently handed out
+func (s *weightedSemaphore) inUse() int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.cur
+}
+
+// acquireFetchBudget takes a unit of the fetch budget for a single OSRM fetch. The returned func
+// gives it back and is a no-op when there is no budget.
+func acquireFetchBudget(ctx context.Context, fetchBudget *weightedSemaphore) (release func(), err error) {
+	if fetchBudget == nil {
+		return func() {}, nil
+	}
+
+	if err := fetchBudget.acquire(ctx, 1); err != nil {
+		return nil, err
+	}
+
+	return func() { fetchBudget.release(1) }, nil
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWeightedSemaphoreServesWaitersInOrder(t *testing.T) {
+	s := newWeightedSemaphore(3)
+	ctx := context.Background()
+
+	assert.Nil(t, s.acquire(ctx, 2))
+	assert.Equal(t, int64(2), s.inUse())
+
+	// The large waiter comes first, the small one waits behind it although a unit is free
+	acquired := make(chan int64, 2)
+	go func() {
+		s.acquire(ctx, 3)
+		acquired <- 3
+	}()
+	time.Sleep(10 * time.Millisecond)
+	go func() {
+		s.acquire(ctx, 1)
+		acquired <- 1
+	}()
+	time.Sleep(10 * time.Millisecond)
+	assert.Empty(t, acquired)
+
+	s.release(2)
+	assert.Equal(t, int64(3), <-acquired)
+	s.release(3)
+	assert.Equal(t, int64(1), <-acquired)
+	s.release(1)
+	assert.Equal(t, int64(0), s.inUse())
+}
+
+func TestWeightedSemaphoreGivesUpWhenContextIsDone(t *testing.T) {
+	s := newWeightedSemaphore(1)
+	assert.Nil(t, s.acquire(context.Background(), 1))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	assert.Equal(t, context.DeadlineExceeded, s.acquire(ctx, 1))
+
+	s.release(1)
+	assert.Equal(t, int64(0), s.inUse())
+	assert.Nil(t, s.acquire(context.Background(), 1))
+}
+
+func TestFetchBudgetBoundsFetchesAcrossRequests(t *testing.T) {
+	var inFlight, maxInFlight atomic.Int64
+	mockOsrmApi := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := inFlight.Add(1)
+		defer inFlight.Add(-1)
+		for {
+			max := maxInFlight.Load()
+			if n <= max || maxInFlight.CompareAndSwap(max, n) {
+				break
+			}
+		}
+		time.Sleep(20 * time.Millisecond)
+
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"code":"Ok", "routes": [{"duration":260.1,"distance":1886.3}]}`))
+	}))
+	defer mockOsrmApi.Close()
+
+	fetchBudget := newWeightedSemaphore(3)
+	router = setupRouter(Config{OsrmBaseURL: mockOsrmApi.URL, MaxConcurrency: 4, FetchBudget: fetchBudget})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			rec := mockGetRoutesRequest(fmt.Sprintf("/routes?src=13.38886,52.51703%d&dst=13.1,52.1&dst=13.2,52.2&dst=13.3,52.3&dst=13.4,52.4", i))
+			assert.Equal(t, http.StatusOK, rec.Code)
+			assert.NotContains(t, rec.Body.String(), "failures")
+		}(i)
+	}
+	wg.Wait()
+
+	assert.Equal(t, int64(3), maxInFlight.Load())
+	assert.Equal(t, int64(0), fetchBudget.inUse())
+
+	reg := prometheus.NewRegistry()
+	registerFetchBudgetMetrics(reg, fetchBudget)
+	assert.Nil(t, testutil.GatherAndCompare(reg, strings.NewReader(`
+# HELP routes_osrm_fetch_budget_in_use OSRM fetches in flight across all requests.
+# TYPE routes_osrm_fetch_budget_in_use gauge
+routes_osrm_fetch_budget_in_use 0
+# HELP routes_osrm_fetch_budget_size Most OSRM fetches in flight across all requests, OSRM_GLOBAL_CONCURRENCY.
+# TYPE routes_osrm_fetch_budget_size gauge
+routes_osrm_fetch_budget_size 3
+`)))
+}
+
+func TestCollectBoundedTakesFetchBudgetBeforeStartingGoroutines(t *testing.T) {
+	fetchBudget := newWeightedSemaphore(1)
+	assert.Nil(t, fetchBudget.acquire(context.Background(), 1))
+	cfg := Config{MaxConcurrency: 50, FetchBudget: fetchBudget}
+
+	coordinates := make([]string, 50)
+	for i := range coordinates {
+		coordinates[i] = fmt.Sprintf("13.%d,52.529407", 100+i)
+	}
+
+	before := runtime.NumGoroutine()
+	done := make(chan []Route)
+	go func() {
+		routes, _ := collectBounded(context.Background(), cfg, coordinates, func(ctx context.Context, dst string) (Route, error) {
+			return Route{Destination: dst}, nil
+		})
+		done <- routes
+	}()
+
+	// With the budget taken nothing but the collector itself is running
+	time.Sleep(50 * time.Millisecond)
+	assert.LessOrEqual(t, runtime.NumGoroutine(), before+1)
+
+	fetchBudget.release(1)
+	assert.Len(t, <-done, 50)
+	assert.Equal(t, int64(0), fetchBudget.inUse())
+}
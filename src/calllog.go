@@ -0,0 +1,109 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// Levels of the structured log, entries below a logger's level are dropped
+const (
+	levelDebug = iota
+	levelInfo
+	levelWarn
+	levelError
+)
+
+var levelNames = []string{"debug", "info", "warn", "error"}
+
+// Records every OSRM call at debug and every failed one at warn, see LOG_LEVEL
+var osrmLog = &jsonLogger{out: os.Stdout, level: levelInfo}
+
+// jsonLogger writes an entry as a single line JSON object, so log shippers can index its fields
+type jsonLogger struct {
+	mu    sync.Mutex
+	out   io.Writer
+	level int
+}
+
+// parseLevel returns the level called name, ok is false for unknown names
+func parseLevel(name string) (level int, ok bool) {
+	for i, n := range levelNames {
+		if n == name {
+			return i, true
+		}
+	}
+
+	return 0, false
+}
+
+func (l *jsonLogger) log(level int, msg string, fields map[string]any) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if level < l.level {
+		return
+	}
+
+	entry := make(map[string]any, len(fields)+3)
+	for k, v := range fields {
+		entry[k] = v
+	}
+	entry["time"] = time.Now().UTC().Format(time.RFC3339Nano)
+	entry["level"] = levelNames[level]
+	entry["msg"] = msg
+
+	line, err := json.Marshal(entry)
+	if err != nil {
+		fmt.Fprintf(l.out, "{\"level\":\"error\",\"msg\":%q}\n", err.Error())
+		return
+	}
+	l.out.Write(append(line, '\n'))
+}
+
+// osrmCallStats collects what happened during a single OSRM call across its retries
+type osrmCallStats struct {
+	status  int
+	retries int
+
+	// The route came from the route cache without calling OSRM
+	cached bool
+}
+
+type osrmCallStatsKey struct{}
+
+// withOsrmCallStats returns a context the OSRM requests made with it record their stats to
+func withOsrmCallStats(ctx context.Context, stats *osrmCallStats) context.Context {
+	return context.WithValue(ctx, osrmCallStatsKey{}, stats)
+}
+
+// osrmCallStatsFrom returns the stats ctx records to, nil when it doesn't record any
+func osrmCallStatsFrom(ctx context.Context) *osrmCallStats {
+	stats, _ := ctx.Value(osrmCallStatsKey{}).(*osrmCallStats)
+
+	return stats
+}
+
+// logOsrmCall records a finished OSRM call, at warn level when it didn't yield a route
+func logOsrmCall(src, dst string, stats *osrmCallStats, elapsed time.Duration, err error) {
+	fields := map[string]any{
+		"src":       src,
+		"dst":       dst,
+		"status":    stats.status,
+		"retries":   stats.retries,
+		"elapsedMs": elapsed.Milliseconds(),
+	}
+	if stats.cached {
+		fields["cached"] = true
+	}
+	if err != nil {
+		fields["error"] = err.Error()
+		osrmLog.log(levelWarn, "OSRM call failed", fields)
+		return
+	}
+	osrmLog.log(levelDebug, "OSRM call", fields)
+}
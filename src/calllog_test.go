@@ -0,0 +1,89 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseLevel(t *testing.T) {
+	level, ok := parseLevel("debug")
+	assert.True(t, ok)
+	assert.Equal(t, levelDebug, level)
+
+	level, ok = parseLevel("warn")
+	assert.True(t, ok)
+	assert.Equal(t, levelWarn, level)
+
+	_, ok = parseLevel("verbose")
+	assert.False(t, ok)
+}
+
+func TestJSONLoggerDropsEntriesBelowItsLevel(t *testing.T) {
+	var out bytes.Buffer
+	logger := &jsonLogger{out: &out, level: levelInfo}
+
+	logger.log(levelDebug, "dropped", nil)
+	logger.log(levelWarn, "kept", map[string]any{"dst": "13.397634,52.529407"})
+
+	var entry map[string]any
+	assert.Nil(t, json.Unmarshal(out.Bytes(), &entry))
+	assert.Equal(t, "warn", entry["level"])
+	assert.Equal(t, "kept", entry["msg"])
+	assert.Equal(t, "13.397634,52.529407", entry["dst"])
+	assert.NotEmpty(t, entry["time"])
+}
+
+func TestGetRouteDataLogsEveryOsrmCall(t *testing.T) {
+	calls := 0
+	mockOsrmApi := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		switch {
+		case strings.HasSuffix(r.URL.Path, "1.0,1.0"):
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"code":"NoRoute", "message": "Impossible route between points"}`))
+		case calls == 1:
+			w.WriteHeader(http.StatusTooManyRequests)
+		default:
+			w.WriteHeader(http.StatusOK)
+			w.Write([]byte(`{"code":"Ok", "routes": [{"duration":2490.1,"distance":3286.3}]}`))
+		}
+	}))
+	defer mockOsrmApi.Close()
+
+	var out bytes.Buffer
+	original := osrmLog
+	osrmLog = &jsonLogger{out: &out, level: levelDebug}
+	defer func() { osrmLog = original }()
+
+	cfg := Config{OsrmBaseURL: mockOsrmApi.URL, RetryBackoff: time.Millisecond}
+	getRouteData(context.Background(), cfg, "13.388860,52.517037", "13.397634,52.529407", RouteOptions{})
+	getRouteData(context.Background(), cfg, "13.388860,52.517037", "1.0,1.0", RouteOptions{})
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	assert.Len(t, lines, 2)
+
+	var entry map[string]any
+	assert.Nil(t, json.Unmarshal([]byte(lines[0]), &entry))
+	assert.Equal(t, "debug", entry["level"])
+	assert.Equal(t, "13.388860,52.517037", entry["src"])
+	assert.Equal(t, "13.397634,52.529407", entry["dst"])
+	assert.Equal(t, float64(http.StatusOK), entry["status"])
+	assert.Equal(t, float64(1), entry["retries"])
+	assert.Contains(t, entry, "elapsedMs")
+
+	entry = nil
+	assert.Nil(t, json.Unmarshal([]byte(lines[1]), &entry))
+	assert.Equal(t, "warn", entry["level"])
+	assert.Equal(t, "1.0,1.0", entry["dst"])
+	assert.Equal(t, float64(http.StatusBadRequest), entry["status"])
+	assert.Equal(t, float64(0), entry["retries"])
+	assert.Equal(t, "response code: 400. message: Impossible route between points", entry["error"])
+}
@@ -0,0 +1,63 @@
+package main
+
+// clusterDestinations greedily groups destinations lying within radius meters of a cluster's
+// first destination, which then represents the whole cluster. The representatives are returned
+// in input order together with the members of each cluster.
+func clusterDestinations(dsts []string, radius float64) ([]string, map[string][]string) {
+	var (
+		reps      []string
+		repCoords []Coordinate
+		members   = make(map[string][]string)
+	)
+
+	for _, dst := range dsts {
+		coord, err := parseCoordinate(dst)
+		if err != nil {
+			continue
+		}
+
+		joined := false
+		for i, repCoord := range repCoords {
+			if haversineDistance(coord, repCoord) <= radius {
+				members[reps[i]] = append(members[reps[i]], dst)
+				joined = true
+				break
+			}
+		}
+
+		if !joined {
+			reps = append(reps, dst)
+			repCoords = append(repCoords, coord)
+			members[dst] = []string{dst}
+		}
+	}
+
+	return reps, members
+}
+
+// expandClusters copies the route of each representative to every member of its cluster
+func expandClusters(routes []Route, clusters map[string][]string) []Route {
+	expanded := make([]Route, 0, len(routes))
+	for _, route := range routes {
+		for _, member := range clusters[route.Destination] {
+			r := route
+			if member != route.Destination {
+				r.Destination = member
+				r.ClusteredTo = route.Destination
+			}
+			expanded = append(expanded, r)
+		}
+	}
+
+	return expanded
+}
+
+// expandSkipped lists every member of the skipped clusters
+func expandSkipped(skipped []string, clusters map[string][]string) []string {
+	var expanded []string
+	for _, rep := range skipped {
+		expanded = append(expanded, clusters[rep]...)
+	}
+
+	return expanded
+}
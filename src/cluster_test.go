@@ -0,0 +1,61 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClusterDestinations(t *testing.T) {
+	// The first two are ~11m apart, the third ~1.3km away
+	dsts := []string{"13.397634,52.529407", "13.397634,52.529507", "13.397634,52.541407"}
+
+	reps, members := clusterDestinations(dsts, 50)
+
+	assert.Equal(t, []string{"13.397634,52.529407", "13.397634,52.541407"}, reps)
+	assert.Equal(t, []string{"13.397634,52.529407", "13.397634,52.529507"}, members["13.397634,52.529407"])
+	assert.Equal(t, []string{"13.397634,52.541407"}, members["13.397634,52.541407"])
+}
+
+func TestGetRoutesRoutesClustersOnce(t *testing.T) {
+	var calls int32
+	mockOsrmApi := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"code":"Ok", "routes": [{"duration":2490.1,"distance":3286.3}]}`))
+	}))
+	defer mockOsrmApi.Close()
+
+	router = setupRouter(Config{OsrmBaseURL: mockOsrmApi.URL})
+	src := "13.388860,52.517037"
+	rec := mockGetRoutesRequest(fmt.Sprintf("/routes?src=%s&dst=13.397634,52.529407&dst=13.397734,52.529407&dst=13.397634,52.529507&cluster=50", src))
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&calls))
+
+	// Routes as fast and as long as each other are sorted by destination
+	expectedResp := `{"source":"13.388860,52.517037","routes":[` +
+		`{"destination":"13.397634,52.529407","duration":2490.1,"distance":3286.3},` +
+		`{"destination":"13.397634,52.529507","duration":2490.1,"distance":3286.3,"clusteredTo":"13.397634,52.529407"},` +
+		`{"destination":"13.397734,52.529407","duration":2490.1,"distance":3286.3,"clusteredTo":"13.397634,52.529407"}` +
+		`],"metadata":{"detourRatio":2.19}}`
+	assert.Equal(t, expectedResp, rec.Body.String())
+}
+
+func TestGetRoutesSkipsWholeClustersOverMaxCalls(t *testing.T) {
+	mockOsrmApi := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"code":"Ok", "routes": [{"duration":2490.1,"distance":3286.3}]}`))
+	}))
+	defer mockOsrmApi.Close()
+
+	router = setupRouter(Config{OsrmBaseURL: mockOsrmApi.URL})
+	rec := mockGetRoutesRequest("/routes?src=13.388860,52.517037&dst=13.397634,52.529407&dst=13.428555,48.523219&dst=13.428655,48.523219&cluster=50&maxCalls=1")
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"skipped":["13.428555,48.523219","13.428655,48.523219"]`)
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"context"
+	"sync"
+)
+
+// Strategies to collect the routes of a request, selectable with debug=true to compare them
+const (
+	strategyWaitGroup = "waitgroup"
+	strategyChannel   = "channel"
+)
+
+// collectWithWaitGroup routes every destination in its own goroutine, appending to shared slices under a mutex
+func collectWithWaitGroup(ctx context.Context, cfg Config, src string, dsts []string, opts RouteOptions) ([]Route, []Failure) {
+	return collectBounded(ctx, cfg, dsts, func(ctx context.Context, dst string) (Route, error) {
+		return getRouteData(ctx, cfg, src, dst, opts)
+	})
+}
+
+// collectBounded calls route for every coordinate in its own goroutine with at most cfg.MaxConcurrency
+// in flight, reporting each error as the failure of its coordinate
+func collectBounded(ctx context.Context, cfg Config, coordinates []string, route func(context.Context, string) (Route, error)) ([]Route, []Failure) {
+	routes := make([]Route, 0)
+	var failures []Failure
+
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		inFlight = newInFlightLimit(cfg)
+		progress = routeProgressFrom(ctx)
+	)
+	for _, coordinate := range coordinates {
+		inFlight <- struct{}{}
+
+		// The fetch budget is taken before the goroutine is started, so requests waiting for it don't
+		// pile up goroutines either
+		release, err := acquireFetchBudget(ctx, cfg.FetchBudget)
+		if err != nil {
+			<-inFlight
+			mu.Lock()
+			failures = append(failures, newFailure(coordinate, err))
+			mu.Unlock()
+			progress.done()
+			continue
+		}
+
+		wg.Add(1)
+		go func(coordinate string) {
+			defer wg.Done()
+			defer func() { <-inFlight }()
+			defer release()
+			r, err := route(ctx, coordinate)
+
+			// Individual failures don't block the output, they are reported next to the routes
+			mu.Lock()
+			if err != nil {
+				failures = append(failures, newFailure(coordinate, err))
+			} else {
+				routes = append(routes, r)
+			}
+			mu.Unlock()
+			progress.done()
+		}(coordinate)
+	}
+
+	wg.Wait()
+
+	return routes, failures
+}
+
+// newInFlightLimit returns a semaphore holding up to cfg.MaxConcurrency OSRM calls. A goroutine is
+// only started once it acquired a slot, so a request with thousands of destinations doesn't open
+// thousands of connections at once.
+func newInFlightLimit(cfg Config) chan struct{} {
+	return make(chan struct{}, cfg.withDefaults().MaxConcurrency)
+}
+
+type routeResult struct {
+	route Route
+	dst   string
+	err   error
+}
+
+// collectWithChannel routes every destination in its own goroutine, sending the results to a single collector
+func collectWithChannel(ctx context.Context, cfg Config, src string, dsts []string, opts RouteOptions) ([]Route, []Failure) {
+	results := make(chan routeResult, len(dsts))
+	inFlight := newInFlightLimit(cfg)
+	go func() {
+		for _, dst := range dsts {
+			inFlight <- struct{}{}
+			release, err := acquireFetchBudget(ctx, cfg.FetchBudget)
+			if err != nil {
+				<-inFlight
+				results <- routeResult{dst: dst, err: err}
+				continue
+			}
+
+			go func(d string) {
+				route, err := getRouteData(ctx, cfg, src, d, opts)
+				release()
+				<-inFlight
+				results <- routeResult{route: route, dst: d, err: err}
+			}(dst)
+		}
+	}()
+
+	routes := make([]Route, 0)
+	var failures []Failure
+	progress := routeProgressFrom(ctx)
+	for range dsts {
+		result := <-results
+		progress.done()
+		if result.err != nil {
+			failures = append(failures, newFailure(result.dst, result.err))
+		} else {
+			routes = append(routes, result.route)
+		}
+	}
+
+	return routes, failures
+}
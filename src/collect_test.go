@@ -0,0 +1,132 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func mockCollectOsrmApi() *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Every fifth destination can't be routed, the others get a duration derived from their longitude
+		var lng, lat float64
+		fmt.Sscanf(r.URL.Path[strings.LastIndex(r.URL.Path, ";")+1:], "%f,%f", &lng, &lat)
+		if int(lng*1000)%5 == 0 {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"code":"NoRoute", "message": "Impossible route between points"}`))
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(fmt.Sprintf(`{"code":"Ok", "routes": [{"duration":%.1f,"distance":%.1f}]}`, lng*100, lat*100)))
+	}))
+}
+
+func collectDestinations(n int) []string {
+	dsts := make([]string, 0, n)
+	for i := 0; i < n; i++ {
+		dsts = append(dsts, fmt.Sprintf("13.%03d,52.%03d", i, n-i))
+	}
+
+	return dsts
+}
+
+func TestCollectStrategiesReturnIdenticalSortedOutput(t *testing.T) {
+	mockOsrmApi := mockCollectOsrmApi()
+	defer mockOsrmApi.Close()
+
+	cfg := Config{OsrmBaseURL: mockOsrmApi.URL}
+	src := "13.388860,52.517037"
+	dsts := collectDestinations(50)
+
+	routes, failures := collectWithWaitGroup(context.Background(), cfg, src, dsts, RouteOptions{})
+	waitGroupResp := newGetRoutesResp(src, routes, failures, dsts)
+
+	routes, failures = collectWithChannel(context.Background(), cfg, src, dsts, RouteOptions{})
+	channelResp := newGetRoutesResp(src, routes, failures, dsts)
+
+	assert.Len(t, waitGroupResp.Routes, 40)
+	assert.Len(t, waitGroupResp.Failures, 10)
+	assert.Equal(t, waitGroupResp, channelResp)
+}
+
+func TestCollectStrategiesLimitCallsInFlight(t *testing.T) {
+	var (
+		mu       sync.Mutex
+		inFlight int
+		peak     int
+	)
+	mockOsrmApi := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		inFlight++
+		if inFlight > peak {
+			peak = inFlight
+		}
+		mu.Unlock()
+
+		// Held long enough for the calls to pile up when they aren't limited
+		time.Sleep(5 * time.Millisecond)
+
+		mu.Lock()
+		inFlight--
+		mu.Unlock()
+
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"code":"Ok", "routes": [{"duration":2490.1,"distance":3286.3}]}`))
+	}))
+	defer mockOsrmApi.Close()
+
+	cfg := Config{OsrmBaseURL: mockOsrmApi.URL, MaxConcurrency: 3}
+	dsts := collectDestinations(30)
+
+	for name, collect := range map[string]func(context.Context, Config, string, []string, RouteOptions) ([]Route, []Failure){
+		strategyWaitGroup: collectWithWaitGroup,
+		strategyChannel:   collectWithChannel,
+	} {
+		peak = 0
+		routes, failures := collect(context.Background(), cfg, "13.388860,52.517037", dsts, RouteOptions{})
+
+		assert.Len(t, routes, 30, name)
+		assert.Empty(t, failures, name)
+		assert.Equal(t, 3, peak, name)
+	}
+}
+
+func TestGetRoutesUsesChannelStrategyWhenDebugging(t *testing.T) {
+	mockOsrmApi := mockCollectOsrmApi()
+	defer mockOsrmApi.Close()
+
+	router = setupRouter(Config{OsrmBaseURL: mockOsrmApi.URL})
+	rec := mockGetRoutesRequest("/routes?src=13.388860,52.517037&dst=13.001,52.001&strategy=channel&debug=true")
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"routes":[{"destination":"13.001,52.001","duration":1300.1,"distance":5200.1`)
+	assert.Contains(t, rec.Body.String(), `"strategy":"channel"`)
+}
+
+func benchmarkCollect(b *testing.B, collect func(context.Context, Config, string, []string, RouteOptions) ([]Route, []Failure), n int) {
+	mockOsrmApi := mockCollectOsrmApi()
+	defer mockOsrmApi.Close()
+
+	cfg := Config{OsrmBaseURL: mockOsrmApi.URL}
+	dsts := collectDestinations(n)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		collect(context.Background(), cfg, "13.388860,52.517037", dsts, RouteOptions{})
+	}
+}
+
+func BenchmarkCollect(b *testing.B) {
+	for _, n := range []int{1, 10, 100} {
+		b.Run(fmt.Sprintf("waitgroup/%d", n), func(b *testing.B) { benchmarkCollect(b, collectWithWaitGroup, n) })
+		b.Run(fmt.Sprintf("channel/%d", n), func(b *testing.B) { benchmarkCollect(b, collectWithChannel, n) })
+	}
+}
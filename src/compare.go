@@ -0,0 +1,121 @@
+package main
+
+import (
+	"context"
+	"math"
+)
+
+// Comparison sets the routes of two profiles to the same destination side by side. The deltas are
+// the second profile's value minus the first's, so a positive DurationDelta means it's slower.
+type Comparison struct {
+	Destination   string     `json:"destination"`
+	Durations     [2]float64 `json:"durations"`
+	Distances     [2]float64 `json:"distances"`
+	DurationDelta float64    `json:"durationDelta"`
+	DistanceDelta float64    `json:"distanceDelta"`
+}
+
+// CompareResp answers compare=, destinations either profile failed to route are only listed in Failures
+type CompareResp struct {
+	Source      string            `json:"source"`
+	Profiles    []string          `json:"profiles"`
+	Comparisons []Comparison      `json:"comparisons"`
+	Warnings    []string          `json:"warnings,omitempty"`
+	Skipped     []string          `json:"skipped,omitempty"`
+	Failures    []Failure         `json:"failures,omitempty"`
+	Excluded    []Exclusion       `json:"excluded,omitempty"`
+	Geocoded    map[string]string `json:"geocoded,omitempty"`
+}
+
+// compareConflict names the first option of the query that only applies to a list of routes and
+// can't be combined with compare, "" when there is none
+func compareConflict(query QueryParams) string {
+	switch {
+	case query.Format != "" && query.Format != "json":
+		return "format"
+	case query.Units != "":
+		return "units"
+	case query.MaxDuration > 0:
+		return "max_duration"
+	case query.MaxDistance > 0:
+		return "max_distance"
+	case query.Limit > 0:
+		return "limit"
+	case query.Flat:
+		return "flat"
+	case query.GroupByGrid != nil:
+		return "groupByGrid"
+	case query.Keyed:
+		return "keyed"
+	}
+
+	return ""
+}
+
+// compareProfiles routes every destination with both profiles, one after the other so the calls in
+// flight stay within the configured limit, and compares them in the order of dsts
+func compareProfiles(ctx context.Context, cfg Config, collect func(context.Context, Config, string, []string, RouteOptions) ([]Route, []Failure),
+	src string, dsts []string, opts RouteOptions, profiles []string) CompareResp {
+	resp := CompareResp{Source: src, Profiles: profiles, Comparisons: make([]Comparison, 0)}
+
+	var byProfile [2]map[string]Route
+	for i, profile := range profiles {
+		opts.Profile = profile
+		routes, failures := collect(ctx, cfg, src, dsts, opts)
+
+		byProfile[i] = make(map[string]Route, len(routes))
+		for _, route := range routes {
+			byProfile[i][route.Destination] = route
+		}
+		for _, failure := range failures {
+			failure.Profile = profile
+			resp.Failures = append(resp.Failures, failure)
+		}
+	}
+	sortFailures(resp.Failures, dsts)
+
+	for _, dst := range dsts {
+		first, ok := byProfile[0][dst]
+		if !ok {
+			continue
+		}
+		second, ok := byProfile[1][dst]
+		if !ok {
+			continue
+		}
+
+		resp.Comparisons = append(resp.Comparisons, Comparison{
+			Destination:   dst,
+			Durations:     [2]float64{first.Duration, second.Duration},
+			Distances:     [2]float64{first.Distance, second.Distance},
+			DurationDelta: math.Round((second.Duration-first.Duration)*10) / 10,
+			DistanceDelta: math.Round((second.Distance-first.Distance)*10) / 10,
+		})
+	}
+
+	return resp
+}
+
+// applySpeedFactorToComparisons rescales the durations of both profiles like applySpeedFactor does for routes
+func applySpeedFactorToComparisons(comparisons []Comparison, factor float64) {
+	for i := range comparisons {
+		for j := range comparisons[i].Durations {
+			comparisons[i].Durations[j] = math.Round(comparisons[i].Durations[j]/factor*10) / 10
+		}
+		comparisons[i].DurationDelta = math.Round((comparisons[i].Durations[1]-comparisons[i].Durations[0])*10) / 10
+	}
+}
+
+// expandComparisons copies the comparison of each representative to every member of its cluster
+func expandComparisons(comparisons []Comparison, clusters map[string][]string) []Comparison {
+	expanded := make([]Comparison, 0, len(comparisons))
+	for _, comparison := range comparisons {
+		for _, member := range clusters[comparison.Destination] {
+			c := comparison
+			c.Destination = member
+			expanded = append(expanded, c)
+		}
+	}
+
+	return expanded
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetRoutesComparesProfiles(t *testing.T) {
+	mockOsrmApi := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case strings.HasPrefix(r.URL.Path, "/route/v1/cycling/") && strings.HasSuffix(r.URL.Path, "1.0,1.0"):
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"code":"NoRoute", "message": "Impossible route between points"}`))
+		case strings.HasPrefix(r.URL.Path, "/route/v1/cycling/"):
+			w.WriteHeader(http.StatusOK)
+			w.Write([]byte(`{"code":"Ok", "routes": [{"duration":1100.4,"distance":2986.1}]}`))
+		default:
+			w.WriteHeader(http.StatusOK)
+			w.Write([]byte(`{"code":"Ok", "routes": [{"duration":500.2,"distance":3286.3}]}`))
+		}
+	}))
+	defer mockOsrmApi.Close()
+
+	router = setupRouter(Config{OsrmBaseURL: mockOsrmApi.URL})
+	rec := mockGetRoutesRequest("/routes?src=13.388860,52.517037&dst=13.397634,52.529407&dst=1.0,1.0&compare=driving,cycling")
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, `{"source":"13.388860,52.517037","profiles":["driving","cycling"],"comparisons":[`+
+		`{"destination":"13.397634,52.529407","durations":[500.2,1100.4],"distances":[3286.3,2986.1],"durationDelta":600.2,"distanceDelta":-300.2}],`+
+		`"failures":[{"destination":"1.0,1.0","status":422,"category":"no_route","message":"response code: 400. message: Impossible route between points","profile":"cycling"}]}`,
+		rec.Body.String())
+}
+
+func TestGetRoutesReturns400WhenCompareIsInvalid(t *testing.T) {
+	router = setupRouter(Config{})
+
+	for _, compare := range []string{"driving", "driving,driving", "driving,flying", "driving,walking,cycling"} {
+		rec := mockGetRoutesRequest("/routes?src=13.388860,52.517037&dst=13.397634,52.529407&compare=" + compare)
+
+		assert.Equal(t, http.StatusBadRequest, rec.Code, compare)
+		assert.Contains(t, rec.Body.String(), `"message":"Compare must be two different profiles of driving, walking, cycling separated by a comma"`, compare)
+	}
+}
+
+func TestGetRoutesComparesProfilesWithResponseOptions(t *testing.T) {
+	mockOsrmApi := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		if strings.HasPrefix(r.URL.Path, "/route/v1/cycling/") {
+			w.Write([]byte(`{"code":"Ok", "routes": [{"duration":1100.4,"distance":2986.1}]}`))
+			return
+		}
+		w.Write([]byte(`{"code":"Ok", "routes": [{"duration":500.2,"distance":3286.3}]}`))
+	}))
+	defer mockOsrmApi.Close()
+
+	router = setupRouter(Config{OsrmBaseURL: mockOsrmApi.URL})
+	rec := mockGetRoutesRequest("/routes?src=13.388860,52.517037&dst=13.3976341,52.529407&compare=driving,cycling&speedFactor=2&naming=snake")
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, `{"source":"13.388860,52.517037","profiles":["driving","cycling"],"comparisons":[`+
+		`{"destination":"13.3976341,52.529407","durations":[250.1,550.2],"distances":[3286.3,2986.1],"duration_delta":300.1,"distance_delta":-300.2}],`+
+		`"warnings":["Dst 13.3976341,52.529407 has more than 6 decimal places, OSRM ignores the extra ones"]}`,
+		rec.Body.String())
+}
+
+func TestGetRoutesComparesProfilesHonoursOnEmpty(t *testing.T) {
+	mockOsrmApi := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"code":"NoRoute", "message": "Impossible route between points"}`))
+	}))
+	defer mockOsrmApi.Close()
+
+	router = setupRouter(Config{OsrmBaseURL: mockOsrmApi.URL})
+	url := "/routes?src=13.388860,52.517037&dst=13.397634,52.529407&compare=driving,cycling"
+
+	rec := mockGetRoutesRequest(url)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"warnings":["No routes found"]`)
+
+	rec = mockGetRoutesRequest(url + "&onEmpty=404")
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+	assert.Equal(t, `{"code":404,"message":"No routes found","error_code":"no_routes"}`, rec.Body.String())
+}
+
+func TestGetRoutesReturns400WhenCompareIsCombinedWithRouteOptions(t *testing.T) {
+	router = setupRouter(Config{})
+
+	for option, param := range map[string]string{
+		"format":       "format=csv",
+		"units":        "units=imperial",
+		"max_duration": "max_duration=600",
+		"max_distance": "max_distance=1000",
+		"limit":        "limit=1",
+		"flat":         "flat=true",
+		"groupByGrid":  "groupByGrid=2",
+		"keyed":        "keyed=true",
+	} {
+		rec := mockGetRoutesRequest("/routes?src=13.388860,52.517037&dst=13.397634,52.529407&compare=driving,cycling&" + param)
+
+		assert.Equal(t, http.StatusBadRequest, rec.Code, param)
+		assert.Equal(t, `{"code":400,"message":"Compare can't be combined with `+option+`","error_code":"invalid_parameter"}`, rec.Body.String())
+	}
+}
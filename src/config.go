@@ -0,0 +1,167 @@
+package main
+
+import (
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	defaultOsrmBaseURL = "http://router.project-osrm.org"
+
+	defaultRetryAttempts   = 20
+	defaultRetryBackoff    = 1 * time.Second
+	defaultRetryMaxBackoff = 30 * time.Second
+
+	defaultMaxConcurrency = 16
+
+	defaultHTTPTimeout = 10 * time.Second
+
+	defaultMaxDestinations = 100
+
+	// Path of the OSRM route service for a profile, source and destination
+	osrmRoutePath = "/route/v1/%s/%s;%s"
+
+	// Path of the OSRM table service for a profile and the coordinates separated by semicolons
+	osrmTablePath = "/table/v1/%s/%s"
+)
+
+// Config holds the settings a router is set up with, so several configurations can run in one process
+type Config struct {
+	// Base URL of the OSRM backend without a trailing slash
+	OsrmBaseURL string
+
+	// OSRM calls answered with 429 are retried up to RetryAttempts in total. The first retry waits
+	// RetryBackoff and every next one twice as long, up to RetryMaxBackoff.
+	RetryAttempts   int
+	RetryBackoff    time.Duration
+	RetryMaxBackoff time.Duration
+
+	// Durations in seconds below MinDuration are raised to it and flagged as floored
+	MinDuration float64
+
+	// At most MaxConcurrency OSRM calls of a request are in flight at the same time
+	MaxConcurrency int
+
+	// Every OSRM call is given up after HTTPTimeout
+	HTTPTimeout time.Duration
+
+	// Most destinations a single GET or POST /routes may have, so one request can't fan out to
+	// thousands of OSRM calls
+	MaxDestinations int
+
+	// What happens to the altitude of a 3D coordinate. OSRM only routes on longitude and latitude, so
+	// unless 3D coordinates are rejected the altitude is dropped before validation.
+	CoordinateAltitudes string
+
+	// Routes of recent OSRM calls, nil unless ROUTE_CACHE_SIZE is set
+	RouteCache *routeLRU
+
+	// Budget of OSRM fetches in flight across all requests of the process, on top of MaxConcurrency,
+	// so a burst of large requests can't exhaust the scheduler. nil when there is no budget.
+	FetchBudget *weightedSemaphore
+
+	// Client of the OSRM calls, set up by setupRouter so every router has its own HTTPTimeout
+	client *http.Client
+}
+
+// configFromEnv reads the configuration from the environment, falling back to the defaults
+func configFromEnv() Config {
+	cfg := Config{OsrmBaseURL: defaultOsrmBaseURL}
+	if url := os.Getenv("OSRM_BASE_URL"); url != "" {
+		cfg.OsrmBaseURL = strings.TrimSuffix(url, "/")
+	}
+	if n, err := strconv.Atoi(os.Getenv("OSRM_RETRY_ATTEMPTS")); err == nil && n > 0 {
+		cfg.RetryAttempts = n
+	}
+	if d, err := time.ParseDuration(os.Getenv("OSRM_RETRY_BACKOFF")); err == nil && d > 0 {
+		cfg.RetryBackoff = d
+	}
+	if d, err := time.ParseDuration(os.Getenv("OSRM_RETRY_MAX_BACKOFF")); err == nil && d > 0 {
+		cfg.RetryMaxBackoff = d
+	}
+	if f, err := strconv.ParseFloat(os.Getenv("MIN_DURATION"), 64); err == nil && f > 0 {
+		cfg.MinDuration = f
+	}
+	if n, err := strconv.Atoi(os.Getenv("OSRM_MAX_CONCURRENCY")); err == nil && n > 0 {
+		cfg.MaxConcurrency = n
+	}
+	if d, err := time.ParseDuration(os.Getenv("OSRM_HTTP_TIMEOUT")); err == nil && d > 0 {
+		cfg.HTTPTimeout = d
+	}
+	if n, err := strconv.Atoi(os.Getenv("MAX_DESTINATIONS")); err == nil && n > 0 {
+		cfg.MaxDestinations = n
+	}
+	switch policy := os.Getenv("COORDINATE_ALTITUDES"); policy {
+	case altitudeStrip, altitudeKeep:
+		cfg.CoordinateAltitudes = policy
+	}
+	if size, err := strconv.Atoi(os.Getenv("ROUTE_CACHE_SIZE")); err == nil && size > 0 {
+		ttl, err := time.ParseDuration(os.Getenv("ROUTE_CACHE_TTL"))
+		if err != nil || ttl <= 0 {
+			ttl = defaultRouteCacheTTL
+		}
+		cfg.RouteCache = newRouteLRU(size, ttl)
+		cfg.RouteCache.maxStale = defaultRouteCacheMaxStale
+		if maxStale, err := time.ParseDuration(os.Getenv("ROUTE_CACHE_MAX_STALE")); err == nil && maxStale >= 0 {
+			cfg.RouteCache.maxStale = maxStale
+		}
+	}
+	if n, err := strconv.ParseInt(os.Getenv("OSRM_GLOBAL_CONCURRENCY"), 10, 64); err == nil && n > 0 {
+		cfg.FetchBudget = newWeightedSemaphore(n)
+	}
+
+	return cfg.withDefaults()
+}
+
+// osrmClient returns the client the OSRM calls are made with. A Config that didn't come through
+// setupRouter gets a client with its HTTPTimeout.
+func (c Config) osrmClient() *http.Client {
+	if c.client != nil {
+		return c.client
+	}
+
+	return &http.Client{Timeout: c.withDefaults().HTTPTimeout}
+}
+
+// withDefaults fills in the default of every setting left unset
+func (c Config) withDefaults() Config {
+	if c.RetryAttempts <= 0 {
+		c.RetryAttempts = defaultRetryAttempts
+	}
+	if c.RetryBackoff <= 0 {
+		c.RetryBackoff = defaultRetryBackoff
+	}
+	if c.RetryMaxBackoff <= 0 {
+		c.RetryMaxBackoff = defaultRetryMaxBackoff
+	}
+	if c.MaxConcurrency <= 0 {
+		c.MaxConcurrency = defaultMaxConcurrency
+	}
+	if c.HTTPTimeout <= 0 {
+		c.HTTPTimeout = defaultHTTPTimeout
+	}
+	if c.MaxDestinations <= 0 {
+		c.MaxDestinations = defaultMaxDestinations
+	}
+	if c.CoordinateAltitudes == "" {
+		c.CoordinateAltitudes = altitudeReject
+	}
+
+	return c
+}
+
+// retryBackoff returns how long to wait before the retry-th retry, counting from 0
+func (c Config) retryBackoff(retry int) time.Duration {
+	backoff := c.RetryBackoff
+	for i := 0; i < retry && backoff < c.RetryMaxBackoff; i++ {
+		backoff *= 2
+	}
+	if backoff > c.RetryMaxBackoff {
+		backoff = c.RetryMaxBackoff
+	}
+
+	return backoff
+}
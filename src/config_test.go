@@ -0,0 +1,124 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConfigFromEnv(t *testing.T) {
+	t.Setenv("OSRM_BASE_URL", "")
+	t.Setenv("OSRM_RETRY_ATTEMPTS", "")
+	t.Setenv("OSRM_RETRY_BACKOFF", "")
+	t.Setenv("OSRM_RETRY_MAX_BACKOFF", "")
+	t.Setenv("MIN_DURATION", "")
+	t.Setenv("OSRM_MAX_CONCURRENCY", "")
+	t.Setenv("OSRM_HTTP_TIMEOUT", "")
+	t.Setenv("MAX_DESTINATIONS", "")
+	t.Setenv("COORDINATE_ALTITUDES", "")
+	t.Setenv("ROUTE_CACHE_SIZE", "")
+	t.Setenv("ROUTE_CACHE_TTL", "")
+	t.Setenv("ROUTE_CACHE_MAX_STALE", "")
+	t.Setenv("OSRM_GLOBAL_CONCURRENCY", "")
+	assert.Equal(t, Config{
+		OsrmBaseURL:         "http://router.project-osrm.org",
+		RetryAttempts:       20,
+		RetryBackoff:        time.Second,
+		RetryMaxBackoff:     30 * time.Second,
+		MaxConcurrency:      16,
+		HTTPTimeout:         10 * time.Second,
+		MaxDestinations:     100,
+		CoordinateAltitudes: "reject",
+	}, configFromEnv())
+
+	t.Setenv("OSRM_BASE_URL", "http://osrm:5000/")
+	t.Setenv("OSRM_RETRY_ATTEMPTS", "5")
+	t.Setenv("OSRM_RETRY_BACKOFF", "200ms")
+	t.Setenv("OSRM_RETRY_MAX_BACKOFF", "2s")
+	t.Setenv("MIN_DURATION", "30")
+	t.Setenv("OSRM_MAX_CONCURRENCY", "4")
+	t.Setenv("OSRM_HTTP_TIMEOUT", "3s")
+	t.Setenv("MAX_DESTINATIONS", "50")
+	t.Setenv("COORDINATE_ALTITUDES", "keep")
+	t.Setenv("ROUTE_CACHE_SIZE", "10")
+	t.Setenv("ROUTE_CACHE_TTL", "1m")
+	t.Setenv("ROUTE_CACHE_MAX_STALE", "10m")
+	t.Setenv("OSRM_GLOBAL_CONCURRENCY", "8")
+	cfg := configFromEnv()
+	assert.Equal(t, 10, cfg.RouteCache.size)
+	assert.Equal(t, time.Minute, cfg.RouteCache.ttl)
+	assert.Equal(t, 10*time.Minute, cfg.RouteCache.maxStale)
+	assert.Equal(t, int64(8), cfg.FetchBudget.size)
+
+	cfg.RouteCache, cfg.FetchBudget = nil, nil
+	assert.Equal(t, Config{
+		OsrmBaseURL:         "http://osrm:5000",
+		RetryAttempts:       5,
+		RetryBackoff:        200 * time.Millisecond,
+		RetryMaxBackoff:     2 * time.Second,
+		MinDuration:         30,
+		MaxConcurrency:      4,
+		HTTPTimeout:         3 * time.Second,
+		MaxDestinations:     50,
+		CoordinateAltitudes: "keep",
+	}, cfg)
+}
+
+func TestConfigFromEnvFallsBackToDefaultRouteCacheMaxStale(t *testing.T) {
+	t.Setenv("ROUTE_CACHE_SIZE", "10")
+	t.Setenv("ROUTE_CACHE_MAX_STALE", "")
+	assert.Equal(t, time.Hour, configFromEnv().RouteCache.maxStale)
+
+	t.Setenv("ROUTE_CACHE_MAX_STALE", "-1s")
+	assert.Equal(t, time.Hour, configFromEnv().RouteCache.maxStale)
+
+	// 0 serves nothing past the TTL
+	t.Setenv("ROUTE_CACHE_MAX_STALE", "0s")
+	assert.Equal(t, time.Duration(0), configFromEnv().RouteCache.maxStale)
+}
+
+func TestConfigFromEnvFallsBackToDefaultHTTPTimeout(t *testing.T) {
+	t.Setenv("OSRM_HTTP_TIMEOUT", "soon")
+	assert.Equal(t, 10*time.Second, configFromEnv().HTTPTimeout)
+
+	t.Setenv("OSRM_HTTP_TIMEOUT", "-1s")
+	assert.Equal(t, 10*time.Second, configFromEnv().HTTPTimeout)
+}
+
+func TestRoutersKeepTheirOwnHTTPTimeout(t *testing.T) {
+	mockOsrmApi := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(200 * time.Millisecond)
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"code":"Ok", "routes": [{"duration":2490.1,"distance":3286.3}]}`))
+	}))
+	defer mockOsrmApi.Close()
+
+	impatient := setupRouter(Config{OsrmBaseURL: mockOsrmApi.URL, HTTPTimeout: 50 * time.Millisecond})
+	patient := setupRouter(Config{OsrmBaseURL: mockOsrmApi.URL, HTTPTimeout: 5 * time.Second})
+
+	url := "/routes?src=13.388860,52.517037&dst=13.397634,52.529407"
+	var wg sync.WaitGroup
+	for _, r := range []*gin.Engine{impatient, patient} {
+		wg.Add(1)
+		go func(r *gin.Engine) {
+			defer wg.Done()
+
+			rec := httptest.NewRecorder()
+			req, _ := http.NewRequest(http.MethodGet, url, nil)
+			r.ServeHTTP(rec, req)
+			assert.Equal(t, http.StatusOK, rec.Code)
+
+			if r == impatient {
+				assert.Contains(t, rec.Body.String(), `"category":"timeout"`)
+			} else {
+				assert.Contains(t, rec.Body.String(), `"routes":[{"destination":"13.397634,52.529407"`)
+			}
+		}(r)
+	}
+	wg.Wait()
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+const csvContentType = "text/csv; charset=utf-8"
+
+// marshalCSV renders the routes as CSV with a header row and a row per route in the order of the
+// response. Coordinates hold a comma, the CSV writer quotes them.
+func (o *GetRoutesResp) marshalCSV() ([]byte, error) {
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+
+	w.Write([]string{"source", "destination", "duration", "distance"})
+	for _, route := range o.Routes {
+		w.Write([]string{
+			o.Source,
+			route.Destination,
+			strconv.FormatFloat(route.Duration, 'f', -1, 64),
+			strconv.FormatFloat(route.Distance, 'f', -1, 64),
+		})
+	}
+	w.Flush()
+
+	if err := w.Error(); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+func writeCSV(c *gin.Context, resp GetRoutesResp) {
+	body, err := resp.marshalCSV()
+	if err != nil {
+		writeErrResp(c, ErrResp{
+			Code:      http.StatusInternalServerError,
+			Message:   "Could not encode response",
+			ErrorCode: errCodeInternal,
+		})
+		return
+	}
+
+	c.Data(http.StatusOK, csvContentType, body)
+}
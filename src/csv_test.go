@@ -0,0 +1,51 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetRoutesReturnsCSV(t *testing.T) {
+	osrmApiPath := "/route/v1/driving/%s;%s"
+	src := "13.388860,52.517037"
+	dst1 := "13.397634,52.529407"
+	dst2 := "12.428555,52.523219"
+
+	mockOsrmApi := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		if r.URL.Path == fmt.Sprintf(osrmApiPath, src, dst1) {
+			w.Write([]byte(`{"code":"Ok", "routes": [{"duration":2490.1,"distance":1286.3}]}`))
+			return
+		}
+		w.Write([]byte(`{"code":"Ok", "routes": [{"duration":260.1,"distance":1886.3}]}`))
+	}))
+	defer mockOsrmApi.Close()
+
+	router = setupRouter(Config{OsrmBaseURL: mockOsrmApi.URL})
+	rec := mockGetRoutesRequest(fmt.Sprintf("/routes?src=%s&dst=%s&dst=%s&format=csv", src, dst1, dst2))
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, csvContentType, rec.Header().Get("Content-Type"))
+	assert.Equal(t, "source,destination,duration,distance\n"+
+		`"13.388860,52.517037","12.428555,52.523219",260.1,1886.3`+"\n"+
+		`"13.388860,52.517037","13.397634,52.529407",2490.1,1286.3`+"\n", rec.Body.String())
+
+	rec = mockGetRoutesRequest(fmt.Sprintf("/routes?src=%s&dst=%s&dst=%s&format=csv&sort=distance", src, dst1, dst2))
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "source,destination,duration,distance\n"+
+		`"13.388860,52.517037","13.397634,52.529407",2490.1,1286.3`+"\n"+
+		`"13.388860,52.517037","12.428555,52.523219",260.1,1886.3`+"\n", rec.Body.String())
+}
+
+func TestGetRoutesReturnsJSONErrorsWithCSV(t *testing.T) {
+	rec := mockGetRoutesRequest("/routes?src=13.388860,52.517037&dst=13.397634,91&format=csv")
+
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Equal(t, "application/json; charset=utf-8", rec.Header().Get("Content-Type"))
+	assert.JSONEq(t, `{"code":400,"message":"Dst is not a valid latitude and longitude","error_code":"invalid_coordinate"}`, rec.Body.String())
+}
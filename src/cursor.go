@@ -0,0 +1,85 @@
+package main
+
+import (
+	"encoding/base64"
+	"errors"
+	"strconv"
+	"strings"
+)
+
+var errInvalidCursor = errors.New("cursor is not valid")
+
+// routeCursor marks the last route of a page. The next page starts after its sort key rather than
+// at an offset, so routes that appear or drop out between two requests don't shift the pages.
+type routeCursor struct {
+	sort string
+
+	// The value the routes are sorted by, the value breaking its ties and the destination breaking theirs
+	primary     float64
+	secondary   float64
+	destination string
+}
+
+func newRouteCursor(sortBy string, route Route) routeCursor {
+	primary, secondary := route.Duration, route.Distance
+	if sortBy == "distance" {
+		primary, secondary = route.Distance, route.Duration
+	}
+
+	return routeCursor{sort: sortBy, primary: primary, secondary: secondary, destination: route.Destination}
+}
+
+// encode returns the cursor as an opaque string safe to use in a URL
+func (c routeCursor) encode() string {
+	return base64.RawURLEncoding.EncodeToString([]byte(strings.Join([]string{
+		c.sort,
+		strconv.FormatFloat(c.primary, 'g', -1, 64),
+		strconv.FormatFloat(c.secondary, 'g', -1, 64),
+		c.destination,
+	}, "|")))
+}
+
+func decodeRouteCursor(s string) (routeCursor, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return routeCursor{}, errInvalidCursor
+	}
+
+	parts := strings.Split(string(b), "|")
+	if len(parts) != 4 || (parts[0] != "duration" && parts[0] != "distance") {
+		return routeCursor{}, errInvalidCursor
+	}
+
+	c := routeCursor{sort: parts[0], destination: parts[3]}
+	if c.primary, err = strconv.ParseFloat(parts[1], 64); err != nil {
+		return routeCursor{}, errInvalidCursor
+	}
+	if c.secondary, err = strconv.ParseFloat(parts[2], 64); err != nil {
+		return routeCursor{}, errInvalidCursor
+	}
+
+	return c, nil
+}
+
+// less tells whether c sorts before other
+func (c routeCursor) less(other routeCursor) bool {
+	if c.primary != other.primary {
+		return c.primary < other.primary
+	}
+	if c.secondary != other.secondary {
+		return c.secondary < other.secondary
+	}
+
+	return c.destination < other.destination
+}
+
+// routesAfter drops the sorted routes up to and including the cursor's position
+func routesAfter(routes []Route, cursor routeCursor) []Route {
+	for i, route := range routes {
+		if cursor.less(newRouteCursor(cursor.sort, route)) {
+			return routes[i:]
+		}
+	}
+
+	return routes[len(routes):]
+}
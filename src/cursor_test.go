@@ -0,0 +1,114 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRouteCursorRoundTrip(t *testing.T) {
+	cursor := newRouteCursor("distance", Route{Destination: "13.397634,52.529407", Duration: 260.1, Distance: 1886.3})
+
+	decoded, err := decodeRouteCursor(cursor.encode())
+	assert.Nil(t, err)
+	assert.Equal(t, routeCursor{sort: "distance", primary: 1886.3, secondary: 260.1, destination: "13.397634,52.529407"}, decoded)
+
+	for _, invalid := range []string{"not base64!", "ZHVyYXRpb24", cursor.encode()[:10]} {
+		_, err = decodeRouteCursor(invalid)
+		assert.Equal(t, errInvalidCursor, err, invalid)
+	}
+}
+
+func TestRoutesAfter(t *testing.T) {
+	routes := []Route{
+		{Destination: "a", Duration: 100, Distance: 1000},
+		{Destination: "b", Duration: 200, Distance: 1000},
+		{Destination: "c", Duration: 200, Distance: 1000},
+		{Destination: "d", Duration: 300, Distance: 500},
+	}
+
+	assert.Equal(t, routes[2:], routesAfter(routes, newRouteCursor("duration", routes[1])))
+	assert.Empty(t, routesAfter(routes, newRouteCursor("duration", routes[3])))
+
+	// The cursor's route is gone, the page still starts after where it was
+	assert.Equal(t, routes[1:], routesAfter(routes, routeCursor{sort: "duration", primary: 150}))
+}
+
+func TestGetRoutesPaginatesWithCursors(t *testing.T) {
+	mockOsrmApi := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// The duration grows with the destination's longitude
+		var lng, lat float64
+		fmt.Sscanf(r.URL.Path[strings.LastIndex(r.URL.Path, ";")+1:], "%f,%f", &lng, &lat)
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(fmt.Sprintf(`{"code":"Ok", "routes": [{"duration":%.1f,"distance":3286.3}]}`, (lng-13)*1000)))
+	}))
+	defer mockOsrmApi.Close()
+
+	router = setupRouter(Config{OsrmBaseURL: mockOsrmApi.URL})
+
+	dsts := []string{"13.5,52.5", "13.1,52.5", "13.4,52.5", "13.2,52.5", "13.3,52.5"}
+	var pages [][]string
+	cursor := ""
+	for {
+		url := "/routes?src=13.0,52.5&limit=2&dst=" + strings.Join(dsts, "&dst=")
+		if cursor != "" {
+			url += "&cursor=" + cursor
+		}
+		rec := mockGetRoutesRequest(url)
+		assert.Equal(t, http.StatusOK, rec.Code)
+
+		var resp GetRoutesResp
+		assert.Nil(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+		var page []string
+		for _, route := range resp.Routes {
+			page = append(page, route.Destination)
+		}
+		pages = append(pages, page)
+
+		if resp.NextCursor == "" {
+			break
+		}
+		cursor = resp.NextCursor
+
+		// Routes of earlier pages disappearing don't shift the next pages
+		dsts = withoutCoordinate(dsts, page[0])
+	}
+
+	assert.Equal(t, [][]string{
+		{"13.1,52.5", "13.2,52.5"},
+		{"13.3,52.5", "13.4,52.5"},
+		{"13.5,52.5"},
+	}, pages)
+}
+
+func withoutCoordinate(coordinates []string, c string) []string {
+	var without []string
+	for _, coordinate := range coordinates {
+		if coordinate != c {
+			without = append(without, coordinate)
+		}
+	}
+
+	return without
+}
+
+func TestGetRoutesReturns400WhenCursorIsInvalid(t *testing.T) {
+	router = setupRouter(Config{})
+	durationCursor := newRouteCursor("duration", Route{Destination: "13.397634,52.529407", Duration: 260.1}).encode()
+
+	for query, message := range map[string]string{
+		"&cursor=garbage": "Cursor is not valid",
+		"&cursor=" + durationCursor + "&sort=distance":   "Cursor was issued for sort=duration",
+		"&cursor=" + durationCursor + "&order=13.1,52.5": "Cursor can't be combined with order",
+	} {
+		rec := mockGetRoutesRequest("/routes?src=13.0,52.5&dst=13.1,52.5" + query)
+
+		assert.Equal(t, http.StatusBadRequest, rec.Code, query)
+		assert.Equal(t, `{"code":400,"message":"`+message+`","error_code":"invalid_parameter"}`, rec.Body.String(), query)
+	}
+}
@@ -0,0 +1,76 @@
+package main
+
+// EffectiveOptions echoes the options that governed a request, with defaults filled in
+// for everything the query didn't override. Units is the unit system the routes were
+// converted to, whether given or inferred from Accept-Language, and absent without one.
+type EffectiveOptions struct {
+	Sort                  string  `json:"sort"`
+	Profile               string  `json:"profile"`
+	Units                 string  `json:"units,omitempty"`
+	Naming                string  `json:"naming"`
+	Pretty                bool    `json:"pretty"`
+	RoadClasses           bool    `json:"roadClasses"`
+	MaxCalls              int     `json:"maxCalls"`
+	Limit                 int     `json:"limit"`
+	Cluster               float64 `json:"cluster"`
+	OnEmpty               string  `json:"onEmpty"`
+	Strategy              string  `json:"strategy"`
+	RejectEdgeCoordinates bool    `json:"rejectEdgeCoordinates"`
+	Timeout               string  `json:"timeout"`
+	RetryAttempts         int     `json:"retryAttempts"`
+	RetryBackoff          string  `json:"retryBackoff"`
+	RetryMaxBackoff       string  `json:"retryMaxBackoff"`
+	MaxConcurrency        int     `json:"maxConcurrency"`
+}
+
+func effectiveOptions(cfg Config, query QueryParams) *EffectiveOptions {
+	cfg = cfg.withDefaults()
+
+	naming := query.Naming
+	if naming == "" {
+		naming = namingCamel
+	}
+
+	onEmpty := query.OnEmpty
+	if onEmpty == "" {
+		onEmpty = "ok"
+	}
+
+	sortBy := "duration"
+	if query.Sort != "" {
+		sortBy = query.Sort
+	}
+	if len(query.Order) > 0 {
+		sortBy = "order"
+	}
+
+	profile := query.Profile
+	if profile == "" {
+		profile = osrmProfiles[0]
+	}
+
+	strategy := query.Strategy
+	if strategy == "" {
+		strategy = strategyWaitGroup
+	}
+
+	return &EffectiveOptions{
+		Sort:                  sortBy,
+		Profile:               profile,
+		Units:                 query.Units,
+		Naming:                naming,
+		Pretty:                query.Pretty,
+		RoadClasses:           query.RoadClasses,
+		MaxCalls:              query.MaxCalls,
+		Limit:                 query.Limit,
+		Cluster:               query.Cluster,
+		OnEmpty:               onEmpty,
+		Strategy:              strategy,
+		RejectEdgeCoordinates: rejectEdgeCoordinates,
+		Timeout:               cfg.HTTPTimeout.String(),
+		RetryAttempts:         cfg.RetryAttempts,
+		RetryBackoff:          cfg.RetryBackoff.String(),
+		RetryMaxBackoff:       cfg.RetryMaxBackoff.String(),
+		MaxConcurrency:        cfg.MaxConcurrency,
+	}
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Results of recent responses, so a client polling with the ETag it got last time
+// only receives the routes that changed since
+var routeSnapshots = newSnapshotStore(1000)
+
+type routeValues struct {
+	duration float64
+	distance float64
+}
+
+// snapshotStore keeps the most recent snapshots in a ring buffer, the oldest is evicted first
+type snapshotStore struct {
+	mu        sync.Mutex
+	snapshots map[string]map[string]routeValues
+	keys      []string
+	next      int
+}
+
+func newSnapshotStore(size int) *snapshotStore {
+	return &snapshotStore{
+		snapshots: make(map[string]map[string]routeValues),
+		keys:      make([]string, size),
+	}
+}
+
+func (s *snapshotStore) save(etag string, routes []Route) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Identical results share a snapshot, keep it where it is
+	if _, ok := s.snapshots[etag]; ok {
+		return
+	}
+
+	values := make(map[string]routeValues, len(routes))
+	for _, route := range routes {
+		values[route.Destination] = routeValues{duration: route.Duration, distance: route.Distance}
+	}
+
+	delete(s.snapshots, s.keys[s.next])
+	s.keys[s.next] = etag
+	s.next = (s.next + 1) % len(s.keys)
+	s.snapshots[etag] = values
+}
+
+func (s *snapshotStore) get(etag string) (map[string]routeValues, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	values, ok := s.snapshots[etag]
+	return values, ok
+}
+
+// routesETag identifies the durations and distances from src to every routed destination as
+// encoded in the given representation, so every encoding of the same routes has its own ETag
+func routesETag(src string, representation string, routes []Route) string {
+	h := sha256.New()
+	fmt.Fprintf(h, "%s\n%s\n", src, representation)
+	for _, route := range routes {
+		fmt.Fprintf(h, "%s|%v|%v\n", route.Destination, route.Duration, route.Distance)
+	}
+
+	return hex.EncodeToString(h.Sum(nil)[:16])
+}
+
+// responseRepresentation names the encoding respondRoutes answers the request in, following the
+// same precedence as its output switch
+func responseRepresentation(c *gin.Context, query QueryParams) string {
+	switch {
+	case query.Format == "kml" || query.Format == "gpx":
+		return query.Format
+	case query.Format == "geojson" || acceptsGeoJSON(c):
+		return "geojson"
+	case query.Format == "csv":
+		return query.Format
+	case acceptsProtobuf(c):
+		return "protobuf"
+	}
+
+	shape := "routes"
+	switch {
+	case query.Flat:
+		shape = "flat"
+	case query.GroupByGrid != nil:
+		shape = fmt.Sprintf("grid%d", *query.GroupByGrid)
+	case query.Keyed:
+		shape = "keyed"
+	}
+
+	return fmt.Sprintf("json|%s|naming=%s|pretty=%t", shape, query.Naming, query.Pretty)
+}
+
+// changedRoutes returns the routes that are new or whose duration or distance differ from the
+// snapshot, and the destinations of the snapshot that no longer have a route
+func changedRoutes(previous map[string]routeValues, routes []Route) (changed []Route, removed []string) {
+	changed = make([]Route, 0)
+	routed := make(map[string]bool, len(routes))
+	for _, route := range routes {
+		routed[route.Destination] = true
+		prev, ok := previous[route.Destination]
+		if !ok || prev.duration != route.Duration || prev.distance != route.Distance {
+			changed = append(changed, route)
+		}
+	}
+
+	for destination := range previous {
+		if !routed[destination] {
+			removed = append(removed, destination)
+		}
+	}
+	sort.Strings(removed)
+
+	return changed, removed
+}
+
+// The ETag header is quoted, clients may send it back with or without the quotes
+func parseETag(s string) string {
+	return strings.Trim(strings.TrimPrefix(s, "W/"), `"`)
+}
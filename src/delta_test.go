@@ -0,0 +1,138 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetRoutesReturnsOnlyChangedRoutesSincePreviousETag(t *testing.T) {
+	src := "13.388860,52.517037"
+	dst1 := "13.397634,52.529407"
+	dst2 := "12.428555,52.523219"
+
+	polls := 0
+	mockOsrmApi := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		if strings.Contains(r.URL.Path, dst1) && polls > 0 {
+			// Traffic slowed down the route to dst1 since the first poll
+			w.Write([]byte(`{"code":"Ok", "routes": [{"duration":2790.1,"distance":3286.3}]}`))
+			return
+		}
+		if strings.Contains(r.URL.Path, dst1) {
+			w.Write([]byte(`{"code":"Ok", "routes": [{"duration":2490.1,"distance":3286.3}]}`))
+			return
+		}
+		w.Write([]byte(`{"code":"Ok", "routes": [{"duration":260.1,"distance":1886.3}]}`))
+	}))
+	defer mockOsrmApi.Close()
+
+	router = setupRouter(Config{OsrmBaseURL: mockOsrmApi.URL})
+	url := fmt.Sprintf("/routes?src=%s&dst=%s&dst=%s", src, dst1, dst2)
+
+	first := mockGetRoutesRequest(url)
+	assert.Equal(t, http.StatusOK, first.Code)
+	etag := first.Header().Get("ETag")
+	assert.NotEmpty(t, etag)
+
+	polls++
+	second := mockGetRoutesRequest(url + "&since=" + strings.Trim(etag, `"`))
+
+	var resp GetRoutesResp
+	assert.Nil(t, json.Unmarshal(second.Body.Bytes(), &resp))
+	assert.True(t, resp.Delta)
+	assert.Equal(t, []Route{{Destination: dst1, Duration: 2790.1, Distance: 3286.3}}, resp.Routes)
+	assert.NotEqual(t, etag, second.Header().Get("ETag"))
+
+	// Nothing changed since the second poll
+	third := mockGetRoutesRequest(url + "&since=" + strings.Trim(second.Header().Get("ETag"), `"`))
+	assert.Contains(t, third.Body.String(), `"delta":true,"routes":[]`)
+}
+
+func TestGetRoutesReturnsAllRoutesForUnknownETag(t *testing.T) {
+	mockOsrmApi := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"code":"Ok", "routes": [{"duration":2490.1,"distance":3286.3}]}`))
+	}))
+	defer mockOsrmApi.Close()
+
+	router = setupRouter(Config{OsrmBaseURL: mockOsrmApi.URL})
+	rec := mockGetRoutesRequest("/routes?src=13.388860,52.517037&dst=13.397634,52.529407&since=unknown")
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.NotContains(t, rec.Body.String(), `"delta"`)
+	assert.Contains(t, rec.Body.String(), `"routes":[{"destination":"13.397634,52.529407"`)
+}
+
+func TestGetRoutesReportsDestinationsNoLongerRoutedAsRemoved(t *testing.T) {
+	src := "13.388860,52.517037"
+	dst1 := "13.397634,52.529407"
+	dst2 := "12.428555,52.523219"
+
+	polls := 0
+	mockOsrmApi := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		if strings.Contains(r.URL.Path, dst2) && polls > 0 {
+			// dst2 became unreachable since the first poll
+			w.Write([]byte(`{"code":"NoRoute", "routes": []}`))
+			return
+		}
+		w.Write([]byte(`{"code":"Ok", "routes": [{"duration":260.1,"distance":1886.3}]}`))
+	}))
+	defer mockOsrmApi.Close()
+
+	router = setupRouter(Config{OsrmBaseURL: mockOsrmApi.URL})
+	url := fmt.Sprintf("/routes?src=%s&dst=%s&dst=%s", src, dst1, dst2)
+
+	first := mockGetRoutesRequest(url)
+	assert.Equal(t, http.StatusOK, first.Code)
+
+	polls++
+	second := mockGetRoutesRequest(url + "&since=" + strings.Trim(first.Header().Get("ETag"), `"`))
+
+	var resp GetRoutesResp
+	assert.Nil(t, json.Unmarshal(second.Body.Bytes(), &resp))
+	assert.True(t, resp.Delta)
+	assert.Empty(t, resp.Routes)
+	assert.Equal(t, []string{dst2}, resp.Removed)
+}
+
+func TestGetRoutesETagDiffersByRepresentation(t *testing.T) {
+	mockOsrmApi := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"code":"Ok", "routes": [{"duration":2490.1,"distance":3286.3}]}`))
+	}))
+	defer mockOsrmApi.Close()
+
+	router = setupRouter(Config{OsrmBaseURL: mockOsrmApi.URL})
+	url := "/routes?src=13.388860,52.517037&dst=13.397634,52.529407"
+
+	etags := make(map[string]string)
+	for _, params := range []string{"", "&pretty=true", "&naming=snake", "&format=kml", "&format=gpx", "&format=csv", "&format=geojson", "&keyed=true", "&flat=true"} {
+		rec := mockGetRoutesRequest(url + params)
+		assert.Equal(t, http.StatusOK, rec.Code, params)
+		etag := rec.Header().Get("ETag")
+		assert.NotContains(t, etags, etag, params)
+		etags[etag] = params
+	}
+
+	// The same request keeps its ETag
+	assert.Contains(t, etags, mockGetRoutesRequest(url+"&format=csv").Header().Get("ETag"))
+}
+
+func TestSnapshotStoreEvictsOldest(t *testing.T) {
+	store := newSnapshotStore(2)
+	store.save("a", nil)
+	store.save("b", nil)
+	store.save("c", nil)
+
+	_, ok := store.get("a")
+	assert.False(t, ok)
+	_, ok = store.get("c")
+	assert.True(t, ok)
+}
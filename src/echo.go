@@ -0,0 +1,27 @@
+package main
+
+// replayableQuery returns the query as it was routed, normalized and with the defaults it was
+// routed with filled in, so sending it as the body of POST /routes repeats the request exactly
+// even once the server defaults change
+func replayableQuery(query QueryParams) QueryParams {
+	if query.Profile == "" {
+		query.Profile = osrmProfiles[0]
+	}
+	if query.Sort == "" {
+		query.Sort = "duration"
+	}
+	if query.Naming == "" {
+		query.Naming = namingCamel
+	}
+	if query.OnEmpty == "" {
+		query.OnEmpty = "ok"
+	}
+	if query.Unordered == "" && len(query.Order) > 0 {
+		query.Unordered = "end"
+	}
+
+	// Addresses have been replaced by the coordinates they were resolved to
+	query.Geocode = false
+
+	return query
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetRoutesEchoesNormalizedRequest(t *testing.T) {
+	mockOsrmApi := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"code":"Ok", "routes": [{"duration":2490.1,"distance":3286.3}]}`))
+	}))
+	defer mockOsrmApi.Close()
+
+	router = setupRouter(Config{OsrmBaseURL: mockOsrmApi.URL})
+	rec := mockGetRoutesRequest("/routes?src=13.388860+52.517037&dst=13.397634%3B52.529407&dst=13.397634,52.529407&dst=13.428555,52.523219&limit=5&echo=true")
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	var resp GetRoutesResp
+	assert.Nil(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	assert.Equal(t, &QueryParams{
+		Src:     "13.388860,52.517037",
+		Dst:     []string{"13.397634,52.529407", "13.428555,52.523219"},
+		Limit:   5,
+		Sort:    "duration",
+		Naming:  "camel",
+		OnEmpty: "ok",
+		Profile: "driving",
+		Echo:    true,
+	}, resp.Request)
+
+	// Replaying the echoed request routes the same
+	body, _ := json.Marshal(resp.Request)
+	replay := mockPostRoutesRequest(string(body))
+	assert.Equal(t, http.StatusOK, replay.Code)
+	assert.Equal(t, rec.Body.String(), replay.Body.String())
+}
+
+func TestGetRoutesOnlyEchoesRequestWhenAsked(t *testing.T) {
+	mockOsrmApi := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"code":"Ok", "routes": [{"duration":2490.1,"distance":3286.3}]}`))
+	}))
+	defer mockOsrmApi.Close()
+
+	router = setupRouter(Config{OsrmBaseURL: mockOsrmApi.URL})
+	rec := mockGetRoutesRequest("/routes?src=13.388860,52.517037&dst=13.397634,52.529407")
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.NotContains(t, rec.Body.String(), `"request"`)
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"strings"
+	"sync"
+)
+
+// EngineInfo identifies the routing engine and map data that produced the routes, so a cached or
+// archived response can be traced back to a map vintage
+type EngineInfo struct {
+	Name        string `json:"name"`
+	Version     string `json:"version,omitempty"`
+	DataVersion string `json:"dataVersion,omitempty"`
+}
+
+// engineInfos caches what each OSRM backend advertised in its latest response, by base URL
+var engineInfos = &engineInfoCache{infos: make(map[string]EngineInfo)}
+
+type engineInfoCache struct {
+	mu    sync.Mutex
+	infos map[string]EngineInfo
+}
+
+// observe records the versions an OSRM response advertised. OSRM reports the data version in the
+// body when its data was built with one, the engine version is only known from a Server header
+// such as osrm-routed/5.27.1.
+func (e *engineInfoCache) observe(baseURL string, server string, dataVersion string) {
+	info := EngineInfo{Name: "OSRM", DataVersion: dataVersion}
+	if name, version, ok := strings.Cut(server, "/"); ok && strings.Contains(strings.ToLower(name), "osrm") {
+		info.Name, info.Version = name, version
+	}
+
+	if info.Version == "" && info.DataVersion == "" {
+		return
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.infos[baseURL] = info
+}
+
+func (e *engineInfoCache) get(baseURL string) (EngineInfo, bool) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	info, ok := e.infos[baseURL]
+	return info, ok
+}
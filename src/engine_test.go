@@ -0,0 +1,45 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetRoutesReturnsEngineInfoAdvertisedByBackend(t *testing.T) {
+	mockOsrmApi := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Server", "osrm-routed/5.27.1")
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"code":"Ok", "data_version":"2023-06-01T12:00:00", "routes": [{"duration":2490.1,"distance":3286.3}]}`))
+	}))
+	defer mockOsrmApi.Close()
+
+	router = setupRouter(Config{OsrmBaseURL: mockOsrmApi.URL})
+	rec := mockGetRoutesRequest("/routes?src=13.388860,52.517037&dst=13.397634,52.529407")
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	var resp GetRoutesResp
+	json.Unmarshal(rec.Body.Bytes(), &resp)
+	assert.Equal(t, &EngineInfo{Name: "osrm-routed", Version: "5.27.1", DataVersion: "2023-06-01T12:00:00"}, resp.Metadata.Engine)
+}
+
+func TestEngineInfoCacheObserve(t *testing.T) {
+	cache := &engineInfoCache{infos: make(map[string]EngineInfo)}
+
+	cache.observe("http://a", "nginx/1.25.1", "")
+	_, ok := cache.get("http://a")
+	assert.False(t, ok)
+
+	cache.observe("http://a", "nginx/1.25.1", "2023-06-01")
+	info, ok := cache.get("http://a")
+	assert.True(t, ok)
+	assert.Equal(t, EngineInfo{Name: "OSRM", DataVersion: "2023-06-01"}, info)
+
+	cache.observe("http://b", "osrm-routed/5.27.1", "")
+	info, _ = cache.get("http://b")
+	assert.Equal(t, EngineInfo{Name: "osrm-routed", Version: "5.27.1"}, info)
+}
@@ -0,0 +1,39 @@
+package main
+
+import "fmt"
+
+// Exclusion explains why a filter of the request left a destination out of the routes
+type Exclusion struct {
+	Destination    string `json:"destination"`
+	ExcludedReason string `json:"excludedReason"`
+}
+
+// filterExclusions explains the destinations left out before routing, those over the maxCalls
+// budget and those farther than the prefilterNearest nearest as the crow flies
+func filterExclusions(skipped []string, maxCalls int, prefiltered []string, prefilterNearest int) []Exclusion {
+	var excluded []Exclusion
+	for _, dst := range skipped {
+		excluded = append(excluded, Exclusion{
+			Destination:    dst,
+			ExcludedReason: fmt.Sprintf("routing it would exceed maxCalls of %d", maxCalls),
+		})
+	}
+	for _, dst := range prefiltered {
+		excluded = append(excluded, Exclusion{
+			Destination:    dst,
+			ExcludedReason: fmt.Sprintf("not among the prefilterNearest of %d destinations nearest as the crow flies", prefilterNearest),
+		})
+	}
+
+	return excluded
+}
+
+// limitExclusions explains the routes filterByLimits moved to the failures with their messages
+func limitExclusions(exceeded []Failure) []Exclusion {
+	excluded := make([]Exclusion, len(exceeded))
+	for i, failure := range exceeded {
+		excluded[i] = Exclusion{Destination: failure.Destination, ExcludedReason: failure.Message}
+	}
+
+	return excluded
+}
@@ -0,0 +1,92 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func mockExcludedOsrmApi() *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		if r.URL.Path == "/route/v1/driving/13.388860,52.517037;13.397634,52.529407" {
+			w.Write([]byte(`{"code":"Ok", "routes": [{"duration":2490.1,"distance":3286.3}]}`))
+			return
+		}
+		w.Write([]byte(`{"code":"Ok", "routes": [{"duration":260.1,"distance":1886.3}]}`))
+	}))
+}
+
+func TestGetRoutesExplainsDestinationsOverMaxDuration(t *testing.T) {
+	mockOsrmApi := mockExcludedOsrmApi()
+	defer mockOsrmApi.Close()
+
+	router = setupRouter(Config{OsrmBaseURL: mockOsrmApi.URL})
+	rec := mockGetRoutesRequest("/routes?src=13.388860,52.517037&dst=13.397634,52.529407&dst=12.428555,52.523219&max_duration=1800")
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"excluded":[{"destination":"13.397634,52.529407","excludedReason":"duration of 2490.1s exceeds max_duration of 1800s"}]`)
+}
+
+func TestGetRoutesExplainsDestinationsOverMaxDistance(t *testing.T) {
+	mockOsrmApi := mockExcludedOsrmApi()
+	defer mockOsrmApi.Close()
+
+	router = setupRouter(Config{OsrmBaseURL: mockOsrmApi.URL})
+	rec := mockGetRoutesRequest("/routes?src=13.388860,52.517037&dst=13.397634,52.529407&dst=12.428555,52.523219&max_distance=2000")
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"excluded":[{"destination":"13.397634,52.529407","excludedReason":"distance of 3286.3m exceeds max_distance of 2000m"}]`)
+}
+
+func TestGetRoutesExplainsPrefilteredDestinations(t *testing.T) {
+	mockOsrmApi := mockExcludedOsrmApi()
+	defer mockOsrmApi.Close()
+
+	router = setupRouter(Config{OsrmBaseURL: mockOsrmApi.URL})
+	rec := mockGetRoutesRequest("/routes?src=13.388860,52.517037&dst=13.428555,48.523219&dst=13.397634,52.529407&prefilterNearest=1")
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"excluded":[{"destination":"13.428555,48.523219","excludedReason":"not among the prefilterNearest of 1 destinations nearest as the crow flies"}]`)
+}
+
+func TestGetRoutesExplainsDestinationsOverMaxCalls(t *testing.T) {
+	mockOsrmApi := mockExcludedOsrmApi()
+	defer mockOsrmApi.Close()
+
+	router = setupRouter(Config{OsrmBaseURL: mockOsrmApi.URL})
+	rec := mockGetRoutesRequest("/routes?src=13.388860,52.517037&dst=13.397634,52.529407&dst=12.428555,52.523219&maxCalls=1")
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"excluded":[{"destination":"12.428555,52.523219","excludedReason":"routing it would exceed maxCalls of 1"}]`)
+}
+
+func TestGetRoutesExplainsEveryFilterInOrder(t *testing.T) {
+	mockOsrmApi := mockExcludedOsrmApi()
+	defer mockOsrmApi.Close()
+
+	// The farthest is prefiltered, one is over maxCalls and the routed one exceeds max_duration
+	router = setupRouter(Config{OsrmBaseURL: mockOsrmApi.URL})
+	rec := mockGetRoutesRequest(fmt.Sprintf("/routes?src=13.388860,52.517037&dst=%s&dst=%s&dst=%s&prefilterNearest=2&maxCalls=1&max_duration=1800",
+		"13.397634,52.529407", "13.428555,48.523219", "13.412,52.5"))
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"excluded":[`+
+		`{"destination":"13.412,52.5","excludedReason":"routing it would exceed maxCalls of 1"},`+
+		`{"destination":"13.428555,48.523219","excludedReason":"not among the prefilterNearest of 2 destinations nearest as the crow flies"},`+
+		`{"destination":"13.397634,52.529407","excludedReason":"duration of 2490.1s exceeds max_duration of 1800s"}]`)
+}
+
+func TestGetRoutesExplainsNothingWithoutFilters(t *testing.T) {
+	mockOsrmApi := mockExcludedOsrmApi()
+	defer mockOsrmApi.Close()
+
+	router = setupRouter(Config{OsrmBaseURL: mockOsrmApi.URL})
+	rec := mockGetRoutesRequest("/routes?src=13.388860,52.517037&dst=13.397634,52.529407&dst=12.428555,52.523219")
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.NotContains(t, rec.Body.String(), "excluded")
+}
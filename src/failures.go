@@ -0,0 +1,148 @@
+package main
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"net/url"
+	"sort"
+)
+
+// Categories of per-destination failures
+const (
+	categoryNoRoute      = "no_route"
+	categoryBackendError = "backend_error"
+	categoryTimeout      = "timeout"
+	categoryNoSegment    = "no_segment"
+	categoryInvalidValue = "invalid_value"
+
+	categoryGeometryTooLarge = "geometry_too_large"
+	categoryExceedsLimit     = "exceeds_limit"
+)
+
+// noSegmentHint is reported with destinations OSRM couldn't snap to any road, which usually
+// means they are in water or too far from the road network
+var noSegmentHint = "The destination could not be snapped to a road, try a larger radiuses value"
+
+// errTruncatedResponse marks an OSRM response whose body was cut off, which is worth retrying
+var errTruncatedResponse = errors.New("truncated OSRM response")
+
+// errRetriesExhausted marks an OSRM call that was answered with a 429 on every attempt
+var errRetriesExhausted = errors.New("OSRM retries exhausted")
+
+// Failure reports a destination that could not be routed, with an HTTP-like status so
+// clients can handle every destination the same way
+type Failure struct {
+	Destination string `json:"destination"`
+	Status      int    `json:"status"`
+	Category    string `json:"category"`
+	Message     string `json:"message"`
+	Hint        string `json:"hint,omitempty"`
+
+	// Parameters lists the parameters forwarded to OSRM when it rejected one of them as invalid
+	Parameters []string `json:"parameters,omitempty"`
+
+	// Profile tells which of the compared profiles failed, see compare=
+	Profile string `json:"profile,omitempty"`
+}
+
+// RouteError is returned by getRouteData when OSRM answers but can't provide a route
+type RouteError struct {
+	Category   string
+	Message    string
+	Hint       string
+	Parameters []string
+}
+
+func (e *RouteError) Error() string {
+	return e.Message
+}
+
+// osrmErrCategory maps an OSRM response code to a failure category
+func osrmErrCategory(code string) string {
+	switch code {
+	case "NoRoute":
+		return categoryNoRoute
+	case "NoSegment":
+		return categoryNoSegment
+	case "InvalidValue":
+		return categoryInvalidValue
+	default:
+		return categoryBackendError
+	}
+}
+
+// osrmParameters lists the query parameters sent to OSRM as sorted key=value pairs
+func osrmParameters(params url.Values) []string {
+	var pairs []string
+	for key, values := range params {
+		for _, v := range values {
+			pairs = append(pairs, key+"="+v)
+		}
+	}
+	sort.Strings(pairs)
+
+	return pairs
+}
+
+func newFailure(dst string, err error) Failure {
+	category := categoryBackendError
+	hint := ""
+	var parameters []string
+
+	var routeErr *RouteError
+	var netErr net.Error
+	if errors.As(err, &routeErr) {
+		category, hint, parameters = routeErr.Category, routeErr.Hint, routeErr.Parameters
+	} else if errors.As(err, &netErr) && netErr.Timeout() {
+		category = categoryTimeout
+	}
+
+	return Failure{
+		Destination: dst,
+		Status:      failureStatus(category),
+		Category:    category,
+		Message:     err.Error(),
+		Hint:        hint,
+		Parameters:  parameters,
+	}
+}
+
+func failureStatus(category string) int {
+	switch category {
+	case categoryNoRoute, categoryNoSegment, categoryGeometryTooLarge, categoryExceedsLimit:
+		return http.StatusUnprocessableEntity
+	case categoryInvalidValue:
+		return http.StatusBadRequest
+	case categoryTimeout:
+		return http.StatusGatewayTimeout
+	default:
+		return http.StatusBadGateway
+	}
+}
+
+// sortFailures puts the failures in the order their destinations were requested
+func sortFailures(failures []Failure, dsts []string) {
+	position := make(map[string]int, len(dsts))
+	for i := len(dsts) - 1; i >= 0; i-- {
+		position[dsts[i]] = i
+	}
+
+	sort.SliceStable(failures, func(i, j int) bool {
+		return position[failures[i].Destination] < position[failures[j].Destination]
+	})
+}
+
+// expandFailures copies the failure of each representative to every member of its cluster
+func expandFailures(failures []Failure, clusters map[string][]string) []Failure {
+	expanded := make([]Failure, 0, len(failures))
+	for _, failure := range failures {
+		for _, member := range clusters[failure.Destination] {
+			f := failure
+			f.Destination = member
+			expanded = append(expanded, f)
+		}
+	}
+
+	return expanded
+}
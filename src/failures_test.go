@@ -0,0 +1,185 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewFailureStatus(t *testing.T) {
+	assert.Equal(t, http.StatusUnprocessableEntity, newFailure("a", &RouteError{Category: categoryNoRoute, Message: "no route"}).Status)
+	assert.Equal(t, http.StatusBadGateway, newFailure("a", errors.New("response code: 500")).Status)
+}
+
+func TestGetRoutesReturnsPerDestinationStatus(t *testing.T) {
+	mockOsrmApi := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case strings.Contains(r.URL.Path, "12.428555,52.523219"):
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"code":"NoRoute", "message": "Impossible route between points"}`))
+		case strings.Contains(r.URL.Path, "13.428555,48.523219"):
+			w.WriteHeader(http.StatusServiceUnavailable)
+		case strings.Contains(r.URL.Path, "10.428555,29.523219"):
+			time.Sleep(200 * time.Millisecond)
+		default:
+			w.WriteHeader(http.StatusOK)
+			w.Write([]byte(`{"code":"Ok", "routes": [{"duration":2490.1,"distance":3286.3}]}`))
+		}
+	}))
+	defer mockOsrmApi.Close()
+
+	router = setupRouter(Config{OsrmBaseURL: mockOsrmApi.URL, HTTPTimeout: 100 * time.Millisecond})
+
+	rec := mockGetRoutesRequest(fmt.Sprintf("/routes?src=13.388860,52.517037&dst=%s&dst=%s&dst=%s&dst=%s",
+		"10.428555,29.523219", "13.397634,52.529407", "12.428555,52.523219", "13.428555,48.523219"))
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	body := rec.Body.String()
+	assert.Contains(t, body, `"routes":[{"destination":"13.397634,52.529407"`)
+	assert.Contains(t, body, `"failures":[`+
+		`{"destination":"10.428555,29.523219","status":504,"category":"timeout",`)
+	assert.Contains(t, body, `{"destination":"12.428555,52.523219","status":422,"category":"no_route","message":"response code: 400. message: Impossible route between points"},`+
+		`{"destination":"13.428555,48.523219","status":502,"category":"backend_error","message":"response code: 503"}]`)
+}
+
+func TestGetRoutesReportsNoSegmentWithHint(t *testing.T) {
+	mockOsrmApi := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"code":"NoSegment", "message": "Could not find a matching segment for coordinate 1"}`))
+	}))
+	defer mockOsrmApi.Close()
+
+	router = setupRouter(Config{OsrmBaseURL: mockOsrmApi.URL})
+	rec := mockGetRoutesRequest("/routes?src=13.388860,52.517037&dst=4.428555,54.523219")
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"failures":[{"destination":"4.428555,54.523219","status":422,"category":"no_segment",`+
+		`"message":"response code: 400. message: Could not find a matching segment for coordinate 1",`+
+		`"hint":"The destination could not be snapped to a road, try a larger radiuses value"}]`)
+}
+
+func TestGetRoutesRetriesTruncatedResponses(t *testing.T) {
+	var calls int32
+	mockOsrmApi := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch atomic.AddInt32(&calls, 1) {
+		case 1:
+			// The connection is closed before the announced body was sent
+			w.Header().Set("Content-Length", "100")
+			w.WriteHeader(http.StatusOK)
+			w.Write([]byte(`{"code":"Ok", "rou`))
+		case 2:
+			w.WriteHeader(http.StatusOK)
+			w.Write([]byte(`{"code":"Ok", "routes": [{"duration":2490.1,`))
+		default:
+			w.WriteHeader(http.StatusOK)
+			w.Write([]byte(`{"code":"Ok", "routes": [{"duration":2490.1,"distance":3286.3}]}`))
+		}
+	}))
+	defer mockOsrmApi.Close()
+
+	router = setupRouter(Config{OsrmBaseURL: mockOsrmApi.URL})
+	rec := mockGetRoutesRequest("/routes?src=13.388860,52.517037&dst=13.397634,52.529407")
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, int32(3), atomic.LoadInt32(&calls))
+	assert.Contains(t, rec.Body.String(), `"routes":[{"destination":"13.397634,52.529407","duration":2490.1,"distance":3286.3}]`)
+}
+
+func TestGetRoutesReportsTruncatedResponsesOnceRetriesAreExhausted(t *testing.T) {
+	var calls int32
+	mockOsrmApi := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"code":"Ok", "routes": [{"duration":2490.1,`))
+	}))
+	defer mockOsrmApi.Close()
+
+	router = setupRouter(Config{OsrmBaseURL: mockOsrmApi.URL})
+	truncatedRetries = 1
+	defer func() { truncatedRetries = 2 }()
+
+	rec := mockGetRoutesRequest("/routes?src=13.388860,52.517037&dst=13.397634,52.529407")
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, int32(2), atomic.LoadInt32(&calls))
+	assert.Contains(t, rec.Body.String(), `"failures":[{"destination":"13.397634,52.529407","status":502,"category":"backend_error",`+
+		`"message":"truncated OSRM response: unexpected end of JSON input"}]`)
+}
+
+func TestGetRoutesDoesNotRetryMalformedResponses(t *testing.T) {
+	var calls int32
+	mockOsrmApi := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"code":"Ok", "routes": oops}`))
+	}))
+	defer mockOsrmApi.Close()
+
+	router = setupRouter(Config{OsrmBaseURL: mockOsrmApi.URL})
+	rec := mockGetRoutesRequest("/routes?src=13.388860,52.517037&dst=13.397634,52.529407")
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&calls))
+	assert.Contains(t, rec.Body.String(), `"category":"backend_error"`)
+}
+
+func TestGetRoutesReportsExhausted429Retries(t *testing.T) {
+	var calls int32
+	mockOsrmApi := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer mockOsrmApi.Close()
+
+	cfg := Config{OsrmBaseURL: mockOsrmApi.URL, RetryAttempts: 3, RetryBackoff: time.Millisecond}
+	_, _, err := makeRequestWith429Retries(context.Background(), cfg, mockOsrmApi.URL)
+	assert.ErrorIs(t, err, errRetriesExhausted)
+	assert.Equal(t, int32(3), atomic.LoadInt32(&calls))
+
+	router = setupRouter(cfg)
+	rec := mockGetRoutesRequest("/routes?src=13.388860,52.517037&dst=13.397634,52.529407")
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"failures":[{"destination":"13.397634,52.529407","status":502,"category":"backend_error",`+
+		`"message":"OSRM retries exhausted: 3 attempts answered with 429"}]`)
+}
+
+func TestGetRoutesReportsOkResponsesWithoutRoutes(t *testing.T) {
+	mockOsrmApi := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"code":"Ok", "routes": []}`))
+	}))
+	defer mockOsrmApi.Close()
+
+	router = setupRouter(Config{OsrmBaseURL: mockOsrmApi.URL})
+	rec := mockGetRoutesRequest("/routes?src=13.388860,52.517037&dst=13.397634,52.529407")
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, `{"source":"13.388860,52.517037","routes":[],"warnings":["No routes found"],"failures":[{"destination":"13.397634,52.529407",`+
+		`"status":422,"category":"no_route","message":"response code: 200. message: no routes returned"}]}`, rec.Body.String())
+}
+
+func TestGetRoutesReportsInvalidValueWithParameters(t *testing.T) {
+	mockOsrmApi := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"code":"InvalidValue", "message": "Bearing must be between 0 and 360"}`))
+	}))
+	defer mockOsrmApi.Close()
+
+	router = setupRouter(Config{OsrmBaseURL: mockOsrmApi.URL})
+	rec := mockGetRoutesRequest("/routes?src=13.388860,52.517037&dst=13.397634,52.529407&turns=true")
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"failures":[{"destination":"13.397634,52.529407","status":400,"category":"invalid_value",`+
+		`"message":"response code: 400. message: Bearing must be between 0 and 360",`+
+		`"parameters":["overview=false","steps=true"]}]`)
+}
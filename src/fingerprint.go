@@ -0,0 +1,39 @@
+package main
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"regexp"
+
+	"github.com/gin-gonic/gin"
+)
+
+const requestIDHeader = "X-Request-ID"
+
+// Request IDs sent by clients end up in the logs, so only short plain ones are taken over
+var requestIDPattern = regexp.MustCompile(`^[A-Za-z0-9._-]{1,64}$`)
+
+// requestID returns the request ID the client sent, or a random one
+func requestID(c *gin.Context) string {
+	if id := c.GetHeader(requestIDHeader); requestIDPattern.MatchString(id) {
+		return id
+	}
+
+	b := make([]byte, 8)
+	rand.Read(b)
+
+	return hex.EncodeToString(b)
+}
+
+// requestFingerprint combines the request ID with a hash of the query, so a fingerprint quoted in
+// a support ticket leads to the log lines of the request as well as to its exact inputs
+func requestFingerprint(requestID string, query QueryParams) string {
+	// Asking for the fingerprint doesn't change it
+	query.Fingerprint = false
+	b, _ := json.Marshal(query)
+	sum := sha256.Sum256(b)
+
+	return requestID + "-" + hex.EncodeToString(sum[:8])
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func mockFingerprintRequest(url string, id string) (string, string) {
+	var logs bytes.Buffer
+	log.SetOutput(&logs)
+	defer log.SetOutput(os.Stderr)
+
+	rec := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodGet, url, nil)
+	if id != "" {
+		req.Header.Set(requestIDHeader, id)
+	}
+	router.ServeHTTP(rec, req)
+
+	var resp GetRoutesResp
+	json.Unmarshal(rec.Body.Bytes(), &resp)
+	if resp.Metadata == nil {
+		return "", logs.String()
+	}
+
+	return resp.Metadata.Fingerprint, logs.String()
+}
+
+func TestGetRoutesReturnsFingerprintMatchingLogs(t *testing.T) {
+	mockOsrmApi := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"code":"Ok", "routes": [{"duration":2490.1,"distance":3286.3}]}`))
+	}))
+	defer mockOsrmApi.Close()
+
+	router = setupRouter(Config{OsrmBaseURL: mockOsrmApi.URL})
+	url := "/routes?src=13.388860,52.517037&dst=13.397634,52.529407&fingerprint=true"
+
+	fingerprint, logs := mockFingerprintRequest(url, "ticket-42")
+	assert.True(t, strings.HasPrefix(fingerprint, "ticket-42-"), fingerprint)
+	assert.Contains(t, logs, "fingerprint="+fingerprint+" ")
+
+	// Identical inputs hash the same, whichever request they come with
+	other, logs := mockFingerprintRequest(url, "")
+	assert.NotEqual(t, fingerprint, other)
+	assert.Equal(t, fingerprint[strings.LastIndex(fingerprint, "-"):], other[strings.LastIndex(other, "-"):])
+	assert.Contains(t, logs, "fingerprint="+other+" ")
+
+	// Without asking for it the fingerprint is only logged
+	fingerprint, logs = mockFingerprintRequest("/routes?src=13.388860,52.517037&dst=13.397634,52.529407", "ticket-42")
+	assert.Empty(t, fingerprint)
+	assert.Contains(t, logs, "fingerprint=ticket-42-"+other[strings.LastIndex(other, "-")+1:]+" ")
+}
+
+func TestRequestFingerprintDependsOnInputs(t *testing.T) {
+	query := QueryParams{Src: "13.388860,52.517037", Dst: []string{"13.397634,52.529407"}}
+	fingerprint := requestFingerprint("a", query)
+
+	assert.Equal(t, fingerprint, requestFingerprint("a", query))
+
+	query.Fingerprint = true
+	assert.Equal(t, fingerprint, requestFingerprint("a", query))
+
+	query.Dst = []string{"12.428555,52.523219"}
+	assert.NotEqual(t, fingerprint, requestFingerprint("a", query))
+}
@@ -0,0 +1,23 @@
+package main
+
+// FlatRoute is a route with its source, so the routes can be a single array of rows
+type FlatRoute struct {
+	Source string `json:"source"`
+	Route
+}
+
+// FlatRoutesResp is a GetRoutesResp with its routes as rows that repeat the source
+type FlatRoutesResp struct {
+	RoutesEnvelope
+	Rows []FlatRoute `json:"rows"`
+}
+
+// flatten returns one row per route next to the rest of the response
+func (o *GetRoutesResp) flatten() FlatRoutesResp {
+	rows := make([]FlatRoute, 0, len(o.Routes))
+	for _, route := range o.Routes {
+		rows = append(rows, FlatRoute{Source: o.Source, Route: route})
+	}
+
+	return FlatRoutesResp{RoutesEnvelope: o.envelope(), Rows: rows}
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetRoutesReturnsFlatRows(t *testing.T) {
+	mockOsrmApi := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		if strings.Contains(r.URL.Path, "13.397634,52.529407") {
+			w.Write([]byte(`{"code":"Ok", "routes": [{"duration":2490.1,"distance":3286.3}]}`))
+			return
+		}
+		w.Write([]byte(`{"code":"Ok", "routes": [{"duration":260.1,"distance":1886.3}]}`))
+	}))
+	defer mockOsrmApi.Close()
+
+	router = setupRouter(Config{OsrmBaseURL: mockOsrmApi.URL})
+	rec := mockGetRoutesRequest("/routes?src=13.388860,52.517037&dst=13.397634,52.529407&dst=13.428555,52.523219&flat=true&naming=snake")
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, `{"source":"13.388860,52.517037","metadata":{"detour_ratio":1.21},"rows":[`+
+		`{"source":"13.388860,52.517037","destination":"13.428555,52.523219","duration":260.1,"distance":1886.3},`+
+		`{"source":"13.388860,52.517037","destination":"13.397634,52.529407","duration":2490.1,"distance":3286.3}]}`,
+		rec.Body.String())
+}
+
+func TestFlattenWithoutRoutes(t *testing.T) {
+	resp := GetRoutesResp{Source: "13.388860,52.517037"}
+
+	assert.Equal(t, []FlatRoute{}, resp.flatten().Rows)
+}
+
+func TestGetRoutesFlatRowsKeepTheEnvelope(t *testing.T) {
+	mockOsrmApi := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"code":"Ok", "routes": [{"duration":260.1,"distance":1886.3}]}`))
+	}))
+	defer mockOsrmApi.Close()
+
+	router = setupRouter(Config{OsrmBaseURL: mockOsrmApi.URL})
+	for _, shape := range []string{"flat=true", "keyed=true", "groupByGrid=1"} {
+		rec := mockGetRoutesRequest("/routes?src=13.388860,52.517037&dst=13.397634,52.529407&dst=13.428555,52.523219&limit=1&echo=true&" + shape)
+
+		assert.Equal(t, http.StatusOK, rec.Code, shape)
+		assert.Contains(t, rec.Body.String(), `"nextCursor":"`, shape)
+		assert.Contains(t, rec.Body.String(), `"request":{`, shape)
+	}
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+const earthRadiusMeters = 6371008.8
+
+var (
+	// Separators accepted in place of the comma between the two values of a coordinate
+	coordinateSeparators = " ;"
+
+	// Destinations given as a minLng,minLat,maxLng,maxLat bounding box are routed to its center unless this is unset
+	bboxDestinations = true
+)
+
+// Coordinate is a point in the order OSRM expects it: longitude first, then latitude
+type Coordinate struct {
+	Lng float64
+	Lat float64
+}
+
+// parseCoordinate parses a validated coordinate string such as 13.388860,52.517037
+func parseCoordinate(s string) (Coordinate, error) {
+	parts := strings.Split(s, ",")
+	if len(parts) != 2 {
+		return Coordinate{}, fmt.Errorf("invalid coordinate: %s", s)
+	}
+
+	lng, err := strconv.ParseFloat(parts[0], 64)
+	if err != nil {
+		return Coordinate{}, err
+	}
+
+	lat, err := strconv.ParseFloat(parts[1], 64)
+	if err != nil {
+		return Coordinate{}, err
+	}
+
+	return Coordinate{Lng: lng, Lat: lat}, nil
+}
+
+// normalizeCoordinate rewrites a coordinate such as "13.388860 52.517037" or "13.388860;52.517037"
+// to the canonical comma form. Anything else is returned as is and left to validation.
+func normalizeCoordinate(s string) string {
+	s = strings.TrimSpace(s)
+	if strings.Contains(s, ",") {
+		return s
+	}
+
+	for _, sep := range coordinateSeparators {
+		var parts []string
+		if unicode.IsSpace(sep) {
+			parts = strings.Fields(s)
+		} else {
+			parts = strings.Split(s, string(sep))
+		}
+
+		if len(parts) == 2 {
+			return strings.TrimSpace(parts[0]) + "," + strings.TrimSpace(parts[1])
+		}
+	}
+
+	return s
+}
+
+// normalizeCoordinates normalizes every coordinate of the slice in place
+func normalizeCoordinates(coordinates []string) {
+	for i := range coordinates {
+		coordinates[i] = normalizeCoordinate(coordinates[i])
+	}
+}
+
+// uniqueCoordinates drops every repetition of a coordinate, keeping the first one in place. The
+// coordinates are compared as strings, so they should be normalized first.
+func uniqueCoordinates(coordinates []string) []string {
+	seen := make(map[string]bool, len(coordinates))
+	unique := make([]string, 0, len(coordinates))
+	for _, c := range coordinates {
+		if !seen[c] {
+			seen[c] = true
+			unique = append(unique, c)
+		}
+	}
+
+	return unique
+}
+
+// bboxCenter returns the center of a minLng,minLat,maxLng,maxLat bounding box as a coordinate.
+// ok is false when s isn't a bounding box, err is set when it is one but degenerate or out of range.
+func bboxCenter(s string) (center string, ok bool, err error) {
+	parts := strings.Split(s, ",")
+	if len(parts) != 4 {
+		return "", false, nil
+	}
+
+	var v [4]float64
+	for i, part := range parts {
+		if v[i], err = strconv.ParseFloat(strings.TrimSpace(part), 64); err != nil {
+			return "", true, fmt.Errorf("invalid bounding box: %s", s)
+		}
+	}
+
+	minLng, minLat, maxLng, maxLat := v[0], v[1], v[2], v[3]
+	if minLng < -180 || maxLng > 180 || minLat < -90 || maxLat > 90 {
+		return "", true, fmt.Errorf("bounding box out of range: %s", s)
+	}
+	if minLng >= maxLng || minLat >= maxLat {
+		return "", true, fmt.Errorf("degenerate bounding box: %s", s)
+	}
+
+	// Rounded to the precision OSRM works with
+	scale := math.Pow10(maxCoordinatePrecision)
+	lng := strconv.FormatFloat(math.Round((minLng+maxLng)/2*scale)/scale, 'f', -1, 64)
+	lat := strconv.FormatFloat(math.Round((minLat+maxLat)/2*scale)/scale, 'f', -1, 64)
+
+	return lng + "," + lat, true, nil
+}
+
+// replaceBBoxes replaces every bounding box among the destinations with its center
+func replaceBBoxes(dsts []string) error {
+	for i, dst := range dsts {
+		center, ok, err := bboxCenter(dst)
+		if err != nil {
+			return err
+		}
+		if ok {
+			dsts[i] = center
+		}
+	}
+
+	return nil
+}
+
+// haversineDistance returns the great-circle distance between two coordinates in meters
+func haversineDistance(a Coordinate, b Coordinate) float64 {
+	lat1 := a.Lat * math.Pi / 180
+	lat2 := b.Lat * math.Pi / 180
+	dLat := (b.Lat - a.Lat) * math.Pi / 180
+	dLng := (b.Lng - a.Lng) * math.Pi / 180
+
+	h := math.Sin(dLat/2)*math.Sin(dLat/2) + math.Cos(lat1)*math.Cos(lat2)*math.Sin(dLng/2)*math.Sin(dLng/2)
+
+	return 2 * earthRadiusMeters * math.Asin(math.Sqrt(h))
+}
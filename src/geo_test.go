@@ -0,0 +1,77 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseCoordinate(t *testing.T) {
+	c, err := parseCoordinate("13.388860,52.517037")
+
+	assert.Nil(t, err)
+	assert.Equal(t, Coordinate{Lng: 13.388860, Lat: 52.517037}, c)
+}
+
+func TestParseCoordinateReturnsErrorWhenInvalid(t *testing.T) {
+	_, err := parseCoordinate("13.388860")
+
+	assert.NotNil(t, err)
+}
+
+func TestHaversineDistance(t *testing.T) {
+	berlin := Coordinate{Lng: 13.388860, Lat: 52.517037}
+	hamburg := Coordinate{Lng: 9.993682, Lat: 53.551085}
+
+	assert.InDelta(t, 254400, haversineDistance(berlin, hamburg), 500)
+	assert.Equal(t, 0.0, haversineDistance(berlin, berlin))
+}
+
+func TestNormalizeCoordinate(t *testing.T) {
+	assert.Equal(t, "13.388860,52.517037", normalizeCoordinate("13.388860,52.517037"))
+	assert.Equal(t, "13.388860,52.517037", normalizeCoordinate("13.388860 52.517037"))
+	assert.Equal(t, "13.388860,52.517037", normalizeCoordinate(" 13.388860   52.517037 "))
+	assert.Equal(t, "13.388860,52.517037", normalizeCoordinate("13.388860;52.517037"))
+	assert.Equal(t, "13.388860,52.517037", normalizeCoordinate("13.388860 ; 52.517037"))
+	assert.Equal(t, "13.388860", normalizeCoordinate("13.388860"))
+}
+
+func TestNormalizeCoordinateOnlyAcceptsConfiguredSeparators(t *testing.T) {
+	original := coordinateSeparators
+	coordinateSeparators = ";"
+	defer func() { coordinateSeparators = original }()
+
+	assert.Equal(t, "13.388860 52.517037", normalizeCoordinate("13.388860 52.517037"))
+	assert.Equal(t, "13.388860,52.517037", normalizeCoordinate("13.388860;52.517037"))
+}
+
+func TestUniqueCoordinates(t *testing.T) {
+	assert.Equal(t, []string{"13.397634,52.529407", "13.428555,52.523219", "13.388860,52.517037"},
+		uniqueCoordinates([]string{"13.397634,52.529407", "13.428555,52.523219", "13.397634,52.529407", "13.388860,52.517037", "13.428555,52.523219"}))
+	assert.Empty(t, uniqueCoordinates(nil))
+}
+
+func TestBBoxCenter(t *testing.T) {
+	center, ok, err := bboxCenter("13.38,52.51,13.40,52.53")
+	assert.Nil(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, "13.39,52.52", center)
+
+	_, ok, err = bboxCenter("13.388860,52.517037")
+	assert.Nil(t, err)
+	assert.False(t, ok)
+}
+
+func TestBBoxCenterRejectsDegenerateBBoxes(t *testing.T) {
+	for _, bbox := range []string{
+		"13.38,52.51,13.38,52.53",
+		"13.38,52.51,13.40,52.51",
+		"13.40,52.51,13.38,52.53",
+		"13.38,52.51,181,52.53",
+		"13.38,52.51,13.40,north",
+	} {
+		_, ok, err := bboxCenter(bbox)
+		assert.True(t, ok, bbox)
+		assert.NotNil(t, err, bbox)
+	}
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// Places are renamed or moved rarely, so resolved addresses are kept for long
+const (
+	defaultGeocoderCacheSize = 10000
+	defaultGeocoderCacheTTL  = 24 * time.Hour
+)
+
+// Resolves the addresses of geocode=true, nil when no geocoder is configured
+var geocoder Geocoder
+
+// Geocoder resolves an address, such as "Brandenburg Gate, Berlin", to a coordinate
+type Geocoder interface {
+	Geocode(ctx context.Context, address string) (Coordinate, error)
+}
+
+// HTTPGeocoder asks a Nominatim compatible service for the coordinate of an address. URL takes the
+// query escaped address and the service answers with a list of places, the first one is used.
+type HTTPGeocoder struct {
+	URL string
+}
+
+func (g *HTTPGeocoder) Geocode(ctx context.Context, address string) (Coordinate, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, fmt.Sprintf(g.URL, url.QueryEscape(address)), nil)
+	if err != nil {
+		return Coordinate{}, err
+	}
+	// Nominatim's usage policy asks every application to identify itself
+	req.Header.Set("User-Agent", "twiking-routes")
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return Coordinate{}, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return Coordinate{}, fmt.Errorf("geocoder response code: %d", resp.StatusCode)
+	}
+
+	var places []struct {
+		Lat string `json:"lat"`
+		Lon string `json:"lon"`
+	}
+	if err = json.NewDecoder(resp.Body).Decode(&places); err != nil {
+		return Coordinate{}, err
+	}
+	if len(places) == 0 {
+		return Coordinate{}, fmt.Errorf("no place found for %q", address)
+	}
+
+	lat, err := strconv.ParseFloat(places[0].Lat, 64)
+	if err != nil {
+		return Coordinate{}, err
+	}
+	lng, err := strconv.ParseFloat(places[0].Lon, 64)
+	if err != nil {
+		return Coordinate{}, err
+	}
+
+	return Coordinate{Lng: lng, Lat: lat}, nil
+}
+
+// CachedGeocoder remembers up to size addresses it resolved for ttl, so repeated addresses cost a
+// single lookup. The least recently used address is evicted when it is full.
+type CachedGeocoder struct {
+	geocoder Geocoder
+	places   *lruCache[string, Coordinate]
+}
+
+func newCachedGeocoder(geocoder Geocoder, size int, ttl time.Duration) *CachedGeocoder {
+	return &CachedGeocoder{geocoder: geocoder, places: newLRUCache[string, Coordinate](size, ttl)}
+}
+
+func (g *CachedGeocoder) Geocode(ctx context.Context, address string) (Coordinate, error) {
+	if place, ok := g.places.get(address); ok {
+		return place, nil
+	}
+
+	place, err := g.geocoder.Geocode(ctx, address)
+	if err != nil {
+		return Coordinate{}, err
+	}
+	g.places.put(address, place)
+
+	return place, nil
+}
+
+// geocodeAddresses replaces every value that is neither a coordinate nor a bounding box with the
+// coordinate of the address it holds, recording what each address was resolved to in geocoded
+func geocodeAddresses(ctx context.Context, values []string, geocoded map[string]string) error {
+	for i, value := range values {
+		normalized := normalizeCoordinate(value)
+		if _, isBBox, _ := bboxCenter(normalized); isBBox || latLngPattern.MatchString(normalized) {
+			continue
+		}
+
+		place, err := geocoder.Geocode(ctx, value)
+		if err != nil {
+			return err
+		}
+		if place.Lat < -90 || place.Lat > 90 || place.Lng < -180 || place.Lng > 180 {
+			return fmt.Errorf("%q was resolved to a coordinate out of range", value)
+		}
+
+		// Rounded to the precision OSRM works with
+		scale := math.Pow10(maxCoordinatePrecision)
+		values[i] = strconv.FormatFloat(math.Round(place.Lng*scale)/scale, 'f', -1, 64) + "," +
+			strconv.FormatFloat(math.Round(place.Lat*scale)/scale, 'f', -1, 64)
+		geocoded[value] = values[i]
+	}
+
+	return nil
+}
+
+// sortedKeys returns the keys of m in ascending order
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	return keys
+}
@@ -0,0 +1,149 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func mockGeocoderApi(lookups *int32) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(lookups, 1)
+		switch r.URL.Query().Get("q") {
+		case "Alexanderplatz, Berlin":
+			w.Write([]byte(`[{"lat":"52.5217749","lon":"13.4105257"}]`))
+		case "Berlin Hauptbahnhof":
+			w.Write([]byte(`[{"lat":"52.5250839","lon":"13.369402"}]`))
+		case "Atlantis":
+			w.Write([]byte(`[{"lat":"95.0","lon":"13.4105257"}]`))
+		default:
+			w.Write([]byte(`[]`))
+		}
+	}))
+}
+
+func TestCachedGeocoder(t *testing.T) {
+	var lookups int32
+	mockApi := mockGeocoderApi(&lookups)
+	defer mockApi.Close()
+
+	geocoder := newCachedGeocoder(&HTTPGeocoder{URL: mockApi.URL + "/search?format=json&q=%s"}, 100, time.Minute)
+
+	for i := 0; i < 3; i++ {
+		place, err := geocoder.Geocode(context.Background(), "Alexanderplatz, Berlin")
+		assert.Nil(t, err)
+		assert.Equal(t, Coordinate{Lng: 13.4105257, Lat: 52.5217749}, place)
+	}
+	assert.Equal(t, int32(1), atomic.LoadInt32(&lookups))
+
+	_, err := geocoder.Geocode(context.Background(), "Nowhere")
+	assert.EqualError(t, err, `no place found for "Nowhere"`)
+}
+
+func TestCachedGeocoderEvictsLeastRecentlyUsed(t *testing.T) {
+	var lookups int32
+	mockApi := mockGeocoderApi(&lookups)
+	defer mockApi.Close()
+
+	geocoder := newCachedGeocoder(&HTTPGeocoder{URL: mockApi.URL + "/search?format=json&q=%s"}, 1, time.Minute)
+
+	for _, address := range []string{"Alexanderplatz, Berlin", "Berlin Hauptbahnhof", "Alexanderplatz, Berlin"} {
+		_, err := geocoder.Geocode(context.Background(), address)
+		assert.Nil(t, err)
+	}
+	assert.Equal(t, int32(3), atomic.LoadInt32(&lookups))
+	assert.Equal(t, 1, geocoder.places.order.Len())
+}
+
+func TestCachedGeocoderStopsWhenContextIsDone(t *testing.T) {
+	var lookups int32
+	mockApi := mockGeocoderApi(&lookups)
+	defer mockApi.Close()
+
+	geocoder := newCachedGeocoder(&HTTPGeocoder{URL: mockApi.URL + "/search?format=json&q=%s"}, 100, time.Minute)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := geocoder.Geocode(ctx, "Alexanderplatz, Berlin")
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Equal(t, int32(0), atomic.LoadInt32(&lookups))
+}
+
+func TestGetRoutesGeocodesAddresses(t *testing.T) {
+	var lookups int32
+	mockApi := mockGeocoderApi(&lookups)
+	defer mockApi.Close()
+
+	var osrmPath string
+	mockOsrmApi := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		osrmPath = r.URL.Path
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"code":"Ok", "routes": [{"duration":2490.1,"distance":3286.3}]}`))
+	}))
+	defer mockOsrmApi.Close()
+
+	router = setupRouter(Config{OsrmBaseURL: mockOsrmApi.URL})
+	geocoder = newCachedGeocoder(&HTTPGeocoder{URL: mockApi.URL + "/search?format=json&q=%s"}, 100, time.Minute)
+	defer func() { geocoder = nil }()
+
+	rec := mockGetRoutesRequest("/routes?src=Berlin+Hauptbahnhof&dst=Alexanderplatz,+Berlin&geocode=true")
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "/route/v1/driving/13.369402,52.525084;13.410526,52.521775", osrmPath)
+	assert.Equal(t, `{"source":"13.369402,52.525084","routes":[`+
+		`{"destination":"13.410526,52.521775","duration":2490.1,"distance":3286.3}],"metadata":{"detourRatio":1.17},`+
+		`"geocoded":{"Alexanderplatz, Berlin":"13.410526,52.521775","Berlin Hauptbahnhof":"13.369402,52.525084"}}`,
+		rec.Body.String())
+
+	// Coordinates are left as they are
+	rec = mockGetRoutesRequest("/routes?src=13.388860,52.517037&dst=Alexanderplatz,+Berlin&geocode=true")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "/route/v1/driving/13.388860,52.517037;13.410526,52.521775", osrmPath)
+	assert.Equal(t, int32(2), atomic.LoadInt32(&lookups))
+
+	rec = mockGetRoutesRequest("/routes?src=13.388860,52.517037&dst=Nowhere&geocode=true")
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Equal(t, `{"code":400,"message":"Dst could not be geocoded: no place found for \"Nowhere\"","error_code":"invalid_coordinate"}`, rec.Body.String())
+}
+
+func TestGetRoutesReturns400WhenSourceIsGeocodedOutOfRange(t *testing.T) {
+	var lookups int32
+	mockApi := mockGeocoderApi(&lookups)
+	defer mockApi.Close()
+
+	var osrmCalls int32
+	mockOsrmApi := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&osrmCalls, 1)
+	}))
+	defer mockOsrmApi.Close()
+
+	router = setupRouter(Config{OsrmBaseURL: mockOsrmApi.URL})
+	geocoder = newCachedGeocoder(&HTTPGeocoder{URL: mockApi.URL + "/search?format=json&q=%s"}, 100, time.Minute)
+	defer func() { geocoder = nil }()
+
+	rec := mockGetRoutesRequest("/routes?src=Atlantis&dst=13.397634,52.529407&geocode=true")
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Equal(t, `{"code":400,"message":"Src could not be geocoded: \"Atlantis\" was resolved to a coordinate out of range","error_code":"invalid_coordinate"}`, rec.Body.String())
+
+	// Whitespace never reaches the geocoder
+	rec = mockGetRoutesRequest("/routes?src=%20%20&dst=13.397634,52.529407&geocode=true")
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Equal(t, `{"code":400,"message":"Src is empty once normalized","error_code":"invalid_coordinate"}`, rec.Body.String())
+
+	assert.Equal(t, int32(1), atomic.LoadInt32(&lookups))
+	assert.Equal(t, int32(0), atomic.LoadInt32(&osrmCalls))
+}
+
+func TestGetRoutesReturns400WhenGeocodingIsNotConfigured(t *testing.T) {
+	router = setupRouter(Config{})
+
+	rec := mockGetRoutesRequest("/routes?src=13.388860,52.517037&dst=Alexanderplatz,+Berlin&geocode=true")
+
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Equal(t, `{"code":400,"message":"Geocoding is not available on this server","error_code":"invalid_parameter"}`, rec.Body.String())
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+const geoJSONContentType = "application/geo+json"
+
+type geoJSONFeatureCollection struct {
+	Type     string           `json:"type"`
+	Features []geoJSONFeature `json:"features"`
+}
+
+type geoJSONFeature struct {
+	Type       string            `json:"type"`
+	Geometry   geoJSONGeometry   `json:"geometry"`
+	Properties geoJSONProperties `json:"properties"`
+}
+
+type geoJSONGeometry struct {
+	Type string `json:"type"`
+
+	// A [lng, lat] position for a Point, a list of them for a LineString
+	Coordinates any `json:"coordinates"`
+}
+
+type geoJSONProperties struct {
+	Destination string  `json:"destination"`
+	Duration    float64 `json:"duration"`
+	Distance    float64 `json:"distance"`
+}
+
+func acceptsGeoJSON(c *gin.Context) bool {
+	return strings.Contains(c.GetHeader("Accept"), geoJSONContentType)
+}
+
+// marshalGeoJSON renders the routes as a GeoJSON FeatureCollection with a feature per destination. The
+// feature is the route as a LineString when its geometry was requested and the destination as a Point otherwise.
+func (o *GetRoutesResp) marshalGeoJSON() ([]byte, error) {
+	collection := geoJSONFeatureCollection{Type: "FeatureCollection", Features: make([]geoJSONFeature, 0, len(o.Routes))}
+
+	for _, route := range o.Routes {
+		var geometry geoJSONGeometry
+		if len(route.Geometry) > 0 {
+			geometry = geoJSONGeometry{Type: "LineString", Coordinates: route.Geometry}
+		} else {
+			// Destinations have been validated, they always parse
+			destination, _ := parseCoordinate(route.Destination)
+			geometry = geoJSONGeometry{Type: "Point", Coordinates: [2]float64{destination.Lng, destination.Lat}}
+		}
+
+		collection.Features = append(collection.Features, geoJSONFeature{
+			Type:     "Feature",
+			Geometry: geometry,
+			Properties: geoJSONProperties{
+				Destination: route.Destination,
+				Duration:    route.Duration,
+				Distance:    route.Distance,
+			},
+		})
+	}
+
+	return json.Marshal(collection)
+}
+
+func writeGeoJSON(c *gin.Context, resp GetRoutesResp) {
+	body, err := resp.marshalGeoJSON()
+	if err != nil {
+		writeErrResp(c, ErrResp{
+			Code:      http.StatusInternalServerError,
+			Message:   "Could not encode response",
+			ErrorCode: errCodeInternal,
+		})
+		return
+	}
+
+	c.Data(http.StatusOK, geoJSONContentType, body)
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetRoutesReturnsGeoJSON(t *testing.T) {
+	osrmApiPath := "/route/v1/driving/%s;%s"
+	src := "13.388860,52.517037"
+	dst1 := "13.397634,52.529407"
+	dst2 := "12.428555,52.523219"
+
+	mockOsrmApi := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		if r.URL.Path == fmt.Sprintf(osrmApiPath, src, dst1) {
+			w.Write([]byte(`{"code":"Ok", "routes": [{"duration":2490.1,"distance":3286.3}]}`))
+			return
+		}
+		w.Write([]byte(`{"code":"Ok", "routes": [{"duration":260.1,"distance":1886.3}]}`))
+	}))
+	defer mockOsrmApi.Close()
+
+	router = setupRouter(Config{OsrmBaseURL: mockOsrmApi.URL})
+	expectedResp := `{"type":"FeatureCollection","features":[` +
+		`{"type":"Feature","geometry":{"type":"Point","coordinates":[12.428555,52.523219]},"properties":{"destination":"12.428555,52.523219","duration":260.1,"distance":1886.3}},` +
+		`{"type":"Feature","geometry":{"type":"Point","coordinates":[13.397634,52.529407]},"properties":{"destination":"13.397634,52.529407","duration":2490.1,"distance":3286.3}}]}`
+
+	rec := mockGetRoutesRequest(fmt.Sprintf("/routes?src=%s&dst=%s&dst=%s&format=geojson", src, dst1, dst2))
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, geoJSONContentType, rec.Header().Get("Content-Type"))
+	assert.Equal(t, expectedResp, rec.Body.String())
+
+	rec = httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodGet, fmt.Sprintf("/routes?src=%s&dst=%s&dst=%s", src, dst1, dst2), nil)
+	req.Header.Set("Accept", "application/geo+json")
+	router.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, geoJSONContentType, rec.Header().Get("Content-Type"))
+	assert.Equal(t, expectedResp, rec.Body.String())
+}
+
+func TestGetRoutesReturnsGeoJSONLineStringsWithGeometry(t *testing.T) {
+	mockOsrmApi := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"code":"Ok", "routes": [{"duration":260.1,"distance":1886.3,"geometry":{"type":"LineString","coordinates":[` +
+			`[13.38886,52.517037],[13.39,52.52],[13.397634,52.529407]]}}]}`))
+	}))
+	defer mockOsrmApi.Close()
+
+	router = setupRouter(Config{OsrmBaseURL: mockOsrmApi.URL})
+	rec := mockGetRoutesRequest("/routes?src=13.388860,52.517037&dst=13.397634,52.529407&format=geojson&geometry=true")
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, `{"type":"FeatureCollection","features":[`+
+		`{"type":"Feature","geometry":{"type":"LineString","coordinates":[[13.38886,52.517037],[13.39,52.52],[13.397634,52.529407]]},`+
+		`"properties":{"destination":"13.397634,52.529407","duration":260.1,"distance":1886.3}}]}`, rec.Body.String())
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"encoding/xml"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+const gpxContentType = "application/gpx+xml"
+
+type gpxDocument struct {
+	XMLName   xml.Name      `xml:"gpx"`
+	Xmlns     string        `xml:"xmlns,attr"`
+	Version   string        `xml:"version,attr"`
+	Creator   string        `xml:"creator,attr"`
+	Waypoints []gpxWaypoint `xml:"wpt"`
+	Tracks    []gpxTrack    `xml:"trk"`
+}
+
+type gpxWaypoint struct {
+	Lat  float64 `xml:"lat,attr"`
+	Lon  float64 `xml:"lon,attr"`
+	Name string  `xml:"name,omitempty"`
+}
+
+type gpxTrack struct {
+	Name     string       `xml:"name"`
+	Segments []gpxSegment `xml:"trkseg"`
+}
+
+type gpxSegment struct {
+	Points []gpxWaypoint `xml:"trkpt"`
+}
+
+// marshalGPX renders a waypoint for the source and each destination and a track per route geometry
+func (o *GetRoutesResp) marshalGPX() ([]byte, error) {
+	doc := gpxDocument{
+		Xmlns:   "http://www.topografix.com/GPX/1/1",
+		Version: "1.1",
+		Creator: "routes",
+	}
+
+	if src, err := parseCoordinate(o.Source); err == nil {
+		doc.Waypoints = append(doc.Waypoints, gpxWaypoint{Lat: src.Lat, Lon: src.Lng, Name: o.Source})
+	}
+
+	for _, route := range o.Routes {
+		if dst, err := parseCoordinate(route.Destination); err == nil {
+			doc.Waypoints = append(doc.Waypoints, gpxWaypoint{Lat: dst.Lat, Lon: dst.Lng, Name: route.Destination})
+		}
+
+		// A track can only be drawn when OSRM returned the geometry of the route
+		if len(route.geometry) == 0 {
+			continue
+		}
+
+		var segment gpxSegment
+		for _, point := range route.geometry {
+			segment.Points = append(segment.Points, gpxWaypoint{Lat: point.Lat, Lon: point.Lng})
+		}
+		doc.Tracks = append(doc.Tracks, gpxTrack{Name: route.Destination, Segments: []gpxSegment{segment}})
+	}
+
+	body, err := xml.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+
+	return append([]byte(xml.Header), body...), nil
+}
+
+func writeGPX(c *gin.Context, resp GetRoutesResp) {
+	body, err := resp.marshalGPX()
+	if err != nil {
+		writeErrResp(c, ErrResp{
+			Code:      http.StatusInternalServerError,
+			Message:   "Could not encode response",
+			ErrorCode: errCodeInternal,
+		})
+		return
+	}
+
+	c.Data(http.StatusOK, gpxContentType, body)
+}
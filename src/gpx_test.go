@@ -0,0 +1,63 @@
+package main
+
+import (
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetRoutesReturnsGPX(t *testing.T) {
+	src := "13.388860,52.517037"
+	dst := "13.397634,52.529407"
+
+	var query string
+	mockOsrmApi := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query = r.URL.RawQuery
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"code":"Ok", "routes": [{"duration":260.1,"distance":1886.3,` +
+			`"geometry":{"type":"LineString","coordinates":[[13.38886,52.517037],[13.39,52.52],[13.397634,52.529407]]}}]}`))
+	}))
+	defer mockOsrmApi.Close()
+
+	router = setupRouter(Config{OsrmBaseURL: mockOsrmApi.URL})
+	rec := mockGetRoutesRequest(fmt.Sprintf("/routes?src=%s&dst=%s&format=gpx", src, dst))
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, gpxContentType, rec.Header().Get("Content-Type"))
+	assert.Equal(t, "geometries=geojson&overview=full", query)
+
+	var doc gpxDocument
+	assert.Nil(t, xml.Unmarshal(rec.Body.Bytes(), &doc))
+	assert.Equal(t, "1.1", doc.Version)
+	assert.Equal(t, []gpxWaypoint{
+		{Lat: 52.517037, Lon: 13.38886, Name: src},
+		{Lat: 52.529407, Lon: 13.397634, Name: dst},
+	}, doc.Waypoints)
+	assert.Equal(t, []gpxTrack{{
+		Name: dst,
+		Segments: []gpxSegment{{Points: []gpxWaypoint{
+			{Lat: 52.517037, Lon: 13.38886},
+			{Lat: 52.52, Lon: 13.39},
+			{Lat: 52.529407, Lon: 13.397634},
+		}}},
+	}}, doc.Tracks)
+}
+
+func TestMarshalGPXSkipsTracksWithoutGeometry(t *testing.T) {
+	resp := GetRoutesResp{
+		Source: "13.388860,52.517037",
+		Routes: []Route{{Destination: "13.397634,52.529407", Duration: 260.1, Distance: 1886.3}},
+	}
+
+	body, err := resp.marshalGPX()
+	assert.Nil(t, err)
+
+	var doc gpxDocument
+	assert.Nil(t, xml.Unmarshal(body, &doc))
+	assert.Len(t, doc.Waypoints, 2)
+	assert.Empty(t, doc.Tracks)
+}
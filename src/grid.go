@@ -0,0 +1,40 @@
+package main
+
+import (
+	"math"
+	"strconv"
+)
+
+// GroupedRoutesResp is a GetRoutesResp with its routes nested under the grid cell of their destination
+type GroupedRoutesResp struct {
+	RoutesEnvelope
+	Groups map[string][]Route `json:"groups"`
+}
+
+// groupByGrid nests the routes under the grid cell their destination lies in, keeping their order
+// within each cell. Cells are precision decimal places wide and named after their south-west corner.
+func (o *GetRoutesResp) groupByGrid(precision int) GroupedRoutesResp {
+	groups := make(map[string][]Route)
+	for _, route := range o.Routes {
+		cell := gridCell(route.Destination, precision)
+		groups[cell] = append(groups[cell], route)
+	}
+
+	return GroupedRoutesResp{RoutesEnvelope: o.envelope(), Groups: groups}
+}
+
+// gridCell returns the cell of a "lng,lat" coordinate, or the coordinate itself when it can't be parsed
+func gridCell(coordinate string, precision int) string {
+	c, err := parseCoordinate(coordinate)
+	if err != nil {
+		return coordinate
+	}
+
+	scale := math.Pow10(precision)
+	floor := func(v float64) string {
+		// The epsilon keeps values such as 0.3 in their cell despite 0.3*10 being 2.9999999999999996
+		return strconv.FormatFloat(math.Floor(v*scale+1e-9)/scale, 'f', precision, 64)
+	}
+
+	return floor(c.Lng) + "," + floor(c.Lat)
+}
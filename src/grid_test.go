@@ -0,0 +1,48 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGridCell(t *testing.T) {
+	assert.Equal(t, "13.3,52.5", gridCell("13.397634,52.529407", 1))
+	assert.Equal(t, "0.3,-52.6", gridCell("0.3,-52.529407", 1))
+	assert.Equal(t, "13,52", gridCell("13.397634,52.529407", 0))
+	assert.Equal(t, "13.39,52.52", gridCell("13.397634,52.529407", 2))
+}
+
+func TestGetRoutesGroupsRoutesByGridCell(t *testing.T) {
+	mockOsrmApi := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		switch {
+		case strings.Contains(r.URL.Path, "13.397634,52.529407"):
+			w.Write([]byte(`{"code":"Ok", "routes": [{"duration":2490.1,"distance":3286.3}]}`))
+		case strings.Contains(r.URL.Path, "13.328555,52.523219"):
+			w.Write([]byte(`{"code":"Ok", "routes": [{"duration":260.1,"distance":1886.3}]}`))
+		default:
+			w.Write([]byte(`{"code":"Ok", "routes": [{"duration":860.1,"distance":4886.3}]}`))
+		}
+	}))
+	defer mockOsrmApi.Close()
+
+	router = setupRouter(Config{OsrmBaseURL: mockOsrmApi.URL})
+	rec := mockGetRoutesRequest("/routes?src=13.388860,52.517037&dst=13.397634,52.529407&dst=13.328555,52.523219&dst=13.428555,52.523219&groupByGrid=1")
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, `{"source":"13.388860,52.517037","metadata":{"detourRatio":1.2},"groups":{`+
+		`"13.3,52.5":[{"destination":"13.328555,52.523219","duration":260.1,"distance":1886.3},{"destination":"13.397634,52.529407","duration":2490.1,"distance":3286.3}],`+
+		`"13.4,52.5":[{"destination":"13.428555,52.523219","duration":860.1,"distance":4886.3}]}}`,
+		rec.Body.String())
+}
+
+func TestGetRoutesReturns400WhenGroupByGridIsOutOfRange(t *testing.T) {
+	rec := mockGetRoutesRequest("/routes?src=13.388860,52.517037&dst=13.397634,52.529407&groupByGrid=7")
+
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Equal(t, `{"code":400,"message":"GroupByGrid must be at most 6","error_code":"invalid_parameter"}`, rec.Body.String())
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// healthClient checks the OSRM backend with a timeout short enough that probes never hang
+var healthClient = &http.Client{
+	Timeout: 2 * time.Second,
+}
+
+type HealthResp struct {
+	Status string `json:"status"`
+}
+
+// getHealth reports that the process is up. With upstream=true it also checks that the
+// OSRM backend answers and reports the service as degraded when it doesn't.
+func getHealth(cfg Config) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if upstream, _ := strconv.ParseBool(c.Query("upstream")); upstream {
+			if err := checkOsrm(cfg); err != nil {
+				log.Printf("OSRM health check failed: %s", err)
+				c.JSON(http.StatusServiceUnavailable, HealthResp{Status: "degraded"})
+				return
+			}
+		}
+
+		c.JSON(http.StatusOK, HealthResp{Status: "ok"})
+	}
+}
+
+// checkOsrm requests the OSRM base URL, any answer short of a server error means it is reachable
+func checkOsrm(cfg Config) error {
+	resp, err := healthClient.Get(cfg.OsrmBaseURL)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode >= http.StatusInternalServerError {
+		return fmt.Errorf("response code: %d", resp.StatusCode)
+	}
+
+	return nil
+}
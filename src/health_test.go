@@ -0,0 +1,59 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func mockHealthRequest(cfg Config, url string) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodGet, url, nil)
+	setupRouter(cfg).ServeHTTP(rec, req)
+
+	return rec
+}
+
+func TestHealthReturnsOk(t *testing.T) {
+	rec := mockHealthRequest(Config{OsrmBaseURL: "http://127.0.0.1:0"}, "/health")
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, `{"status":"ok"}`, rec.Body.String())
+}
+
+func TestHealthChecksOsrmWhenRequested(t *testing.T) {
+	status := http.StatusBadRequest
+	mockOsrmApi := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+	}))
+	defer mockOsrmApi.Close()
+
+	cfg := Config{OsrmBaseURL: mockOsrmApi.URL}
+
+	rec := mockHealthRequest(cfg, "/health?upstream=true")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, `{"status":"ok"}`, rec.Body.String())
+
+	status = http.StatusBadGateway
+	rec = mockHealthRequest(cfg, "/health?upstream=true")
+	assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+	assert.Equal(t, `{"status":"degraded"}`, rec.Body.String())
+}
+
+func TestHealthIsDegradedWhenOsrmHangs(t *testing.T) {
+	release := make(chan struct{})
+	mockOsrmApi := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer mockOsrmApi.Close()
+	defer close(release)
+
+	healthClient.Timeout = 50 * time.Millisecond
+	defer func() { healthClient.Timeout = 2 * time.Second }()
+
+	rec := mockHealthRequest(Config{OsrmBaseURL: mockOsrmApi.URL}, "/health?upstream=true")
+	assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+}
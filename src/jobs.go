@@ -0,0 +1,350 @@
+package main
+
+import (
+	"container/list"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// How long a finished or cancelled job can still be polled before it is evicted
+const defaultJobRetention = time.Hour
+
+const (
+	jobPending   = "pending"
+	jobRunning   = "running"
+	jobDone      = "done"
+	jobCancelled = "cancelled"
+)
+
+var (
+	jobStore JobStore = newMemoryJobStore(defaultJobRetention)
+
+	// Number of destinations a single job routes at the same time
+	jobConcurrency = 4
+
+	// Whether a cancelled job keeps the routes it completed before it was cancelled
+	keepCancelledJobResults = false
+
+	runningJobs = &jobCancels{cancels: make(map[string]context.CancelFunc)}
+)
+
+type JobRequest struct {
+	Src         string   `json:"src" binding:"required" validate:"latlng,noedge"`
+	Dst         []string `json:"dst" binding:"required,min=1" validate:"latlng,noedge"`
+	RoadClasses bool     `json:"roadClasses"`
+	CallbackURL string   `json:"callbackUrl" validate:"omitempty,http_url,callback"`
+}
+
+type Job struct {
+	ID        string         `json:"id"`
+	Status    string         `json:"status"`
+	Total     int            `json:"total"`
+	Completed int            `json:"completed"`
+	Result    *GetRoutesResp `json:"result,omitempty"`
+}
+
+// JobStore keeps the state of async jobs so it can be polled
+type JobStore interface {
+	Save(job Job) error
+	Get(id string) (Job, bool, error)
+}
+
+// MemoryJobStore keeps the jobs in memory, evicting them retention after they finished or were cancelled
+type MemoryJobStore struct {
+	mu        sync.Mutex
+	jobs      map[string]Job
+	retention time.Duration
+
+	// Finished jobs by when they expire, oldest first
+	finished *list.List
+
+	now func() time.Time
+}
+
+type finishedJob struct {
+	id      string
+	expires time.Time
+}
+
+func newMemoryJobStore(retention time.Duration) *MemoryJobStore {
+	return &MemoryJobStore{
+		jobs:      make(map[string]Job),
+		retention: retention,
+		finished:  list.New(),
+		now:       time.Now,
+	}
+}
+
+func (s *MemoryJobStore) Save(job Job) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.evictExpired()
+
+	s.jobs[job.ID] = job
+	if job.Status == jobDone || job.Status == jobCancelled {
+		s.finished.PushBack(finishedJob{id: job.ID, expires: s.now().Add(s.retention)})
+	}
+
+	return nil
+}
+
+func (s *MemoryJobStore) Get(id string) (Job, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.evictExpired()
+
+	job, ok := s.jobs[id]
+	return job, ok, nil
+}
+
+// evictExpired drops the finished jobs whose retention is over. The retention is the same for
+// every job, so they expire in the order they finished.
+func (s *MemoryJobStore) evictExpired() {
+	now := s.now()
+	for front := s.finished.Front(); front != nil; front = s.finished.Front() {
+		finished := front.Value.(finishedJob)
+		if now.Before(finished.expires) {
+			return
+		}
+
+		delete(s.jobs, finished.id)
+		s.finished.Remove(front)
+	}
+}
+
+// jobCancels holds the cancel functions of the jobs running in this process
+type jobCancels struct {
+	mu      sync.Mutex
+	cancels map[string]context.CancelFunc
+}
+
+func (j *jobCancels) add(id string, cancel context.CancelFunc) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	j.cancels[id] = cancel
+}
+
+func (j *jobCancels) remove(id string) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	delete(j.cancels, id)
+}
+
+func (j *jobCancels) cancel(id string) bool {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	cancel, ok := j.cancels[id]
+	if ok {
+		cancel()
+	}
+
+	return ok
+}
+
+func createJob(cfg Config) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req JobRequest
+
+		err := c.ShouldBindJSON(&req)
+		if err == nil {
+			req.Src = normalizeCoordinate(req.Src)
+			normalizeCoordinates(req.Dst)
+			err = validate.Struct(req)
+		}
+
+		if err != nil {
+			c.JSON(http.StatusBadRequest, ErrResp{
+				Code:      http.StatusBadRequest,
+				Message:   validationErrMsg(err),
+				ErrorCode: validationErrCode(err),
+			})
+			return
+		}
+
+		id, err := newJobID()
+		if err == nil {
+			job := Job{ID: id, Status: jobPending, Total: len(req.Dst)}
+			if err = jobStore.Save(job); err == nil {
+				ctx, cancel := context.WithCancel(context.Background())
+				runningJobs.add(id, cancel)
+				go runJob(ctx, cfg, job, req)
+
+				c.Header("Location", "/routes/jobs/"+id)
+				c.JSON(http.StatusAccepted, job)
+				return
+			}
+		}
+
+		c.JSON(http.StatusInternalServerError, ErrResp{
+			Code:      http.StatusInternalServerError,
+			Message:   "Could not create job",
+			ErrorCode: errCodeInternal,
+		})
+	}
+}
+
+func getJob(c *gin.Context) {
+	job, ok := loadJob(c)
+	if ok {
+		c.JSON(http.StatusOK, job)
+	}
+}
+
+// cancelJob stops dispatching the remaining destinations of a running job and aborts the calls in flight.
+// The job is marked cancelled once they have returned.
+func cancelJob(c *gin.Context) {
+	job, ok := loadJob(c)
+	if !ok {
+		return
+	}
+
+	if !runningJobs.cancel(job.ID) {
+		c.JSON(http.StatusConflict, ErrResp{
+			Code:      http.StatusConflict,
+			Message:   "Job is not running",
+			ErrorCode: errCodeConflict,
+		})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, job)
+}
+
+// loadJob writes an error response and returns false when the job can't be loaded
+func loadJob(c *gin.Context) (Job, bool) {
+	job, ok, err := jobStore.Get(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, ErrResp{
+			Code:      http.StatusInternalServerError,
+			Message:   "Could not load job",
+			ErrorCode: errCodeInternal,
+		})
+		return Job{}, false
+	}
+
+	if !ok {
+		c.JSON(http.StatusNotFound, ErrResp{
+			Code:      http.StatusNotFound,
+			Message:   "Job not found",
+			ErrorCode: errCodeNotFound,
+		})
+		return Job{}, false
+	}
+
+	return job, true
+}
+
+// runJob routes every destination of the job with at most jobConcurrency calls in flight,
+// saving the progress after each destination. Cancelling ctx aborts the calls in flight and stops any further
+// destinations from being routed.
+func runJob(ctx context.Context, cfg Config, job Job, req JobRequest) {
+	opts := RouteOptions{
+		RoadClasses: req.RoadClasses,
+	}
+
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		routes   = make([]Route, 0)
+		failures []Failure
+		dsts     = make(chan string)
+	)
+
+	job.Status = jobRunning
+	saveJob(job)
+
+	// With adaptive concurrency the calls of the workers are bounded by the limiter rather than their number
+	workers := jobConcurrency
+	if adaptiveConcurrency != nil {
+		workers = adaptiveConcurrency.max
+	}
+
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for d := range dsts {
+				var route Route
+				release, err := acquireFetchBudget(ctx, cfg.FetchBudget)
+				if err == nil {
+					route, err = getRouteData(ctx, cfg, req.Src, d, opts)
+					release()
+				}
+
+				// A call aborted by cancelling the job routed nothing, it is neither a route nor a failure
+				if err != nil && ctx.Err() != nil {
+					continue
+				}
+
+				mu.Lock()
+				if err != nil {
+					failures = append(failures, newFailure(d, err))
+				} else {
+					routes = append(routes, route)
+				}
+				job.Completed++
+				saveJob(job)
+				mu.Unlock()
+			}
+		}()
+	}
+
+dispatch:
+	for _, dst := range req.Dst {
+		select {
+		case dsts <- dst:
+		case <-ctx.Done():
+			break dispatch
+		}
+	}
+	close(dsts)
+
+	wg.Wait()
+
+	// The job can no longer be cancelled once its final state is being saved
+	runningJobs.remove(job.ID)
+
+	if ctx.Err() != nil {
+		job.Status = jobCancelled
+		if keepCancelledJobResults {
+			resp := newGetRoutesResp(req.Src, routes, failures, req.Dst)
+			job.Result = &resp
+		}
+		saveJob(job)
+		notifyJobWebhook(req.CallbackURL, job)
+		return
+	}
+
+	resp := newGetRoutesResp(req.Src, routes, failures, req.Dst)
+	job.Status = jobDone
+	job.Result = &resp
+	saveJob(job)
+	notifyJobWebhook(req.CallbackURL, job)
+}
+
+func saveJob(job Job) {
+	if err := jobStore.Save(job); err != nil {
+		log.Printf("could not save job %s: %s", job.ID, err)
+	}
+}
+
+func newJobID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(b), nil
+}
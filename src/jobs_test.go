@@ -0,0 +1,191 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func mockJobRequest(method string, url string, body string) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	req, _ := http.NewRequest(method, url, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(rec, req)
+
+	return rec
+}
+
+// waitForJob polls the job until it reaches the status or the test times out
+func waitForJob(t *testing.T, id string, status string) Job {
+	var job Job
+	for i := 0; i < 100; i++ {
+		rec := mockJobRequest(http.MethodGet, "/routes/jobs/"+id, "")
+		assert.Equal(t, http.StatusOK, rec.Code)
+		json.Unmarshal(rec.Body.Bytes(), &job)
+		if job.Status == status {
+			return job
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	t.Fatalf("job %s never reached status %s, last status %s", id, status, job.Status)
+	return job
+}
+
+func TestCreateJobReturns400WhenInvalid(t *testing.T) {
+	rec := mockJobRequest(http.MethodPost, "/routes/jobs", `{"src":"13.388860,52.517037","dst":["invalid"]}`)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+	rec = mockJobRequest(http.MethodPost, "/routes/jobs", `{"src":`)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+	rec = mockJobRequest(http.MethodPost, "/routes/jobs", `{"src":"13.388860,52.517037","dst":[]}`)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+}
+
+func TestGetJobReturns404WhenUnknown(t *testing.T) {
+	rec := mockJobRequest(http.MethodGet, "/routes/jobs/unknown", "")
+
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+	assert.Equal(t, `{"code":404,"message":"Job not found","error_code":"not_found"}`, rec.Body.String())
+}
+
+func TestJobReportsProgressAndResult(t *testing.T) {
+	release := make(chan struct{})
+	mockOsrmApi := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// The second destination is held back until the progress has been checked
+		if strings.Contains(r.URL.Path, "12.428555,52.523219") {
+			<-release
+			w.WriteHeader(http.StatusOK)
+			w.Write([]byte(`{"code":"Ok", "routes": [{"duration":260.1,"distance":1886.3}]}`))
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"code":"Ok", "routes": [{"duration":2490.1,"distance":3286.3}]}`))
+	}))
+	defer mockOsrmApi.Close()
+
+	router = setupRouter(Config{OsrmBaseURL: mockOsrmApi.URL})
+	jobConcurrency = 1
+	defer func() { jobConcurrency = 4 }()
+
+	rec := mockJobRequest(http.MethodPost, "/routes/jobs", `{"src":"13.388860,52.517037","dst":["13.397634,52.529407","12.428555,52.523219"]}`)
+	assert.Equal(t, http.StatusAccepted, rec.Code)
+
+	var job Job
+	json.Unmarshal(rec.Body.Bytes(), &job)
+	assert.NotEmpty(t, job.ID)
+	assert.Equal(t, "/routes/jobs/"+job.ID, rec.Header().Get("Location"))
+	assert.Equal(t, 2, job.Total)
+
+	for i := 0; i < 100 && job.Completed == 0; i++ {
+		time.Sleep(10 * time.Millisecond)
+		job = waitForJob(t, job.ID, jobRunning)
+	}
+	assert.Equal(t, 1, job.Completed)
+	assert.Nil(t, job.Result)
+
+	close(release)
+
+	job = waitForJob(t, job.ID, jobDone)
+	assert.Equal(t, 2, job.Completed)
+	assert.Equal(t, []Route{
+		{Destination: "12.428555,52.523219", Duration: 260.1, Distance: 1886.3},
+		{Destination: "13.397634,52.529407", Duration: 2490.1, Distance: 3286.3},
+	}, job.Result.Routes)
+}
+
+func TestCancelJobStopsRemainingFetches(t *testing.T) {
+	for _, keepResults := range []bool{false, true} {
+		var calls int32
+		started := make(chan struct{})
+		aborted := make(chan struct{})
+		mockOsrmApi := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if atomic.AddInt32(&calls, 1) == 1 {
+				close(started)
+				// Only returns once the job aborts the call
+				<-r.Context().Done()
+				close(aborted)
+				return
+			}
+			w.WriteHeader(http.StatusOK)
+			w.Write([]byte(`{"code":"Ok", "routes": [{"duration":2490.1,"distance":3286.3}]}`))
+		}))
+
+		router = setupRouter(Config{OsrmBaseURL: mockOsrmApi.URL, RetryAttempts: 1})
+		jobConcurrency = 1
+		keepCancelledJobResults = keepResults
+
+		rec := mockJobRequest(http.MethodPost, "/routes/jobs", `{"src":"13.388860,52.517037","dst":["13.397634,52.529407","12.428555,52.523219","13.428555,48.523219"]}`)
+		assert.Equal(t, http.StatusAccepted, rec.Code)
+
+		var job Job
+		json.Unmarshal(rec.Body.Bytes(), &job)
+
+		<-started
+		rec = mockJobRequest(http.MethodDelete, "/routes/jobs/"+job.ID, "")
+		assert.Equal(t, http.StatusAccepted, rec.Code)
+
+		job = waitForJob(t, job.ID, jobCancelled)
+		select {
+		case <-aborted:
+		case <-time.After(time.Second):
+			t.Fatal("the fetch in flight was not aborted")
+		}
+		assert.Equal(t, int32(1), atomic.LoadInt32(&calls))
+		assert.Equal(t, 0, job.Completed)
+		if keepResults {
+			assert.Empty(t, job.Result.Routes)
+			assert.Empty(t, job.Result.Failures)
+		} else {
+			assert.Nil(t, job.Result)
+		}
+
+		// A job that is no longer running can't be cancelled
+		rec = mockJobRequest(http.MethodDelete, "/routes/jobs/"+job.ID, "")
+		assert.Equal(t, http.StatusConflict, rec.Code)
+
+		mockOsrmApi.Close()
+	}
+
+	jobConcurrency = 4
+	keepCancelledJobResults = false
+}
+
+func TestMemoryJobStoreEvictsFinishedJobsAfterRetention(t *testing.T) {
+	now := time.Date(2023, 6, 1, 12, 0, 0, 0, time.UTC)
+	store := newMemoryJobStore(time.Hour)
+	store.now = func() time.Time { return now }
+
+	store.Save(Job{ID: "running", Status: jobRunning})
+	store.Save(Job{ID: "done", Status: jobDone})
+	now = now.Add(30 * time.Minute)
+	store.Save(Job{ID: "cancelled", Status: jobCancelled})
+
+	now = now.Add(30 * time.Minute)
+	_, ok, _ := store.Get("done")
+	assert.False(t, ok)
+	_, ok, _ = store.Get("cancelled")
+	assert.True(t, ok)
+
+	now = now.Add(30 * time.Minute)
+	_, ok, _ = store.Get("cancelled")
+	assert.False(t, ok)
+
+	// Jobs still running are kept however long they take
+	_, ok, _ = store.Get("running")
+	assert.True(t, ok)
+	assert.Len(t, store.jobs, 1)
+}
+
+func TestCancelJobReturns404WhenUnknown(t *testing.T) {
+	rec := mockJobRequest(http.MethodDelete, "/routes/jobs/unknown", "")
+
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+}
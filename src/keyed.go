@@ -0,0 +1,25 @@
+package main
+
+// KeyedRoutesResp is a GetRoutesResp with its routes in an object keyed by destination. Objects
+// have no order, so Order lists the destinations in the order the routes were sorted in.
+type KeyedRoutesResp struct {
+	RoutesEnvelope
+	Routes map[string]Route `json:"routes"`
+	Order  []string         `json:"order"`
+}
+
+// keyByDestination puts the routes under their destination. Destinations are deduplicated before
+// routing, should one still repeat the first route, which sorts first, is kept.
+func (o *GetRoutesResp) keyByDestination() KeyedRoutesResp {
+	routes := make(map[string]Route, len(o.Routes))
+	order := make([]string, 0, len(o.Routes))
+	for _, route := range o.Routes {
+		if _, ok := routes[route.Destination]; ok {
+			continue
+		}
+		routes[route.Destination] = route
+		order = append(order, route.Destination)
+	}
+
+	return KeyedRoutesResp{RoutesEnvelope: o.envelope(), Routes: routes, Order: order}
+}
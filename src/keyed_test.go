@@ -0,0 +1,55 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestKeyByDestinationKeepsFirstRouteOfRepeatedDestination(t *testing.T) {
+	resp := GetRoutesResp{
+		Source: "13.388860,52.517037",
+		Routes: []Route{
+			{Destination: "13.428555,52.523219", Duration: 260.1, Distance: 1886.3},
+			{Destination: "13.397634,52.529407", Duration: 860.1, Distance: 4886.3},
+			{Destination: "13.428555,52.523219", Duration: 2490.1, Distance: 3286.3},
+		},
+	}
+
+	keyed := resp.keyByDestination()
+	assert.Equal(t, []string{"13.428555,52.523219", "13.397634,52.529407"}, keyed.Order)
+	assert.Equal(t, resp.Routes[0], keyed.Routes["13.428555,52.523219"])
+	assert.Len(t, keyed.Routes, 2)
+}
+
+func TestGetRoutesKeysRoutesByDestination(t *testing.T) {
+	mockOsrmApi := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case strings.Contains(r.URL.Path, "13.397634,52.529407"):
+			w.WriteHeader(http.StatusOK)
+			w.Write([]byte(`{"code":"Ok", "routes": [{"duration":2490.1,"distance":3286.3}]}`))
+		case strings.Contains(r.URL.Path, "1.0,1.0"):
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"code":"NoRoute", "message": "Impossible route between points"}`))
+		default:
+			w.WriteHeader(http.StatusOK)
+			w.Write([]byte(`{"code":"Ok", "routes": [{"duration":260.1,"distance":1886.3}]}`))
+		}
+	}))
+	defer mockOsrmApi.Close()
+
+	router = setupRouter(Config{OsrmBaseURL: mockOsrmApi.URL})
+	rec := mockGetRoutesRequest("/routes?src=13.388860,52.517037&dst=13.397634,52.529407&dst=13.428555,52.523219&dst=1.0,1.0&dst=13.397634,52.529407&keyed=true")
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, `{"source":"13.388860,52.517037",`+
+		`"failures":[{"destination":"1.0,1.0","status":422,"category":"no_route","message":"response code: 400. message: Impossible route between points"}],`+
+		`"metadata":{"detourRatio":1.21},"routes":{`+
+		`"13.397634,52.529407":{"destination":"13.397634,52.529407","duration":2490.1,"distance":3286.3},`+
+		`"13.428555,52.523219":{"destination":"13.428555,52.523219","duration":260.1,"distance":1886.3}},`+
+		`"order":["13.428555,52.523219","13.397634,52.529407"]}`,
+		rec.Body.String())
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+const kmlContentType = "application/vnd.google-earth.kml+xml"
+
+type kmlDocument struct {
+	XMLName  xml.Name `xml:"kml"`
+	Xmlns    string   `xml:"xmlns,attr"`
+	Document struct {
+		Name       string         `xml:"name"`
+		Placemarks []kmlPlacemark `xml:"Placemark"`
+	} `xml:"Document"`
+}
+
+type kmlPlacemark struct {
+	Name         string         `xml:"name"`
+	Description  string         `xml:"description"`
+	ExtendedData []kmlData      `xml:"ExtendedData>Data"`
+	Point        *kmlPoint      `xml:"Point,omitempty"`
+	LineString   *kmlLineString `xml:"LineString,omitempty"`
+}
+
+type kmlData struct {
+	Name  string `xml:"name,attr"`
+	Value string `xml:"value"`
+}
+
+type kmlPoint struct {
+	// KML coordinates are longitude,latitude like the OSRM input
+	Coordinates string `xml:"coordinates"`
+}
+
+type kmlLineString struct {
+	// Longitude,latitude tuples separated by spaces
+	Coordinates string `xml:"coordinates"`
+}
+
+// kmlCoordinates formats the [lng, lat] pairs of a geometry as KML coordinate tuples
+func kmlCoordinates(geometry [][2]float64) string {
+	tuples := make([]string, len(geometry))
+	for i, point := range geometry {
+		tuples[i] = strconv.FormatFloat(point[0], 'f', -1, 64) + "," + strconv.FormatFloat(point[1], 'f', -1, 64)
+	}
+
+	return strings.Join(tuples, " ")
+}
+
+// marshalKML renders the routes as a KML document with a placemark per destination. Like GeoJSON, a
+// placemark is the route as a LineString when its geometry was requested and the destination as a Point otherwise.
+func (o *GetRoutesResp) marshalKML() ([]byte, error) {
+	doc := kmlDocument{Xmlns: "http://www.opengis.net/kml/2.2"}
+	doc.Document.Name = "Routes from " + o.Source
+
+	for _, route := range o.Routes {
+		duration := fmt.Sprint(route.Duration)
+		distance := fmt.Sprint(route.Distance)
+
+		placemark := kmlPlacemark{
+			Name:        route.Destination,
+			Description: fmt.Sprintf("Duration: %ss, distance: %sm", duration, distance),
+			ExtendedData: []kmlData{
+				{Name: "duration", Value: duration},
+				{Name: "distance", Value: distance},
+			},
+		}
+		if len(route.Geometry) > 0 {
+			placemark.LineString = &kmlLineString{Coordinates: kmlCoordinates(route.Geometry)}
+		} else {
+			placemark.Point = &kmlPoint{Coordinates: route.Destination}
+		}
+
+		doc.Document.Placemarks = append(doc.Document.Placemarks, placemark)
+	}
+
+	body, err := xml.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+
+	return append([]byte(xml.Header), body...), nil
+}
+
+func writeKML(c *gin.Context, resp GetRoutesResp) {
+	body, err := resp.marshalKML()
+	if err != nil {
+		writeErrResp(c, ErrResp{
+			Code:      http.StatusInternalServerError,
+			Message:   "Could not encode response",
+			ErrorCode: errCodeInternal,
+		})
+		return
+	}
+
+	c.Data(http.StatusOK, kmlContentType, body)
+}
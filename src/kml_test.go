@@ -0,0 +1,75 @@
+package main
+
+import (
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetRoutesReturnsKML(t *testing.T) {
+	osrmApiPath := "/route/v1/driving/%s;%s"
+	src := "13.388860,52.517037"
+	dst1 := "13.397634,52.529407"
+	dst2 := "12.428555,52.523219"
+
+	mockOsrmApi := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		if r.URL.Path == fmt.Sprintf(osrmApiPath, src, dst1) {
+			w.Write([]byte(`{"code":"Ok", "routes": [{"duration":2490.1,"distance":3286.3}]}`))
+			return
+		}
+		w.Write([]byte(`{"code":"Ok", "routes": [{"duration":260.1,"distance":1886.3}]}`))
+	}))
+	defer mockOsrmApi.Close()
+
+	router = setupRouter(Config{OsrmBaseURL: mockOsrmApi.URL})
+	rec := mockGetRoutesRequest(fmt.Sprintf("/routes?src=%s&dst=%s&dst=%s&format=kml", src, dst1, dst2))
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, kmlContentType, rec.Header().Get("Content-Type"))
+
+	var doc kmlDocument
+	assert.Nil(t, xml.Unmarshal(rec.Body.Bytes(), &doc))
+	assert.Equal(t, "http://www.opengis.net/kml/2.2", doc.Xmlns)
+	assert.Equal(t, "Routes from 13.388860,52.517037", doc.Document.Name)
+	assert.Len(t, doc.Document.Placemarks, 2)
+
+	assert.Equal(t, kmlPlacemark{
+		Name:         dst2,
+		Description:  "Duration: 260.1s, distance: 1886.3m",
+		ExtendedData: []kmlData{{Name: "duration", Value: "260.1"}, {Name: "distance", Value: "1886.3"}},
+		Point:        &kmlPoint{Coordinates: dst2},
+	}, doc.Document.Placemarks[0])
+	assert.Equal(t, dst1, doc.Document.Placemarks[1].Point.Coordinates)
+}
+
+func TestGetRoutesReturnsKMLLineStringsWithGeometry(t *testing.T) {
+	mockOsrmApi := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"code":"Ok", "routes": [{"duration":260.1,"distance":1886.3,"geometry":{"type":"LineString","coordinates":[` +
+			`[13.38886,52.517037],[13.39,52.52],[13.397634,52.529407]]}}]}`))
+	}))
+	defer mockOsrmApi.Close()
+
+	router = setupRouter(Config{OsrmBaseURL: mockOsrmApi.URL})
+	rec := mockGetRoutesRequest("/routes?src=13.388860,52.517037&dst=13.397634,52.529407&format=kml&geometry=true")
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), "<LineString>\n        <coordinates>13.38886,52.517037 13.39,52.52 13.397634,52.529407</coordinates>\n      </LineString>")
+	assert.NotContains(t, rec.Body.String(), "<Point>")
+
+	var doc kmlDocument
+	assert.Nil(t, xml.Unmarshal(rec.Body.Bytes(), &doc))
+	assert.Nil(t, doc.Document.Placemarks[0].Point)
+	assert.Equal(t, &kmlLineString{Coordinates: "13.38886,52.517037 13.39,52.52 13.397634,52.529407"}, doc.Document.Placemarks[0].LineString)
+}
+
+func TestGetRoutesReturns400WhenFormatIsUnknown(t *testing.T) {
+	rec := mockGetRoutesRequest("/routes?src=13.388860,52.517037&dst=13.397634,52.529407&format=xls")
+
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+}
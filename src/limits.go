@@ -0,0 +1,31 @@
+package main
+
+import "fmt"
+
+// filterByLimits moves the routes longer than maxDuration seconds or maxDistance meters to the
+// failures, leaving those within both. A limit of 0 is no limit.
+func filterByLimits(routes []Route, maxDuration float64, maxDistance float64) ([]Route, []Failure) {
+	kept := make([]Route, 0, len(routes))
+	var failures []Failure
+	for _, route := range routes {
+		var message string
+		switch {
+		case maxDuration > 0 && route.Duration > maxDuration:
+			message = fmt.Sprintf("duration of %vs exceeds max_duration of %vs", route.Duration, maxDuration)
+		case maxDistance > 0 && route.Distance > maxDistance:
+			message = fmt.Sprintf("distance of %vm exceeds max_distance of %vm", route.Distance, maxDistance)
+		default:
+			kept = append(kept, route)
+			continue
+		}
+
+		failures = append(failures, Failure{
+			Destination: route.Destination,
+			Status:      failureStatus(categoryExceedsLimit),
+			Category:    categoryExceedsLimit,
+			Message:     message,
+		})
+	}
+
+	return kept, failures
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFilterByLimits(t *testing.T) {
+	routes := []Route{
+		{Destination: "13.397634,52.529407", Duration: 2490.1, Distance: 3286.3},
+		{Destination: "12.428555,52.523219", Duration: 260.1, Distance: 1886.3},
+		{Destination: "13.412,52.5", Duration: 600, Distance: 9000},
+	}
+
+	kept, failures := filterByLimits(routes, 1800, 0)
+	assert.Equal(t, []Route{routes[1], routes[2]}, kept)
+	assert.Equal(t, []Failure{{Destination: "13.397634,52.529407", Status: http.StatusUnprocessableEntity, Category: categoryExceedsLimit,
+		Message: "duration of 2490.1s exceeds max_duration of 1800s"}}, failures)
+
+	kept, failures = filterByLimits(routes, 0, 5000)
+	assert.Equal(t, []Route{routes[0], routes[1]}, kept)
+	assert.Equal(t, []Failure{{Destination: "13.412,52.5", Status: http.StatusUnprocessableEntity, Category: categoryExceedsLimit,
+		Message: "distance of 9000m exceeds max_distance of 5000m"}}, failures)
+
+	kept, failures = filterByLimits(routes, 1800, 5000)
+	assert.Equal(t, []Route{routes[1]}, kept)
+	assert.Len(t, failures, 2)
+
+	kept, failures = filterByLimits(routes, 0, 0)
+	assert.Equal(t, routes, kept)
+	assert.Empty(t, failures)
+}
+
+func TestGetRoutesDropsRoutesOverLimits(t *testing.T) {
+	osrmApiPath := "/route/v1/driving/%s;%s"
+	src := "13.388860,52.517037"
+	dst1 := "13.397634,52.529407"
+	dst2 := "12.428555,52.523219"
+
+	mockOsrmApi := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		if r.URL.Path == fmt.Sprintf(osrmApiPath, src, dst1) {
+			w.Write([]byte(`{"code":"Ok", "routes": [{"duration":2490.1,"distance":3286.3}]}`))
+			return
+		}
+		w.Write([]byte(`{"code":"Ok", "routes": [{"duration":260.1,"distance":1886.3}]}`))
+	}))
+	defer mockOsrmApi.Close()
+
+	router = setupRouter(Config{OsrmBaseURL: mockOsrmApi.URL})
+	rec := mockGetRoutesRequest(fmt.Sprintf("/routes?src=%s&dst=%s&dst=%s&max_duration=1800", src, dst1, dst2))
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"routes":[{"destination":"12.428555,52.523219","duration":260.1,"distance":1886.3}],`+
+		`"failures":[{"destination":"13.397634,52.529407","status":422,"category":"exceeds_limit","message":"duration of 2490.1s exceeds max_duration of 1800s"}]`)
+
+	rec = mockGetRoutesRequest(fmt.Sprintf("/routes?src=%s&dst=%s&dst=%s&max_distance=1000", src, dst1, dst2))
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"routes":[]`)
+	assert.Contains(t, rec.Body.String(), `"category":"exceeds_limit","message":"distance of 3286.3m exceeds max_distance of 1000m"`)
+
+	rec = mockGetRoutesRequest(fmt.Sprintf("/routes?src=%s&dst=%s&max_distance=-1", src, dst1))
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+	rec = mockPostRoutesRequest(fmt.Sprintf(`{"src":%q,"dst":[%q,%q],"max_duration":1800}`, src, dst1, dst2))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"message":"duration of 2490.1s exceeds max_duration of 1800s"`)
+}
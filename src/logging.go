@@ -0,0 +1,54 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Only 1 in logSampleRate successful requests is logged, errors are always logged
+var logSampleRate = 1
+
+// sampledLogger writes a line per logged request in the format of gin's default logger.
+// Requests to skipPaths, such as probes, are never logged.
+func sampledLogger(rate int, out io.Writer, skipPaths ...string) gin.HandlerFunc {
+	var successes uint64
+
+	skip := make(map[string]bool, len(skipPaths))
+	for _, p := range skipPaths {
+		skip[p] = true
+	}
+
+	return func(c *gin.Context) {
+		if skip[c.Request.URL.Path] {
+			c.Next()
+			return
+		}
+
+		start := time.Now()
+		path := c.Request.URL.Path
+		if c.Request.URL.RawQuery != "" {
+			path += "?" + c.Request.URL.RawQuery
+		}
+
+		c.Next()
+
+		status := c.Writer.Status()
+		if status < http.StatusBadRequest && rate > 1 && atomic.AddUint64(&successes, 1)%uint64(rate) != 0 {
+			return
+		}
+
+		fmt.Fprintf(out, "[GIN] %v | %3d | %13v | %15s | %-7s %#v\n",
+			start.Format("2006/01/02 - 15:04:05"),
+			status,
+			time.Since(start),
+			c.ClientIP(),
+			c.Request.Method,
+			path,
+		)
+	}
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func setupLoggingRouter(rate int, out *bytes.Buffer) *gin.Engine {
+	r := gin.New()
+	r.Use(sampledLogger(rate, out))
+	r.GET("/ok", func(c *gin.Context) { c.Status(http.StatusOK) })
+	r.GET("/error", func(c *gin.Context) { c.Status(http.StatusBadGateway) })
+
+	return r
+}
+
+func TestSampledLoggerLogsOneInNSuccesses(t *testing.T) {
+	var out bytes.Buffer
+	r := setupLoggingRouter(10, &out)
+
+	for i := 0; i < 1000; i++ {
+		rec := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodGet, "/ok", nil)
+		r.ServeHTTP(rec, req)
+	}
+
+	lines := strings.Count(out.String(), "\n")
+	assert.InDelta(t, 100, lines, 5)
+	assert.Contains(t, out.String(), `| 200 |`)
+}
+
+func TestSampledLoggerAlwaysLogsErrors(t *testing.T) {
+	var out bytes.Buffer
+	r := setupLoggingRouter(10, &out)
+
+	for i := 0; i < 20; i++ {
+		rec := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodGet, "/error?src=1", nil)
+		r.ServeHTTP(rec, req)
+	}
+
+	assert.Equal(t, 20, strings.Count(out.String(), "\n"))
+	assert.Contains(t, out.String(), `| 502 |`)
+	assert.Contains(t, out.String(), `GET     "/error?src=1"`)
+}
+
+func TestSampledLoggerSkipsPaths(t *testing.T) {
+	var out bytes.Buffer
+	r := gin.New()
+	r.Use(sampledLogger(1, &out, "/health"))
+	r.GET("/health", func(c *gin.Context) { c.Status(http.StatusServiceUnavailable) })
+	r.GET("/ok", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	for _, path := range []string{"/health", "/ok"} {
+		rec := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodGet, path, nil)
+		r.ServeHTTP(rec, req)
+	}
+
+	assert.Equal(t, 1, strings.Count(out.String(), "\n"))
+	assert.Contains(t, out.String(), `"/ok"`)
+}
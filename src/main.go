@@ -1,185 +1,1307 @@
 package main
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"log"
+	"math"
 	"net/http"
+	"net/url"
+	"os"
 	"regexp"
 	"sort"
-	"sync"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/go-playground/validator/v10"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
 var (
-	validate   *validator.Validate
+	validate = newValidator()
+
+	// Client of the time zone lookups and the geocoder, OSRM calls use the client of their router
 	httpClient = &http.Client{
-		Timeout: time.Second * 10,
+		Timeout: defaultHTTPTimeout,
 	}
-	latLngPattern = regexp.MustCompile(`^[-+]?([1-8]?\d(\.\d+)?|90(\.0+)?),[-+]?(180(\.0+)?|((1[0-7]\d)|([1-9]?\d))(\.\d+)?)$`)
-	osrmApiUrl    = "http://router.project-osrm.org/route/v1/driving/%s;%s?overview=false"
+
+	// A coordinate in the lng,lat order OSRM takes, the longitude up to 180 and the latitude up to 90
+	latLngPattern = regexp.MustCompile(`^[-+]?(180(\.0+)?|((1[0-7]\d)|([1-9]?\d))(\.\d+)?),[-+]?([1-8]?\d(\.\d+)?|90(\.0+)?)$`)
+
+	// Transport profiles the OSRM backend serves, the first one is the default
+	osrmProfiles = []string{"driving", "walking", "cycling"}
+
+	// OSRM responses whose body was cut off are retried up to truncatedRetries times
+	truncatedRetries = 2
+
+	// OSRM works with 6 decimal places (~10cm), anything beyond that is discarded
+	maxCoordinatePrecision = 6
+
+	// Coordinates at exactly the poles or on the antimeridian are accepted unless this is set
+	rejectEdgeCoordinates = false
+
+	// What POST /routes does with a src or dst in its query string
+	postQueryCoordinates = queryCoordinatesReject
+)
+
+// Policies for coordinates given in both the query string and the body of POST /routes
+const (
+	queryCoordinatesReject = "reject"
+	queryCoordinatesBody   = "body"
+	queryCoordinatesQuery  = "query"
 )
 
 type QueryParams struct {
-	Src string   `form:"src" binding:"required" validate:"latlng"`
-	Dst []string `form:"dst" binding:"required" validate:"latlng"`
+	Src              string    `form:"src" json:"src" binding:"required" validate:"latlng,noedge"`
+	Dst              []string  `form:"dst" json:"dst" binding:"required,min=1" validate:"latlng,noedge"`
+	RoadClasses      bool      `form:"roadClasses" json:"roadClasses"`
+	Naming           string    `form:"naming" json:"naming" validate:"omitempty,oneof=camel snake"`
+	MaxCalls         int       `form:"maxCalls" json:"maxCalls" validate:"min=0"`
+	PrefilterNearest int       `form:"prefilterNearest" json:"prefilterNearest" validate:"min=0"`
+	Limit            int       `form:"limit" json:"limit" validate:"min=0"`
+	Sort             string    `form:"sort" json:"sort" validate:"omitempty,oneof=duration distance"`
+	Pretty           bool      `form:"pretty" json:"pretty"`
+	Debug            bool      `form:"debug" json:"debug"`
+	Cluster          float64   `form:"cluster" json:"cluster" validate:"min=0"`
+	OnEmpty          string    `form:"onEmpty" json:"onEmpty" validate:"omitempty,oneof=ok 404"`
+	Format           string    `form:"format" json:"format" validate:"omitempty,oneof=json kml gpx geojson csv"`
+	Since            string    `form:"since" json:"since"`
+	Order            []string  `form:"order" json:"order" validate:"omitempty,latlng"`
+	Unordered        string    `form:"unordered" json:"unordered" validate:"omitempty,oneof=end omit"`
+	Bands            []float64 `form:"bands" json:"bands" validate:"omitempty,dive,gt=0"`
+	Strategy         string    `form:"strategy" json:"strategy" validate:"omitempty,oneof=waitgroup channel"`
+	Timezone         bool      `form:"timezone" json:"timezone"`
+	Preview          bool      `form:"preview" json:"preview"`
+	Turns            bool      `form:"turns" json:"turns"`
+	Profile          string    `form:"profile" json:"profile" validate:"omitempty,profile"`
+	Flat             bool      `form:"flat" json:"flat"`
+	Timings          bool      `form:"timings" json:"timings"`
+	Fingerprint      bool      `form:"fingerprint" json:"fingerprint"`
+	GroupByGrid      *int      `form:"groupByGrid" json:"groupByGrid" validate:"omitempty,min=0,max=6"`
+	SpeedFactor      float64   `form:"speedFactor" json:"speedFactor" validate:"omitempty,min=0.1,max=10"`
+	Units            string    `form:"units" json:"units" validate:"omitempty,oneof=metric imperial"`
+	Geocode          bool      `form:"geocode" json:"geocode"`
+	Compare          string    `form:"compare" json:"compare" validate:"omitempty,profiles"`
+	Cursor           string    `form:"cursor" json:"cursor"`
+	Cache            *bool     `form:"cache" json:"cache"`
+	Keyed            bool      `form:"keyed" json:"keyed"`
+	Echo             bool      `form:"echo" json:"echo"`
+	Geometry         bool      `form:"geometry" json:"geometry"`
+	Progress         bool      `form:"progress" json:"progress"`
+	Steps            bool      `form:"steps" json:"steps"`
+	Alternatives     int       `form:"alternatives" json:"alternatives" validate:"min=0,max=3"`
+	MaxDuration      float64   `form:"max_duration" json:"max_duration" validate:"min=0"`
+	MaxDistance      float64   `form:"max_distance" json:"max_distance" validate:"min=0"`
+}
+
+// RenderOptions controls how a JSON response body is written
+type RenderOptions struct {
+	Naming string
+	Pretty bool
+}
+
+// RouteOptions controls what is requested from OSRM for a single route
+type RouteOptions struct {
+	RoadClasses bool
+	Geometry    bool
+	Preview     bool
+	Turns       bool
+	Steps       bool
+	Profile     string
+
+	// Alternative routes to ask OSRM for next to the fastest one
+	Alternatives int
+
+	// Include the geometry fetched for Geometry in the route
+	ReturnGeometry bool
+
+	// Skip the route cache lookup, the fresh route still replaces the cached one
+	BypassCache bool
+
+	// Accept a cached route up to this long past its TTL, from the max-stale of Cache-Control
+	MaxStale time.Duration
 }
 
 type OsrmApiRouteData struct {
 	Routes []struct {
-		Duration float64 `json:"duration"`
-		Distance float64 `json:"distance"`
+		Duration   float64  `json:"duration"`
+		Distance   float64  `json:"distance"`
+		Weight     *float64 `json:"weight"`
+		WeightName string   `json:"weight_name"`
+		Geometry   struct {
+			Coordinates [][]float64 `json:"coordinates"`
+		} `json:"geometry"`
+		Legs []struct {
+			Steps []struct {
+				Name     string  `json:"name"`
+				Distance float64 `json:"distance"`
+				Duration float64 `json:"duration"`
+				Maneuver struct {
+					Type     string `json:"type"`
+					Modifier string `json:"modifier"`
+				} `json:"maneuver"`
+				Intersections []struct {
+					Classes []string `json:"classes"`
+				} `json:"intersections"`
+			} `json:"steps"`
+		} `json:"legs"`
 	} `json:"routes"`
-	Code    string `json:"code"`
-	Message string `json:"message"`
+	Waypoints []struct {
+		Location []float64 `json:"location"`
+	} `json:"waypoints"`
+	Code        string `json:"code"`
+	Message     string `json:"message"`
+	DataVersion string `json:"data_version"`
 }
 
 type Route struct {
-	Destination string  `json:"destination"`
-	Duration    float64 `json:"duration"`
-	Distance    float64 `json:"distance"`
+	Destination  string   `json:"destination"`
+	Duration     float64  `json:"duration"`
+	Distance     float64  `json:"distance"`
+	Weight       *float64 `json:"weight,omitempty"`
+	SnapDistance *float64 `json:"snapDistance,omitempty"`
+	HasToll      *bool    `json:"hasToll,omitempty"`
+	UsesMotorway *bool    `json:"usesMotorway,omitempty"`
+	ClusteredTo  string   `json:"clusteredTo,omitempty"`
+	Timezone     string   `json:"timezone,omitempty"`
+	Preview      string   `json:"preview,omitempty"`
+	Turns        *int     `json:"turns,omitempty"`
+	Floored      bool     `json:"floored,omitempty"`
+	Adjusted     bool     `json:"adjusted,omitempty"`
+
+	// Geometry is the path of the route as [lng, lat] pairs, like GeoJSON, with geometry=true
+	Geometry [][2]float64 `json:"geometry,omitempty"`
+
+	// Steps are the maneuvers of the route in driving order, with steps=true
+	Steps []Step `json:"steps,omitempty"`
+
+	// Alternatives are the other routes OSRM found to the destination, with alternatives=N
+	Alternatives []Alternative `json:"alternatives,omitempty"`
+
+	// Cached tells whether the route came from the route cache or from OSRM, only set when the cache is enabled
+	Cached *bool `json:"cached,omitempty"`
+
+	DurationMinutes *float64 `json:"durationMinutes,omitempty"`
+	DistanceKm      *float64 `json:"distanceKm,omitempty"`
+	DistanceMiles   *float64 `json:"distanceMiles,omitempty"`
+
+	// weightName is reported once for the whole response, see GetRoutesResp.WeightName
+	weightName string
+
+	// geometry is only requested for formats that draw the route, such as GPX
+	geometry []Coordinate
+}
+
+// Step is a single maneuver of a route and the stretch of road that follows it
+type Step struct {
+	Type     string  `json:"type"`
+	Modifier string  `json:"modifier,omitempty"`
+	Name     string  `json:"name,omitempty"`
+	Distance float64 `json:"distance"`
+	Duration float64 `json:"duration"`
+}
+
+// Alternative is a route to the same destination other than the fastest one
+type Alternative struct {
+	Duration float64 `json:"duration"`
+	Distance float64 `json:"distance"`
 }
 
 type GetRoutesResp struct {
-	Source string  `json:"source"`
-	Routes []Route `json:"routes"`
+	Source     string            `json:"source"`
+	WeightName string            `json:"weightName,omitempty"`
+	Delta      bool              `json:"delta,omitempty"`
+	Routes     []Route           `json:"routes"`
+	Removed    []string          `json:"removed,omitempty"`
+	Warnings   []string          `json:"warnings,omitempty"`
+	Skipped    []string          `json:"skipped,omitempty"`
+	Failures   []Failure         `json:"failures,omitempty"`
+	Excluded   []Exclusion       `json:"excluded,omitempty"`
+	Metadata   *Metadata         `json:"metadata,omitempty"`
+	Debug      *EffectiveOptions `json:"debug,omitempty"`
+	Timings    *PhaseTimings     `json:"timings,omitempty"`
+	Geocoded   map[string]string `json:"geocoded,omitempty"`
+	NextCursor string            `json:"nextCursor,omitempty"`
+	Request    *QueryParams      `json:"request,omitempty"`
+}
+
+// RoutesEnvelope is everything of a GetRoutesResp but its routes. The other shapes of the response
+// embed it, so they carry the same warnings, failures, metadata and cursor.
+type RoutesEnvelope struct {
+	Source     string            `json:"source"`
+	WeightName string            `json:"weightName,omitempty"`
+	Delta      bool              `json:"delta,omitempty"`
+	Removed    []string          `json:"removed,omitempty"`
+	Warnings   []string          `json:"warnings,omitempty"`
+	Skipped    []string          `json:"skipped,omitempty"`
+	Failures   []Failure         `json:"failures,omitempty"`
+	Excluded   []Exclusion       `json:"excluded,omitempty"`
+	Metadata   *Metadata         `json:"metadata,omitempty"`
+	Debug      *EffectiveOptions `json:"debug,omitempty"`
+	Timings    *PhaseTimings     `json:"timings,omitempty"`
+	Geocoded   map[string]string `json:"geocoded,omitempty"`
+	NextCursor string            `json:"nextCursor,omitempty"`
+	Request    *QueryParams      `json:"request,omitempty"`
+}
+
+func (o *GetRoutesResp) envelope() RoutesEnvelope {
+	return RoutesEnvelope{
+		Source:     o.Source,
+		WeightName: o.WeightName,
+		Delta:      o.Delta,
+		Removed:    o.Removed,
+		Warnings:   o.Warnings,
+		Skipped:    o.Skipped,
+		Failures:   o.Failures,
+		Excluded:   o.Excluded,
+		Metadata:   o.Metadata,
+		Debug:      o.Debug,
+		Timings:    o.Timings,
+		Geocoded:   o.Geocoded,
+		NextCursor: o.NextCursor,
+		Request:    o.Request,
+	}
 }
 
 type ErrResp struct {
-	Code    int    `json:"code"`
-	Message string `json:"message"`
+	Code      int    `json:"code"`
+	Message   string `json:"message"`
+	ErrorCode string `json:"error_code"`
+}
+
+// Stable error codes clients can branch on instead of parsing messages
+const (
+	errCodeMissingParameter  = "missing_parameter"
+	errCodeInvalidParameter  = "invalid_parameter"
+	errCodeInvalidCoordinate = "invalid_coordinate"
+	errCodeUnauthorized      = "unauthorized"
+	errCodeNotFound          = "not_found"
+	errCodeConflict          = "conflict"
+	errCodeNoRoutes          = "no_routes"
+	errCodeQuotaExceeded     = "quota_exceeded"
+	errCodeInternal          = "internal_error"
+	errCodeBackendError      = "backend_error"
+)
+
+// newValidator registers the custom validations once, so routers can be set up while others serve requests
+func newValidator() *validator.Validate {
+	v := validator.New()
+	v.RegisterValidation("latlng", validateLatLng)
+	v.RegisterValidation("noedge", validateNoEdge)
+	v.RegisterValidation("profile", validateProfile)
+	v.RegisterValidation("profiles", validateProfiles)
+	v.RegisterValidation("callback", validateCallbackURL)
+
+	return v
 }
 
-func setupRouter() *gin.Engine {
-	r := gin.Default()
+func setupRouter(cfg Config) *gin.Engine {
+	cfg = cfg.withDefaults()
+	cfg.client = &http.Client{Timeout: cfg.HTTPTimeout}
+
+	r := gin.New()
+	r.Use(sampledLogger(logSampleRate, gin.DefaultWriter, "/health", "/metrics"), gin.Recovery(), metrics.middleware())
+	if err := r.SetTrustedProxies(trustedProxies); err != nil {
+		log.Fatalf("invalid trusted proxies: %s", err)
+	}
+
+	// Probes and scrapes aren't subject to the API key or the quota
+	r.GET("/health", getHealth(cfg))
+	r.GET("/metrics", gin.WrapH(promhttp.Handler()))
+
+	var middleware []gin.HandlerFunc
+	if apiKey != "" {
+		middleware = append(middleware, requireAPIKey(apiKey, apiKeyHeader))
+	}
+	if dailyQuota != nil {
+		middleware = append(middleware, dailyQuota.middleware())
+	}
 
-	validate = validator.New()
-	validate.RegisterValidation("latlng", validateLatLng)
+	r.GET("/routes", append(middleware, getRoutes(cfg))...)
+	r.POST("/routes", append(middleware, postRoutes(cfg))...)
+	r.GET("/routes/nearest", append(middleware, getNearestSource(cfg))...)
+	r.GET("/routes/nearest-destination", append(middleware, getNearestDestination(cfg))...)
+	r.GET("/matrix", append(middleware, getMatrix(cfg))...)
+	r.POST("/matrix", append(middleware, postMatrix(cfg))...)
+	r.POST("/routes/jobs", append(middleware, createJob(cfg))...)
+	r.GET("/routes/jobs/:id", append(middleware, getJob)...)
+	r.DELETE("/routes/jobs/:id", append(middleware, cancelJob)...)
 
-	r.GET("/routes", getRoutes)
+	if debugTimings {
+		r.GET("/debug/timings", append(middleware, getDebugTimings)...)
+	}
 
 	return r
 }
 
 func main() {
-	r := setupRouter()
+	cfg := configFromEnv()
+	log.Printf("OSRM HTTP timeout: %s", cfg.HTTPTimeout)
+	if cfg.FetchBudget != nil {
+		registerFetchBudgetMetrics(prometheus.DefaultRegisterer, cfg.FetchBudget)
+	}
+
+	rejectEdgeCoordinates, _ = strconv.ParseBool(os.Getenv("REJECT_EDGE_COORDINATES"))
+	debugTimings, _ = strconv.ParseBool(os.Getenv("DEBUG_TIMINGS"))
+	if n, err := strconv.Atoi(os.Getenv("LOG_SAMPLE_RATE")); err == nil && n > 0 {
+		logSampleRate = n
+	}
+
+	if n, err := strconv.Atoi(os.Getenv("JOB_CONCURRENCY")); err == nil && n > 0 {
+		jobConcurrency = n
+	}
+	if maxLimit, err := strconv.Atoi(os.Getenv("ADAPTIVE_CONCURRENCY_MAX")); err == nil && maxLimit > 0 {
+		minLimit, err := strconv.Atoi(os.Getenv("ADAPTIVE_CONCURRENCY_MIN"))
+		if err != nil || minLimit < 1 || minLimit > maxLimit {
+			minLimit = 1
+		}
+		adaptiveConcurrency = newAdaptiveLimiter(minLimit, maxLimit, 100)
+	}
+	keepCancelledJobResults, _ = strconv.ParseBool(os.Getenv("JOB_CANCEL_KEEP_RESULTS"))
+	if retention, err := time.ParseDuration(os.Getenv("JOB_RETENTION")); err == nil && retention > 0 {
+		jobStore = newMemoryJobStore(retention)
+	}
+	if accept, err := strconv.ParseBool(os.Getenv("BBOX_DESTINATIONS")); err == nil {
+		bboxDestinations = accept
+	}
+	if separators, ok := os.LookupEnv("COORDINATE_SEPARATORS"); ok {
+		coordinateSeparators = separators
+	}
+	if n, err := strconv.Atoi(os.Getenv("MAX_GEOMETRY_POINTS")); err == nil && n > 0 {
+		maxGeometryPoints = n
+	}
+	switch policy := os.Getenv("POST_QUERY_COORDINATES"); policy {
+	case queryCoordinatesBody, queryCoordinatesQuery:
+		postQueryCoordinates = policy
+	}
+	if os.Getenv("GEOMETRY_LIMIT_POLICY") == geometryLimitReject {
+		geometryLimitPolicy = geometryLimitReject
+	}
+	if url := os.Getenv("TIMEZONE_API_URL"); url != "" {
+		timezoneLookup = newCachedTimezoneLookup(&HTTPTimezoneLookup{URL: url}, defaultTimezoneCacheSize, defaultTimezoneCacheTTL)
+	}
+	if level, ok := parseLevel(os.Getenv("LOG_LEVEL")); ok {
+		osrmLog.level = level
+	}
+	if interval, err := time.ParseDuration(os.Getenv("PROGRESS_INTERVAL")); err == nil && interval > 0 {
+		progressInterval = interval
+	}
+	unitsFromLanguage, _ = strconv.ParseBool(os.Getenv("UNITS_FROM_ACCEPT_LANGUAGE"))
+	if n, err := strconv.Atoi(os.Getenv("MAX_MATRIX_CELLS")); err == nil && n > 0 {
+		maxMatrixCells = n
+	}
+	if url := os.Getenv("GEOCODER_URL"); url != "" {
+		geocoder = newCachedGeocoder(&HTTPGeocoder{URL: url}, defaultGeocoderCacheSize, defaultGeocoderCacheTTL)
+	}
+	if n, err := strconv.Atoi(os.Getenv("TRUNCATED_RESPONSE_RETRIES")); err == nil && n >= 0 {
+		truncatedRetries = n
+	}
+	if hint := os.Getenv("NO_SEGMENT_HINT"); hint != "" {
+		noSegmentHint = hint
+	}
+	if n, err := strconv.Atoi(os.Getenv("WEBHOOK_ATTEMPTS")); err == nil && n > 0 {
+		webhookAttempts = n
+	}
+	if hosts := os.Getenv("WEBHOOK_ALLOWED_HOSTS"); hosts != "" {
+		for _, host := range strings.Split(hosts, ",") {
+			webhookAllowedHosts = append(webhookAllowedHosts, strings.TrimSpace(host))
+		}
+	}
+
+	backendHashHeader = os.Getenv("BACKEND_HASH_HEADER")
+
+	apiKey = os.Getenv("API_KEY")
+	if header := os.Getenv("API_KEY_HEADER"); header != "" {
+		apiKeyHeader = header
+	}
+
+	if proxies := os.Getenv("TRUSTED_PROXIES"); proxies != "" {
+		for _, proxy := range strings.Split(proxies, ",") {
+			trustedProxies = append(trustedProxies, strings.TrimSpace(proxy))
+		}
+	}
+
+	if limit, err := strconv.Atoi(os.Getenv("DAILY_QUOTA")); err == nil && limit > 0 {
+		store, err := newMemoryQuotaStore(os.Getenv("DAILY_QUOTA_FILE"))
+		if err != nil {
+			log.Fatalf("could not load quota file: %s", err)
+		}
+		if interval, err := time.ParseDuration(os.Getenv("DAILY_QUOTA_FLUSH_INTERVAL")); err == nil && interval > 0 {
+			quotaFlushInterval = interval
+		}
+		go store.flushEvery(quotaFlushInterval)
+		dailyQuota = &DailyQuota{Limit: limit, Store: store, IPv4Prefix: 32, IPv6Prefix: 64}
+		if prefix, err := strconv.Atoi(os.Getenv("QUOTA_IPV4_PREFIX")); err == nil {
+			dailyQuota.IPv4Prefix = prefix
+		}
+		if prefix, err := strconv.Atoi(os.Getenv("QUOTA_IPV6_PREFIX")); err == nil {
+			dailyQuota.IPv6Prefix = prefix
+		}
+	}
+
+	if src, dst := os.Getenv("WARMUP_SRC"), os.Getenv("WARMUP_DST"); src != "" && dst != "" {
+		strict, _ := strconv.ParseBool(os.Getenv("WARMUP_STRICT"))
+		if err := runWarmUp(cfg, src, dst, strict); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	r := setupRouter(cfg)
 	r.Run()
 }
 
-func getRoutes(c *gin.Context) {
-	var query QueryParams
+func getRoutes(cfg Config) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var query QueryParams
+		err := c.ShouldBindQuery(&query)
+		respondRoutes(c, cfg, query, err)
+	}
+}
+
+// postRoutes takes the parameters of GET /routes as a JSON body, for batches too large for a URL
+func postRoutes(cfg Config) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var query QueryParams
+		err := c.ShouldBindJSON(&query)
+
+		// Coordinates in the query string as well are ambiguous, they're rejected unless configured to take precedence
+		src, hasSrc := c.GetQuery("src")
+		dsts, hasDst := c.GetQueryArray("dst")
+		if err == nil && (hasSrc || hasDst) {
+			switch postQueryCoordinates {
+			case queryCoordinatesBody:
+			case queryCoordinatesQuery:
+				if hasSrc {
+					query.Src = src
+				}
+				if hasDst {
+					query.Dst = dsts
+				}
+			default:
+				c.JSON(http.StatusBadRequest, ErrResp{
+					Code:      http.StatusBadRequest,
+					Message:   "Coordinates must be given either in the query string or in the body",
+					ErrorCode: errCodeInvalidParameter,
+				})
+				return
+			}
+		}
 
-	err := c.ShouldBindQuery(&query)
+		respondRoutes(c, cfg, query, err)
+	}
+}
+
+// respondRoutes answers GET and POST /routes once their parameters have been bound to query
+func respondRoutes(c *gin.Context, cfg Config, query QueryParams, err error) {
+	var timings PhaseTimings
+	start := time.Now()
+
+	// A source of nothing but whitespace passes the binding but normalizes to nothing to route from
+	if err == nil && strings.TrimSpace(query.Src) == "" {
+		c.JSON(http.StatusBadRequest, ErrResp{
+			Code:      http.StatusBadRequest,
+			Message:   "Src is empty once normalized",
+			ErrorCode: errCodeInvalidCoordinate,
+		})
+		return
+	}
+
+	// Checked before anything fans out, geocoding included
+	if err == nil && len(query.Dst) > cfg.MaxDestinations {
+		c.JSON(http.StatusBadRequest, ErrResp{
+			Code:      http.StatusBadRequest,
+			Message:   fmt.Sprintf("%d destinations are more than the maximum of %d", len(query.Dst), cfg.MaxDestinations),
+			ErrorCode: errCodeInvalidParameter,
+		})
+		return
+	}
+
+	// Addresses are resolved to coordinates before anything else looks at src and dst
+	geocoded := make(map[string]string)
+	if err == nil && query.Geocode {
+		if geocoder == nil {
+			c.JSON(http.StatusBadRequest, ErrResp{
+				Code:      http.StatusBadRequest,
+				Message:   "Geocoding is not available on this server",
+				ErrorCode: errCodeInvalidParameter,
+			})
+			return
+		}
+
+		srcs := []string{query.Src}
+		field, err := "Src", geocodeAddresses(c.Request.Context(), srcs, geocoded)
+		if err == nil {
+			field, err = "Dst", geocodeAddresses(c.Request.Context(), query.Dst, geocoded)
+		}
+		if err != nil {
+			c.JSON(http.StatusBadRequest, ErrResp{
+				Code:      http.StatusBadRequest,
+				Message:   fmt.Sprintf("%s could not be geocoded: %s", field, err),
+				ErrorCode: errCodeInvalidCoordinate,
+			})
+			return
+		}
+		query.Src = srcs[0]
+	}
+
+	altitudes := make(map[string]float64)
 	if err == nil {
+		query.Src = normalizeCoordinate(query.Src)
+		normalizeCoordinates(query.Dst)
+		normalizeCoordinates(query.Order)
+
+		// OSRM only routes on longitude and latitude, altitudes are taken off before validation
+		if cfg.CoordinateAltitudes != altitudeReject {
+			srcs := []string{query.Src}
+			field, err := "Src", stripAltitudes(srcs, altitudes)
+			if err == nil {
+				field, err = "Dst", stripAltitudes(query.Dst, altitudes)
+			}
+			if err == nil {
+				field, err = "Order", stripAltitudes(query.Order, nil)
+			}
+			if err != nil {
+				c.JSON(http.StatusBadRequest, ErrResp{
+					Code:      http.StatusBadRequest,
+					Message:   fmt.Sprintf("%s is not valid: %s", field, err),
+					ErrorCode: errCodeInvalidCoordinate,
+				})
+				return
+			}
+			query.Src = srcs[0]
+		}
+
+		// Bounding boxes are replaced by their center before the coordinates are validated
+		if bboxDestinations {
+			if err := replaceBBoxes(query.Dst); err != nil {
+				c.JSON(http.StatusBadRequest, ErrResp{
+					Code:      http.StatusBadRequest,
+					Message:   fmt.Sprintf("Dst is not valid: %s", err),
+					ErrorCode: errCodeInvalidCoordinate,
+				})
+				return
+			}
+		}
+
 		err = validate.Struct(query)
 	}
 
 	if err != nil {
 		c.JSON(http.StatusBadRequest, ErrResp{
-			Code:    http.StatusBadRequest,
-			Message: validationErrMsg(err),
+			Code:      http.StatusBadRequest,
+			Message:   validationErrMsg(err),
+			ErrorCode: validationErrCode(err),
 		})
 		return
 	}
 
-	routes := make([]Route, 0)
+	// Cursors resume the sort they were issued for, a client provided order has no sort key to resume from
+	sortBy := "duration"
+	if query.Sort != "" {
+		sortBy = query.Sort
+	}
+	var cursor *routeCursor
+	if query.Cursor != "" {
+		decoded, err := decodeRouteCursor(query.Cursor)
+		message := ""
+		switch {
+		case err != nil:
+			message = "Cursor is not valid"
+		case len(query.Order) > 0:
+			message = "Cursor can't be combined with order"
+		case decoded.sort != sortBy:
+			message = fmt.Sprintf("Cursor was issued for sort=%s", decoded.sort)
+		}
+		if message != "" {
+			c.JSON(http.StatusBadRequest, ErrResp{
+				Code:      http.StatusBadRequest,
+				Message:   message,
+				ErrorCode: errCodeInvalidParameter,
+			})
+			return
+		}
+		cursor = &decoded
+	}
 
-	var wg sync.WaitGroup
-	for _, dst := range query.Dst {
-		wg.Add(1)
-		go func(d string) {
-			defer wg.Done()
-			route, err := getRouteData(query.Src, d)
-			if err != nil {
-				// Here we could save errors to a []Error and handle them depending on requirements.
-				// For now, no individual errors will block the output.
-			} else {
-				routes = append(routes, route)
-			}
-		}(dst)
+	// A comparison isn't a list of routes, options shaping one are rejected rather than ignored
+	if conflict := compareConflict(query); query.Compare != "" && conflict != "" {
+		c.JSON(http.StatusBadRequest, ErrResp{
+			Code:      http.StatusBadRequest,
+			Message:   fmt.Sprintf("Compare can't be combined with %s", conflict),
+			ErrorCode: errCodeInvalidParameter,
+		})
+		return
+	}
+
+	// A destination given more than once is routed and reported once
+	query.Dst = uniqueCoordinates(query.Dst)
+
+	// The units parameter always wins over the language
+	if query.Units == "" && unitsFromLanguage {
+		query.Units = unitsForLanguage(c.GetHeader("Accept-Language"))
+	}
+
+	opts := RouteOptions{
+		RoadClasses:    query.RoadClasses,
+		Geometry:       query.Format == "gpx" || query.Preview || query.Geometry,
+		ReturnGeometry: query.Geometry,
+		Preview:        query.Preview,
+		Turns:          query.Turns,
+		Steps:          query.Steps,
+		Alternatives:   query.Alternatives,
+		Profile:        query.Profile,
+		BypassCache:    query.Cache != nil && !*query.Cache,
+		MaxStale:       maxStaleFromCacheControl(c.GetHeader("Cache-Control")),
+	}
+	fingerprint := requestFingerprint(requestID(c), query)
+	timings.since(&timings.Validation, start)
+
+	// The pre-filters below each keep at least one of the destinations, so there is always something to route.
+	// Only the destinations nearest as the crow flies are routed when prefiltering
+	start = time.Now()
+	dsts := query.Dst
+	var prefiltered []string
+	if query.PrefilterNearest > 0 {
+		dsts, prefiltered = nearestDestinations(query.Src, dsts, query.PrefilterNearest)
+	}
+
+	// Destinations close to each other are routed once through their cluster's representative
+	var clusters map[string][]string
+	if query.Cluster > 0 {
+		dsts, clusters = clusterDestinations(dsts, query.Cluster)
+	}
+
+	profiles := []string{opts.Profile}
+	if query.Compare != "" {
+		profiles = strings.Split(query.Compare, ",")
+	}
+
+	// Destinations over the maxCalls budget are reported back instead of routed
+	var skipped []string
+	if query.MaxCalls > 0 {
+		dsts, skipped = withinCallBudget(cfg, query.Src, dsts, opts, profiles, query.MaxCalls)
 	}
 
-	wg.Wait()
+	// The collection strategy can only be picked while debugging
+	collect := collectWithWaitGroup
+	if query.Debug && query.Strategy == strategyChannel {
+		collect = collectWithChannel
+	}
+	timings.since(&timings.Filter, start)
+
+	// Headers have to be set before any progress line is written
+	if backendHashHeader != "" {
+		c.Header(backendHashHeader, backendHash(osrmRouteURLs(cfg, query.Src, dsts, opts, profiles)))
+	}
+
+	// Progress lines keep the connection busy until the routes are collected
+	ctx := c.Request.Context()
+	stopProgress := func() {}
+	if query.Progress {
+		progress := &routeProgress{total: len(dsts)}
+		if query.Compare != "" {
+			progress.total *= 2
+		}
+		ctx = withRouteProgress(ctx, progress)
+		stopProgress = streamProgress(c, progress, progressInterval)
+	}
+
+	// A comparison answers with the differences between two profiles instead of the routes
+	if query.Compare != "" {
+		resp := compareProfiles(ctx, cfg, collect, query.Src, dsts, opts, profiles)
+		stopProgress()
+		if clusters != nil {
+			resp.Comparisons = expandComparisons(resp.Comparisons, clusters)
+			resp.Failures = expandFailures(resp.Failures, clusters)
+			skipped = expandSkipped(skipped, clusters)
+		}
+		resp.Excluded = filterExclusions(skipped, query.MaxCalls, prefiltered, query.PrefilterNearest)
+		resp.Skipped = append(skipped, prefiltered...)
+		if query.SpeedFactor != 0 {
+			applySpeedFactorToComparisons(resp.Comparisons, query.SpeedFactor)
+		}
+
+		if len(resp.Comparisons) == 0 && query.OnEmpty == "404" {
+			writeErrResp(c, ErrResp{
+				Code:      http.StatusNotFound,
+				Message:   "No routes found",
+				ErrorCode: errCodeNoRoutes,
+			})
+			return
+		}
+
+		resp.Warnings = queryWarnings(query)
+		if len(resp.Comparisons) == 0 {
+			resp.Warnings = append(resp.Warnings, "No routes found")
+		}
+		if len(geocoded) > 0 {
+			resp.Geocoded = geocoded
+		}
+		writeResponse(c, http.StatusOK, resp, RenderOptions{Naming: query.Naming, Pretty: query.Pretty})
+		return
+	}
+
+	start = time.Now()
+	routes, failures := collect(ctx, cfg, query.Src, dsts, opts)
+	stopProgress()
+	timings.since(&timings.Fetch, start)
+
+	if query.SpeedFactor != 0 {
+		applySpeedFactor(routes, query.SpeedFactor)
+	}
+	if query.Units != "" {
+		addUnits(routes, query.Units)
+	}
+
+	start = time.Now()
+	if clusters != nil {
+		routes = expandClusters(routes, clusters)
+		failures = expandFailures(failures, clusters)
+		skipped = expandSkipped(skipped, clusters)
+	}
+	excluded := filterExclusions(skipped, query.MaxCalls, prefiltered, query.PrefilterNearest)
+	skipped = append(skipped, prefiltered...)
+
+	// Routes over the limits are reported like the destinations that couldn't be routed
+	if query.MaxDuration > 0 || query.MaxDistance > 0 {
+		var exceeded []Failure
+		routes, exceeded = filterByLimits(routes, query.MaxDuration, query.MaxDistance)
+		failures = append(failures, exceeded...)
+		excluded = append(excluded, limitExclusions(exceeded)...)
+	}
 
+	// Clients can choose between a 404 and an empty 200 with a warning when nothing could be routed
+	if len(routes) == 0 && query.OnEmpty == "404" {
+		writeErrResp(c, ErrResp{
+			Code:      http.StatusNotFound,
+			Message:   "No routes found",
+			ErrorCode: errCodeNoRoutes,
+		})
+		return
+	}
+
+	timings.since(&timings.Filter, start)
+
+	start = time.Now()
+	resp := newGetRoutesResp(query.Src, routes, failures, query.Dst)
+	if query.Sort == "distance" {
+		resp.sortRoutesByDistanceAsc()
+	}
+	timings.since(&timings.Sort, start)
+	resp.Warnings = queryWarnings(query)
+	if len(geocoded) > 0 {
+		resp.Geocoded = geocoded
+	}
+
+	if query.Timezone && timezoneLookup == nil {
+		resp.Warnings = append(resp.Warnings, "Time zones are not available on this server")
+	} else if query.Timezone {
+		resp.Warnings = append(resp.Warnings, addTimezones(c.Request.Context(), cfg, resp.Routes)...)
+	}
+
+	if len(query.Bands) > 0 {
+		if resp.Metadata == nil {
+			resp.Metadata = &Metadata{}
+		}
+		resp.Metadata.Reachability = reachability(routes, query.Bands)
+	}
+
+	if cfg.CoordinateAltitudes == altitudeKeep && len(altitudes) > 0 {
+		if resp.Metadata == nil {
+			resp.Metadata = &Metadata{}
+		}
+		resp.Metadata.Altitudes = altitudes
+	}
+
+	if engine, ok := engineInfos.get(cfg.OsrmBaseURL); ok {
+		if resp.Metadata == nil {
+			resp.Metadata = &Metadata{}
+		}
+		resp.Metadata.Engine = &engine
+	}
+
+	// A client provided order replaces the sorting by duration
+	start = time.Now()
+	if len(query.Order) > 0 {
+		resp.Routes = orderRoutes(resp.Routes, query.Order, query.Unordered == "omit")
+		sortFailures(resp.Failures, append(query.Order, query.Dst...))
+	}
+
+	// A page starts after the cursor's route and hands out a cursor to its last one when more routes follow
+	if cursor != nil {
+		resp.Routes = routesAfter(resp.Routes, *cursor)
+	}
+
+	// Truncated once sorted, so the limit keeps the fastest or nearest routes
+	if query.Limit > 0 && len(resp.Routes) > query.Limit {
+		resp.Routes = resp.Routes[:query.Limit]
+		if len(query.Order) == 0 {
+			resp.NextCursor = newRouteCursor(sortBy, resp.Routes[query.Limit-1]).encode()
+		}
+	}
+	timings.since(&timings.Sort, start)
+	resp.Skipped = skipped
+	resp.Excluded = excluded
+
+	if len(routes) == 0 {
+		resp.Warnings = append(resp.Warnings, "No routes found")
+	}
+
+	// The ETag covers every route, a client polling with it only gets the routes that changed since
+	// and the destinations that are no longer routed
+	etag := routesETag(resp.Source, responseRepresentation(c, query), resp.Routes)
+	routeSnapshots.save(etag, resp.Routes)
+	if query.Since != "" {
+		start = time.Now()
+		if previous, ok := routeSnapshots.get(parseETag(query.Since)); ok {
+			resp.Routes, resp.Removed = changedRoutes(previous, resp.Routes)
+			resp.Delta = true
+		}
+		timings.since(&timings.Filter, start)
+	}
+	c.Header("ETag", `"`+etag+`"`)
+
+	if query.Debug {
+		resp.Debug = effectiveOptions(cfg, query)
+	}
+	if query.Echo {
+		request := replayableQuery(query)
+		resp.Request = &request
+	}
+
+	log.Printf("fingerprint=%s src=%s destinations=%d routes=%d failures=%d",
+		fingerprint, query.Src, len(query.Dst), len(resp.Routes), len(resp.Failures))
+	if query.Fingerprint {
+		if resp.Metadata == nil {
+			resp.Metadata = &Metadata{}
+		}
+		resp.Metadata.Fingerprint = fingerprint
+	}
+
+	// A body can't carry the time it takes to encode itself, so the marshal phase is measured on a first encoding
+	if query.Timings {
+		resp.Timings = &timings
+		start = time.Now()
+		marshalWithNaming(resp, query.Naming)
+		timings.since(&timings.Marshal, start)
+	}
+
+	switch {
+	case query.Format == "kml":
+		writeKML(c, resp)
+	case query.Format == "gpx":
+		writeGPX(c, resp)
+	case query.Format == "geojson" || acceptsGeoJSON(c):
+		writeGeoJSON(c, resp)
+	case query.Format == "csv":
+		writeCSV(c, resp)
+	case acceptsProtobuf(c):
+		c.Data(http.StatusOK, protobufContentType, resp.marshalProto())
+	case query.Flat:
+		writeResponse(c, http.StatusOK, resp.flatten(), RenderOptions{Naming: query.Naming, Pretty: query.Pretty})
+	case query.GroupByGrid != nil:
+		writeResponse(c, http.StatusOK, resp.groupByGrid(*query.GroupByGrid), RenderOptions{Naming: query.Naming, Pretty: query.Pretty})
+	case query.Keyed:
+		writeResponse(c, http.StatusOK, resp.keyByDestination(), RenderOptions{Naming: query.Naming, Pretty: query.Pretty})
+	default:
+		writeResponse(c, http.StatusOK, resp, RenderOptions{Naming: query.Naming, Pretty: query.Pretty})
+	}
+}
+
+// newGetRoutesResp builds the sorted response for the routes found from src to dsts
+func newGetRoutesResp(src string, routes []Route, failures []Failure, dsts []string) GetRoutesResp {
 	var resp = GetRoutesResp{
-		Source: query.Src,
-		Routes: routes,
+		Source:   src,
+		Routes:   routes,
+		Failures: failures,
+	}
+
+	sortFailures(resp.Failures, dsts)
+
+	if ratio := detourRatio(src, routes); ratio != nil {
+		resp.Metadata = &Metadata{DetourRatio: ratio}
+	}
+
+	// All routes are computed with the same profile, so any route can tell what the weight means
+	for _, route := range routes {
+		if route.weightName != "" {
+			resp.WeightName = route.weightName
+			break
+		}
 	}
 
 	resp.sortRoutesByDurationAsc()
 
-	c.JSON(http.StatusOK, resp)
+	return resp
 }
 
-func getRouteData(src string, dst string) (Route, error) {
-	url := fmt.Sprintf(osrmApiUrl, src, dst)
+func writeResponse(c *gin.Context, code int, obj any, opts RenderOptions) {
+	body, err := marshalWithNaming(obj, opts.Naming)
+	if err == nil && opts.Pretty {
+		var indented bytes.Buffer
+		err = json.Indent(&indented, body, "", "    ")
+		body = indented.Bytes()
+	}
 
-	resp, body, err := makeRequestWith429Retries(url)
 	if err != nil {
-		return Route{}, err
+		writeErrResp(c, ErrResp{
+			Code:      http.StatusInternalServerError,
+			Message:   "Could not encode response",
+			ErrorCode: errCodeInternal,
+		})
+		return
 	}
 
-	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusBadRequest {
-		return Route{}, fmt.Errorf("response code: %d", resp.StatusCode)
+	c.Data(code, "application/json; charset=utf-8", body)
+}
+
+// withinCallBudget keeps the destinations routed with at most maxCalls of them calling OSRM and
+// skips the rest. Destinations whose routes are cached don't call OSRM, so they are always kept.
+func withinCallBudget(cfg Config, src string, dsts []string, opts RouteOptions, profiles []string, maxCalls int) (routed []string, skipped []string) {
+	calls := 0
+	for _, dst := range dsts {
+		switch {
+		case isRouteCached(cfg, src, dst, opts, profiles):
+			routed = append(routed, dst)
+		case calls < maxCalls:
+			routed = append(routed, dst)
+			calls++
+		default:
+			skipped = append(skipped, dst)
+		}
 	}
 
-	var data OsrmApiRouteData
-	err = json.Unmarshal(body, &data)
+	return routed, skipped
+}
+
+// getRouteData routes src to dst, recording the OSRM call in the structured log. The caller holds a
+// unit of cfg.FetchBudget, taken before the goroutine making the call was started.
+func getRouteData(ctx context.Context, cfg Config, src string, dst string, opts RouteOptions) (Route, error) {
+	var stats osrmCallStats
+	start := time.Now()
+	if adaptiveConcurrency != nil {
+		if err := adaptiveConcurrency.acquire(ctx); err != nil {
+			logOsrmCall(src, dst, &stats, time.Since(start), err)
+			return Route{}, err
+		}
+		defer adaptiveConcurrency.release()
+	}
+
+	route, err := fetchRouteData(withOsrmCallStats(ctx, &stats), cfg, src, dst, opts)
+	logOsrmCall(src, dst, &stats, time.Since(start), err)
+
+	return route, err
+}
+
+// osrmRouteURL returns the URL of the OSRM route service call for src to dst along with its parameters
+func osrmRouteURL(cfg Config, src string, dst string, opts RouteOptions) (string, url.Values) {
+	params := url.Values{}
+	if opts.Geometry {
+		params.Set("overview", "full")
+		params.Set("geometries", "geojson")
+	} else {
+		params.Set("overview", "false")
+	}
+	if opts.RoadClasses || opts.Turns || opts.Steps {
+		// Road classes and maneuvers are only reported on the steps
+		params.Set("steps", "true")
+	}
+	if opts.Alternatives > 0 {
+		params.Set("alternatives", strconv.Itoa(opts.Alternatives))
+	}
+
+	profile := opts.Profile
+	if profile == "" {
+		profile = osrmProfiles[0]
+	}
+
+	return cfg.OsrmBaseURL + fmt.Sprintf(osrmRoutePath, profile, src, dst) + "?" + params.Encode(), params
+}
+
+func fetchRouteData(ctx context.Context, cfg Config, src string, dst string, opts RouteOptions) (Route, error) {
+	// The OSRM URL holds the backend, profile, source and destination, but options such as preview
+	// and turns are derived from the same answer, so they are part of the cache key too
+	osrmURL, params := osrmRouteURL(cfg, src, dst, opts)
+	cacheKey := routeCacheKey(osrmURL, opts)
+	if cfg.RouteCache != nil && !opts.BypassCache {
+		route, ok := cfg.RouteCache.getStale(cacheKey, opts.MaxStale)
+		metrics.observeRouteCache(ok)
+		if ok {
+			if stats := osrmCallStatsFrom(ctx); stats != nil {
+				stats.cached = true
+			}
+			// The cached route is shared, it gets a flag of its own
+			cached := true
+			route.Cached = &cached
+			return route, nil
+		}
+	}
+
+	resp, data, err := fetchOsrmRoute(ctx, cfg, osrmURL)
+	if errors.Is(err, errRetriesExhausted) {
+		log.Printf("OSRM rate limit: giving up on %s: %s", dst, err)
+	}
 	if err != nil {
 		return Route{}, err
 	}
+	engineInfos.observe(cfg.OsrmBaseURL, resp.Header.Get("Server"), data.DataVersion)
 
 	if data.Code != "Ok" {
-		return Route{}, fmt.Errorf("response code: %d. message: %s", resp.StatusCode, data.Message)
+		routeErr := &RouteError{
+			Category: osrmErrCategory(data.Code),
+			Message:  fmt.Sprintf("response code: %d. message: %s", resp.StatusCode, data.Message),
+		}
+		switch routeErr.Category {
+		case categoryNoSegment:
+			routeErr.Hint = noSegmentHint
+		case categoryInvalidValue:
+			// OSRM doesn't say which value it rejected, the parameters that were sent narrow it down
+			routeErr.Parameters = osrmParameters(params)
+		}
+		return Route{}, routeErr
+	}
+
+	// OSRM can answer Ok without any route for degenerate inputs
+	if len(data.Routes) == 0 {
+		return Route{}, &RouteError{
+			Category: categoryNoRoute,
+			Message:  fmt.Sprintf("response code: %d. message: no routes returned", resp.StatusCode),
+		}
 	}
 
 	route := Route{
 		Destination: dst,
 		Duration:    data.Routes[0].Duration,
 		Distance:    data.Routes[0].Distance,
+		Weight:      data.Routes[0].Weight,
+		weightName:  data.Routes[0].WeightName,
+	}
+
+	// Implausibly short durations are raised to the floor so they aren't taken as instantaneous
+	if route.Duration < cfg.MinDuration {
+		route.Duration = cfg.MinDuration
+		route.Floored = true
+	}
+
+	// OSRM returns the fastest route first, followed by at most the requested number of alternatives
+	if opts.Alternatives > 0 {
+		route.Alternatives = make([]Alternative, 0)
+		for i := 1; i < len(data.Routes) && i <= opts.Alternatives; i++ {
+			route.Alternatives = append(route.Alternatives, Alternative{
+				Duration: data.Routes[i].Duration,
+				Distance: data.Routes[i].Distance,
+			})
+		}
+	}
+
+	if opts.Geometry {
+		for _, point := range data.Routes[0].Geometry.Coordinates {
+			if len(point) == 2 {
+				route.geometry = append(route.geometry, Coordinate{Lng: point[0], Lat: point[1]})
+			}
+		}
+		if opts.Preview {
+			route.Preview = routePreview(route.geometry)
+		}
+		if err := limitGeometry(&route); err != nil {
+			return Route{}, err
+		}
+		if opts.ReturnGeometry {
+			route.Geometry = make([][2]float64, len(route.geometry))
+			for i, point := range route.geometry {
+				route.Geometry[i] = [2]float64{point.Lng, point.Lat}
+			}
+		}
+	}
+
+	// The last waypoint is where OSRM snapped the destination onto the road network
+	if n := len(data.Waypoints); n > 0 && len(data.Waypoints[n-1].Location) == 2 {
+		input, err := parseCoordinate(dst)
+		if err == nil {
+			snapped := Coordinate{Lng: data.Waypoints[n-1].Location[0], Lat: data.Waypoints[n-1].Location[1]}
+			snapDistance := math.Round(haversineDistance(input, snapped)*100) / 100
+			route.SnapDistance = &snapDistance
+		}
+	}
+
+	if opts.RoadClasses {
+		var hasToll, usesMotorway bool
+		for _, leg := range data.Routes[0].Legs {
+			for _, step := range leg.Steps {
+				for _, intersection := range step.Intersections {
+					for _, class := range intersection.Classes {
+						switch class {
+						case "toll":
+							hasToll = true
+						case "motorway":
+							usesMotorway = true
+						}
+					}
+				}
+			}
+		}
+		route.HasToll = &hasToll
+		route.UsesMotorway = &usesMotorway
+	}
+
+	if opts.Turns {
+		// Any maneuver changing direction counts, departing and arriving don't
+		turns := 0
+		for _, leg := range data.Routes[0].Legs {
+			for _, step := range leg.Steps {
+				switch {
+				case step.Maneuver.Type == "depart" || step.Maneuver.Type == "arrive":
+				case step.Maneuver.Modifier == "" || step.Maneuver.Modifier == "straight":
+				default:
+					turns++
+				}
+			}
+		}
+		route.Turns = &turns
+	}
+
+	if opts.Steps {
+		route.Steps = make([]Step, 0)
+		for _, leg := range data.Routes[0].Legs {
+			for _, step := range leg.Steps {
+				route.Steps = append(route.Steps, Step{
+					Type:     step.Maneuver.Type,
+					Modifier: step.Maneuver.Modifier,
+					Name:     step.Name,
+					Distance: step.Distance,
+					Duration: step.Duration,
+				})
+			}
+		}
+	}
+
+	if cfg.RouteCache != nil {
+		cached := false
+		route.Cached = &cached
+		cfg.RouteCache.put(cacheKey, route)
 	}
 
 	return route, nil
 }
 
-func makeRequestWith429Retries(url string) (*http.Response, []byte, error) {
-	var (
-		body []byte
-		err  error
-		resp *http.Response
-	)
-	attempts := 20
-	backoffTime := 1 * time.Second
+// fetchOsrmRoute calls OSRM and decodes its response, retrying responses that were truncated
+func fetchOsrmRoute(ctx context.Context, cfg Config, url string) (*http.Response, OsrmApiRouteData, error) {
+	for attempt := 0; ; attempt++ {
+		resp, data, err := fetchOsrmRouteOnce(ctx, cfg, url)
+		if !errors.Is(err, errTruncatedResponse) || attempt >= truncatedRetries {
+			return resp, data, err
+		}
+		if stats := osrmCallStatsFrom(ctx); stats != nil {
+			stats.retries++
+		}
+	}
+}
+
+func fetchOsrmRouteOnce(ctx context.Context, cfg Config, url string) (*http.Response, OsrmApiRouteData, error) {
+	var data OsrmApiRouteData
+
+	resp, body, err := makeRequestWith429Retries(ctx, cfg, url)
+	if err != nil {
+		return nil, data, err
+	}
+
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusBadRequest {
+		return nil, data, fmt.Errorf("response code: %d", resp.StatusCode)
+	}
+
+	if err := json.Unmarshal(body, &data); err != nil {
+		if truncatedJSON(body) {
+			return nil, data, fmt.Errorf("%w: %s", errTruncatedResponse, err)
+		}
+		return nil, data, err
+	}
+
+	return resp, data, nil
+}
+
+// truncatedJSON tells a body that ends before its JSON value is complete from one that is malformed
+func truncatedJSON(body []byte) bool {
+	var v any
+	err := json.NewDecoder(bytes.NewReader(body)).Decode(&v)
+
+	return errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF)
+}
+
+// makeRequestWith429Retries calls url until it is answered with something other than a 429, waiting
+// an exponentially growing backoff between the attempts. errRetriesExhausted is returned when every
+// attempt was answered with a 429, and the context's error as soon as ctx is done.
+func makeRequestWith429Retries(ctx context.Context, cfg Config, url string) (*http.Response, []byte, error) {
+	cfg = cfg.withDefaults()
+
+	for i := 0; i < cfg.RetryAttempts; i++ {
+		if i > 0 {
+			metrics.observeOsrmRetry()
+			if stats := osrmCallStatsFrom(ctx); stats != nil {
+				stats.retries++
+			}
+			select {
+			case <-time.After(cfg.retryBackoff(i - 1)):
+			case <-ctx.Done():
+				return nil, nil, ctx.Err()
+			}
+		}
+
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+		if err != nil {
+			return nil, nil, err
+		}
 
-	for i := 0; i < attempts; i++ {
-		resp, err = httpClient.Get(url)
+		start := time.Now()
+		resp, err := cfg.osrmClient().Do(req)
+		elapsed := time.Since(start)
+		osrmLatencies.record(elapsed)
 		if err != nil {
 			return nil, nil, err
 		}
+		metrics.observeOsrmCall(elapsed, resp.StatusCode)
+		if stats := osrmCallStatsFrom(ctx); stats != nil {
+			stats.status = resp.StatusCode
+		}
+
+		if adaptiveConcurrency != nil {
+			adaptiveConcurrency.observe(resp.StatusCode == http.StatusTooManyRequests)
+		}
 
 		if resp.StatusCode == http.StatusTooManyRequests {
-			time.Sleep(backoffTime)
+			resp.Body.Close()
 			continue
 		}
 
 		defer resp.Body.Close()
-		body, err = io.ReadAll(resp.Body)
+		body, err := io.ReadAll(resp.Body)
+		if errors.Is(err, io.ErrUnexpectedEOF) {
+			// The connection was closed before the whole body announced by Content-Length arrived
+			return nil, nil, fmt.Errorf("%w: %s", errTruncatedResponse, err)
+		}
 		if err != nil {
 			return nil, nil, err
 		}
-		break
+
+		return resp, body, nil
 	}
 
-	return resp, body, nil
+	return nil, nil, fmt.Errorf("%w: %d attempts answered with 429", errRetriesExhausted, cfg.RetryAttempts)
 }
 
 func (o *GetRoutesResp) sortRoutesByDurationAsc() {
 	sort.Slice(o.Routes, func(i, j int) bool {
-		// Sort by duration if distance is equal
+		// Sort by distance if duration is equal, and by destination if both are, so cursors see a single order
 		if o.Routes[i].Duration == o.Routes[j].Duration {
+			if o.Routes[i].Distance == o.Routes[j].Distance {
+				return o.Routes[i].Destination < o.Routes[j].Destination
+			}
 			return o.Routes[i].Distance < o.Routes[j].Distance
 		}
 
@@ -188,6 +1310,21 @@ func (o *GetRoutesResp) sortRoutesByDurationAsc() {
 	})
 }
 
+func (o *GetRoutesResp) sortRoutesByDistanceAsc() {
+	sort.Slice(o.Routes, func(i, j int) bool {
+		// Sort by duration if distance is equal, and by destination if both are, so cursors see a single order
+		if o.Routes[i].Distance == o.Routes[j].Distance {
+			if o.Routes[i].Duration == o.Routes[j].Duration {
+				return o.Routes[i].Destination < o.Routes[j].Destination
+			}
+			return o.Routes[i].Duration < o.Routes[j].Duration
+		}
+
+		// Sort by distance
+		return o.Routes[i].Distance < o.Routes[j].Distance
+	})
+}
+
 // latLng should have the pattern 13.388860,52.517037
 func validateLatLng(fl validator.FieldLevel) bool {
 	switch v := fl.Field().Interface().(type) {
@@ -207,14 +1344,125 @@ func validateLatLng(fl validator.FieldLevel) bool {
 	}
 }
 
+// Edge coordinates are only rejected when rejectEdgeCoordinates is enabled
+func validateNoEdge(fl validator.FieldLevel) bool {
+	if !rejectEdgeCoordinates {
+		return true
+	}
+
+	switch v := fl.Field().Interface().(type) {
+	case string:
+		return !isEdgeCoordinate(v)
+	case []string:
+		for _, str := range v {
+			if isEdgeCoordinate(str) {
+				return false
+			}
+		}
+		return true
+	default:
+		// Unknown type
+		return false
+	}
+}
+
+// profile must be one of osrmProfiles
+func validateProfile(fl validator.FieldLevel) bool {
+	profile := fl.Field().String()
+	for _, p := range osrmProfiles {
+		if profile == p {
+			return true
+		}
+	}
+
+	return false
+}
+
+// profiles must be two different profiles of osrmProfiles separated by a comma
+func validateProfiles(fl validator.FieldLevel) bool {
+	profiles := strings.Split(fl.Field().String(), ",")
+	if len(profiles) != 2 || profiles[0] == profiles[1] {
+		return false
+	}
+	for _, profile := range profiles {
+		known := false
+		for _, p := range osrmProfiles {
+			known = known || profile == p
+		}
+		if !known {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Coordinates are read in lng,lat order, the same as parseCoordinate and OSRM
+func isEdgeCoordinate(lngLat string) bool {
+	c, err := parseCoordinate(lngLat)
+	if err != nil {
+		return false
+	}
+
+	return math.Abs(c.Lat) == 90 || math.Abs(c.Lng) == 180
+}
+
+// queryWarnings collects notices about the query that don't prevent it from being served
+func queryWarnings(query QueryParams) []string {
+	var warnings []string
+
+	if precisionExceeded(query.Src) {
+		warnings = append(warnings, precisionWarning("Src", query.Src))
+	}
+	for _, dst := range query.Dst {
+		if precisionExceeded(dst) {
+			warnings = append(warnings, precisionWarning("Dst", dst))
+		}
+	}
+
+	return warnings
+}
+
+func precisionExceeded(latLng string) bool {
+	for _, part := range strings.Split(latLng, ",") {
+		if i := strings.Index(part, "."); i >= 0 && len(part)-i-1 > maxCoordinatePrecision {
+			return true
+		}
+	}
+
+	return false
+}
+
+// The coordinate is routed as given, the warning only points out that it is more precise than OSRM
+func precisionWarning(field string, latLng string) string {
+	return fmt.Sprintf("%s %s has more than %d decimal places, OSRM ignores the extra ones", field, latLng, maxCoordinatePrecision)
+}
+
 func validationErrMsg(err error) string {
-	errs := err.(validator.ValidationErrors)
+	var errs validator.ValidationErrors
+	if !errors.As(err, &errs) {
+		// Binding errors, e.g. a boolean parameter that can't be parsed
+		return fmt.Sprintf("Invalid request: %s", err)
+	}
+
 	for _, e := range errs {
 		switch e.Tag() {
 		case "required":
 			return fmt.Sprintf("%s is a required field", e.Field())
 		case "latlng":
 			return fmt.Sprintf("%s is not a valid latitude and longitude", e.Field())
+		case "noedge":
+			return fmt.Sprintf("%s is at a pole or on the antimeridian", e.Field())
+		case "min":
+			return fmt.Sprintf("%s must be at least %s", e.Field(), e.Param())
+		case "profile":
+			return fmt.Sprintf("%s must be one of %s", e.Field(), strings.Join(osrmProfiles, ", "))
+		case "profiles":
+			return fmt.Sprintf("%s must be two different profiles of %s separated by a comma", e.Field(), strings.Join(osrmProfiles, ", "))
+		case "gt":
+			return fmt.Sprintf("%s must be greater than %s", e.Field(), e.Param())
+		case "max":
+			return fmt.Sprintf("%s must be at most %s", e.Field(), e.Param())
 		default:
 			return fmt.Sprintf("%s is not valid", e.Field())
 		}
@@ -222,3 +1470,19 @@ func validationErrMsg(err error) string {
 
 	return "Unknown error"
 }
+
+func validationErrCode(err error) string {
+	var errs validator.ValidationErrors
+	if !errors.As(err, &errs) || len(errs) == 0 {
+		return errCodeInvalidParameter
+	}
+
+	switch errs[0].Tag() {
+	case "required":
+		return errCodeMissingParameter
+	case "latlng", "noedge":
+		return errCodeInvalidCoordinate
+	default:
+		return errCodeInvalidParameter
+	}
+}
@@ -1,17 +1,23 @@
 package main
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
 
 var (
-	router = setupRouter()
+	router = setupRouter(configFromEnv())
 )
 
 func mockGetRoutesRequest(url string) *httptest.ResponseRecorder {
@@ -46,6 +52,84 @@ func TestGetRoutesReturns400WhenLatLongIsInvalid(t *testing.T) {
 	assert.Equal(t, http.StatusBadRequest, rec.Code)
 }
 
+func TestGetRoutesReturnsErrorCodes(t *testing.T) {
+	tests := map[string]string{
+		"/routes?dst=13.397634,52.529407":                                      `{"code":400,"message":"Src is a required field","error_code":"missing_parameter"}`,
+		"/routes?src=13.388860,52.517037&dst=invalid":                          `{"code":400,"message":"Dst is not a valid latitude and longitude","error_code":"invalid_coordinate"}`,
+		"/routes?src=13.388860,52.517037&dst=13.397634,52.529407&naming=kebab": `{"code":400,"message":"Naming is not valid","error_code":"invalid_parameter"}`,
+	}
+
+	for url, expectedResp := range tests {
+		rec := mockGetRoutesRequest(url)
+
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		assert.Equal(t, expectedResp, rec.Body.String(), url)
+	}
+}
+
+func TestGetRoutesReturns400WhenParamCannotBeParsed(t *testing.T) {
+	rec := mockGetRoutesRequest("/routes?src=13.388860,52.517037&dst=13.397634,52.529407&roadClasses=maybe")
+
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"error_code":"invalid_parameter"`)
+}
+
+func TestGetRoutesAcceptsEdgeCoordinatesByDefault(t *testing.T) {
+	mockOsrmApi := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"code":"Ok", "routes": [{"duration":2490.1,"distance":3286.3}]}`))
+	}))
+	defer mockOsrmApi.Close()
+
+	router = setupRouter(Config{OsrmBaseURL: mockOsrmApi.URL})
+
+	rec := mockGetRoutesRequest("/routes?src=13.388860,52.517037&dst=13.397634,90.0")
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	rec = mockGetRoutesRequest("/routes?src=13.388860,52.517037&dst=13.397634,-90")
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	rec = mockGetRoutesRequest("/routes?src=180,0&dst=-180,0")
+	assert.Equal(t, http.StatusOK, rec.Code)
+}
+
+func TestGetRoutesReturns400ForEdgeCoordinatesWhenRejected(t *testing.T) {
+	rejectEdgeCoordinates = true
+	defer func() { rejectEdgeCoordinates = false }()
+
+	rec := mockGetRoutesRequest("/routes?src=13.388860,-90&dst=13.397634,52.529407")
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Equal(t, `{"code":400,"message":"Src is at a pole or on the antimeridian","error_code":"invalid_coordinate"}`, rec.Body.String())
+
+	rec = mockGetRoutesRequest("/routes?src=13.388860,52.517037&dst=13.397634,52.529407&dst=13.397634,90.0")
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Equal(t, `{"code":400,"message":"Dst is at a pole or on the antimeridian","error_code":"invalid_coordinate"}`, rec.Body.String())
+
+	rec = mockGetRoutesRequest("/routes?src=180,0&dst=13.397634,52.529407")
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Equal(t, `{"code":400,"message":"Src is at a pole or on the antimeridian","error_code":"invalid_coordinate"}`, rec.Body.String())
+
+	rec = mockGetRoutesRequest("/routes?src=13.388860,52.517037&dst=-180,0")
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Equal(t, `{"code":400,"message":"Dst is at a pole or on the antimeridian","error_code":"invalid_coordinate"}`, rec.Body.String())
+}
+
+func TestGetRoutesAcceptsLongitude90WhenEdgeCoordinatesRejected(t *testing.T) {
+	rejectEdgeCoordinates = true
+	defer func() { rejectEdgeCoordinates = false }()
+
+	mockOsrmApi := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"code":"Ok", "routes": [{"duration":2490.1,"distance":3286.3}]}`))
+	}))
+	defer mockOsrmApi.Close()
+
+	router = setupRouter(Config{OsrmBaseURL: mockOsrmApi.URL})
+
+	rec := mockGetRoutesRequest("/routes?src=90,52.517037&dst=-90.0,52.529407")
+	assert.Equal(t, http.StatusOK, rec.Code)
+}
+
 func TestGetRoutesReturns200(t *testing.T) {
 	osrmApiPath := "/route/v1/driving/%s;%s"
 	src := "13.388860,52.517037"
@@ -92,34 +176,467 @@ func TestGetRoutesReturns200(t *testing.T) {
 	}))
 	defer mockOsrmApi.Close()
 
-	osrmApiUrl = mockOsrmApi.URL + osrmApiPath
+	router = setupRouter(Config{OsrmBaseURL: mockOsrmApi.URL})
 	rec := mockGetRoutesRequest(fmt.Sprintf("/routes?src=%s&dst=%s&dst=%s&dst=%s&dst=%s", src, dst1, dst2, dst3, dst4))
 
 	assert.Equal(t, http.StatusOK, rec.Code)
 
-	expectedResp := `{"source":"13.388860,52.517037","routes":[{"destination":"12.428555,52.523219","duration":260.1,"distance":1886.3},{"destination":"10.428555,29.523219","duration":2015.1,"distance":6523.3},{"destination":"13.397634,52.529407","duration":2490.1,"distance":3286.3}]}`
+	expectedResp := `{"source":"13.388860,52.517037","routes":[{"destination":"12.428555,52.523219","duration":260.1,"distance":1886.3},{"destination":"10.428555,29.523219","duration":2015.1,"distance":6523.3},{"destination":"13.397634,52.529407","duration":2490.1,"distance":3286.3}],"failures":[{"destination":"13.428555,48.523219","status":502,"category":"backend_error","message":"response code: 400. message: Query string malformed close to position 57"}],"metadata":{"detourRatio":0}}`
+	assert.Equal(t, expectedResp, rec.Body.String())
+}
+
+func TestGetRoutesReturnsRoadClassesWhenRequested(t *testing.T) {
+	src := "13.388860,52.517037"
+	dst := "13.397634,52.529407"
+
+	mockOsrmApi := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "true", r.URL.Query().Get("steps"))
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"code":"Ok", "routes": [{"duration":2490.1,"distance":3286.3,"legs":[{"steps":[
+			{"intersections":[{"classes":["motorway"]},{}]},
+			{"intersections":[{"classes":["toll","motorway"]}]}
+		]}]}]}`))
+	}))
+	defer mockOsrmApi.Close()
+
+	router = setupRouter(Config{OsrmBaseURL: mockOsrmApi.URL})
+	rec := mockGetRoutesRequest(fmt.Sprintf("/routes?src=%s&dst=%s&roadClasses=true", src, dst))
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	expectedResp := `{"source":"13.388860,52.517037","routes":[{"destination":"13.397634,52.529407","duration":2490.1,"distance":3286.3,"hasToll":true,"usesMotorway":true}],"metadata":{"detourRatio":2.19}}`
+	assert.Equal(t, expectedResp, rec.Body.String())
+}
+
+func TestGetRoutesReturnsTurnsWhenRequested(t *testing.T) {
+	src := "13.388860,52.517037"
+	dst := "13.397634,52.529407"
+
+	mockOsrmApi := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "true", r.URL.Query().Get("steps"))
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"code":"Ok", "routes": [{"duration":2490.1,"distance":3286.3,"legs":[{"steps":[
+			{"maneuver":{"type":"depart","modifier":"left"}},
+			{"maneuver":{"type":"turn","modifier":"right"}},
+			{"maneuver":{"type":"new name","modifier":"straight"}},
+			{"maneuver":{"type":"roundabout","modifier":"slight left"}},
+			{"maneuver":{"type":"continue","modifier":"uturn"}},
+			{"maneuver":{"type":"arrive","modifier":"right"}}
+		]}]}]}`))
+	}))
+	defer mockOsrmApi.Close()
+
+	router = setupRouter(Config{OsrmBaseURL: mockOsrmApi.URL})
+	rec := mockGetRoutesRequest(fmt.Sprintf("/routes?src=%s&dst=%s&turns=true", src, dst))
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	expectedResp := `{"source":"13.388860,52.517037","routes":[{"destination":"13.397634,52.529407","duration":2490.1,"distance":3286.3,"turns":3}],"metadata":{"detourRatio":2.19}}`
+	assert.Equal(t, expectedResp, rec.Body.String())
+}
+
+func TestGetRoutesReturnsStepsWhenRequested(t *testing.T) {
+	src := "13.388860,52.517037"
+	dst := "13.397634,52.529407"
+
+	mockOsrmApi := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "true", r.URL.Query().Get("steps"))
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"code":"Ok", "routes": [{"duration":2490.1,"distance":3286.3,"legs":[{"steps":[
+			{"name":"Unter den Linden","distance":1200.5,"duration":900.2,"maneuver":{"type":"depart","modifier":"left"}},
+			{"name":"Friedrichstraße","distance":2085.8,"duration":1589.9,"maneuver":{"type":"turn","modifier":"right"}},
+			{"name":"","distance":0,"duration":0,"maneuver":{"type":"arrive"}}
+		]}]}]}`))
+	}))
+	defer mockOsrmApi.Close()
+
+	router = setupRouter(Config{OsrmBaseURL: mockOsrmApi.URL})
+	rec := mockGetRoutesRequest(fmt.Sprintf("/routes?src=%s&dst=%s&steps=true", src, dst))
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	expectedResp := `{"source":"13.388860,52.517037","routes":[{"destination":"13.397634,52.529407","duration":2490.1,"distance":3286.3,"steps":[` +
+		`{"type":"depart","modifier":"left","name":"Unter den Linden","distance":1200.5,"duration":900.2},` +
+		`{"type":"turn","modifier":"right","name":"Friedrichstraße","distance":2085.8,"duration":1589.9},` +
+		`{"type":"arrive","distance":0,"duration":0}]}],"metadata":{"detourRatio":2.19}}`
+	assert.Equal(t, expectedResp, rec.Body.String())
+}
+
+func TestGetRoutesReturnsAlternativesWhenRequested(t *testing.T) {
+	src := "13.388860,52.517037"
+	dst := "13.397634,52.529407"
+
+	mockOsrmApi := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "2", r.URL.Query().Get("alternatives"))
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"code":"Ok", "routes": [{"duration":2490.1,"distance":3286.3},{"duration":2601.7,"distance":3011.2},{"duration":2750.4,"distance":3590.8}]}`))
+	}))
+	defer mockOsrmApi.Close()
+
+	router = setupRouter(Config{OsrmBaseURL: mockOsrmApi.URL})
+	rec := mockGetRoutesRequest(fmt.Sprintf("/routes?src=%s&dst=%s&alternatives=2", src, dst))
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	expectedResp := `{"source":"13.388860,52.517037","routes":[{"destination":"13.397634,52.529407","duration":2490.1,"distance":3286.3,"alternatives":[` +
+		`{"duration":2601.7,"distance":3011.2},{"duration":2750.4,"distance":3590.8}]}],"metadata":{"detourRatio":2.19}}`
+	assert.Equal(t, expectedResp, rec.Body.String())
+}
+
+func TestGetRoutesReturnsSingleRouteByDefault(t *testing.T) {
+	mockOsrmApi := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.False(t, r.URL.Query().Has("alternatives"))
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"code":"Ok", "routes": [{"duration":2490.1,"distance":3286.3},{"duration":2601.7,"distance":3011.2}]}`))
+	}))
+	defer mockOsrmApi.Close()
+
+	router = setupRouter(Config{OsrmBaseURL: mockOsrmApi.URL})
+	rec := mockGetRoutesRequest("/routes?src=13.388860,52.517037&dst=13.397634,52.529407")
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.NotContains(t, rec.Body.String(), "alternatives")
+
+	rec = mockGetRoutesRequest("/routes?src=13.388860,52.517037&dst=13.397634,52.529407&alternatives=4")
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+}
+
+func TestGetRoutesOmitsRoadClassesByDefault(t *testing.T) {
+	src := "13.388860,52.517037"
+	dst := "13.397634,52.529407"
+
+	mockOsrmApi := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Empty(t, r.URL.Query().Get("steps"))
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"code":"Ok", "routes": [{"duration":2490.1,"distance":3286.3}]}`))
+	}))
+	defer mockOsrmApi.Close()
+
+	router = setupRouter(Config{OsrmBaseURL: mockOsrmApi.URL})
+	rec := mockGetRoutesRequest(fmt.Sprintf("/routes?src=%s&dst=%s", src, dst))
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	expectedResp := `{"source":"13.388860,52.517037","routes":[{"destination":"13.397634,52.529407","duration":2490.1,"distance":3286.3}],"metadata":{"detourRatio":2.19}}`
+	assert.Equal(t, expectedResp, rec.Body.String())
+}
+
+func TestGetRoutesReturnsWarningsForHighPrecisionCoordinates(t *testing.T) {
+	src := "13.3888601234,52.517037"
+	dst := "13.397634,52.52940712"
+
+	mockOsrmApi := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"code":"Ok", "routes": [{"duration":2490.1,"distance":3286.3}]}`))
+	}))
+	defer mockOsrmApi.Close()
+
+	router = setupRouter(Config{OsrmBaseURL: mockOsrmApi.URL})
+	rec := mockGetRoutesRequest(fmt.Sprintf("/routes?src=%s&dst=%s", src, dst))
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	expectedResp := `{"source":"13.3888601234,52.517037","routes":[{"destination":"13.397634,52.52940712","duration":2490.1,"distance":3286.3}],"warnings":["Src 13.3888601234,52.517037 has more than 6 decimal places, OSRM ignores the extra ones","Dst 13.397634,52.52940712 has more than 6 decimal places, OSRM ignores the extra ones"],"metadata":{"detourRatio":2.19}}`
+	assert.Equal(t, expectedResp, rec.Body.String())
+}
+
+func TestGetRoutesReturnsSnapDistanceFromWaypoints(t *testing.T) {
+	src := "13.388860,52.517037"
+	dst := "13.397634,52.529407"
+
+	mockOsrmApi := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"code":"Ok", "routes": [{"duration":2490.1,"distance":3286.3}],
+			"waypoints": [{"location":[13.388860,52.517037]},{"location":[13.397634,52.529507]}]}`))
+	}))
+	defer mockOsrmApi.Close()
+
+	router = setupRouter(Config{OsrmBaseURL: mockOsrmApi.URL})
+	rec := mockGetRoutesRequest(fmt.Sprintf("/routes?src=%s&dst=%s", src, dst))
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	expectedResp := `{"source":"13.388860,52.517037","routes":[{"destination":"13.397634,52.529407","duration":2490.1,"distance":3286.3,"snapDistance":11.12}],"metadata":{"detourRatio":2.19}}`
 	assert.Equal(t, expectedResp, rec.Body.String())
 }
 
+func TestGetRoutesReturnsSnakeCaseKeysWhenRequested(t *testing.T) {
+	src := "13.388860,52.517037"
+	dst := "13.397634,52.529407"
+
+	mockOsrmApi := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"code":"Ok", "routes": [{"duration":2490.1,"distance":3286.3}],
+			"waypoints": [{"location":[13.388860,52.517037]},{"location":[13.397634,52.529507]}]}`))
+	}))
+	defer mockOsrmApi.Close()
+
+	router = setupRouter(Config{OsrmBaseURL: mockOsrmApi.URL})
+	rec := mockGetRoutesRequest(fmt.Sprintf("/routes?src=%s&dst=%s&naming=snake", src, dst))
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "application/json; charset=utf-8", rec.Header().Get("Content-Type"))
+
+	expectedResp := `{"source":"13.388860,52.517037","routes":[{"destination":"13.397634,52.529407","duration":2490.1,"distance":3286.3,"snap_distance":11.12}],"metadata":{"detour_ratio":2.19}}`
+	assert.Equal(t, expectedResp, rec.Body.String())
+}
+
+func TestGetRoutesReturns400WhenNamingIsInvalid(t *testing.T) {
+	rec := mockGetRoutesRequest("/routes?src=13.388860,52.517037&dst=13.397634,52.529407&naming=kebab")
+
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+}
+
+func TestGetRoutesReturnsWeightNameWhenPresent(t *testing.T) {
+	src := "13.388860,52.517037"
+	dst := "13.397634,52.529407"
+
+	mockOsrmApi := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"code":"Ok", "routes": [{"duration":2490.1,"distance":3286.3,"weight":2610.4,"weight_name":"routability"}]}`))
+	}))
+	defer mockOsrmApi.Close()
+
+	router = setupRouter(Config{OsrmBaseURL: mockOsrmApi.URL})
+	rec := mockGetRoutesRequest(fmt.Sprintf("/routes?src=%s&dst=%s", src, dst))
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	expectedResp := `{"source":"13.388860,52.517037","weightName":"routability","routes":[{"destination":"13.397634,52.529407","duration":2490.1,"distance":3286.3,"weight":2610.4}],"metadata":{"detourRatio":2.19}}`
+	assert.Equal(t, expectedResp, rec.Body.String())
+}
+
+func TestGetRoutesSkipsDestinationsOverMaxCalls(t *testing.T) {
+	src := "13.388860,52.517037"
+	dst1 := "13.397634,52.529407"
+	dst2 := "12.428555,52.523219"
+	dst3 := "13.428555,48.523219"
+
+	var calls int32
+	mockOsrmApi := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"code":"Ok", "routes": [{"duration":2490.1,"distance":3286.3}]}`))
+	}))
+	defer mockOsrmApi.Close()
+
+	router = setupRouter(Config{OsrmBaseURL: mockOsrmApi.URL})
+	rec := mockGetRoutesRequest(fmt.Sprintf("/routes?src=%s&dst=%s&dst=%s&dst=%s&maxCalls=1", src, dst1, dst2, dst3))
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&calls))
+
+	expectedResp := `{"source":"13.388860,52.517037","routes":[{"destination":"13.397634,52.529407","duration":2490.1,"distance":3286.3}],"skipped":["12.428555,52.523219","13.428555,48.523219"],` +
+		`"excluded":[{"destination":"12.428555,52.523219","excludedReason":"routing it would exceed maxCalls of 1"},` +
+		`{"destination":"13.428555,48.523219","excludedReason":"routing it would exceed maxCalls of 1"}],"metadata":{"detourRatio":2.19}}`
+	assert.Equal(t, expectedResp, rec.Body.String())
+}
+
+func TestGetRoutesDoesNotCountCachedRoutesAgainstMaxCalls(t *testing.T) {
+	src := "13.388860,52.517037"
+	dst1 := "13.397634,52.529407"
+	dst2 := "12.428555,52.523219"
+	dst3 := "13.428555,48.523219"
+
+	var calls int32
+	mockOsrmApi := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"code":"Ok", "routes": [{"duration":2490.1,"distance":3286.3}]}`))
+	}))
+	defer mockOsrmApi.Close()
+
+	router = setupRouter(Config{OsrmBaseURL: mockOsrmApi.URL, RouteCache: newRouteLRU(100, time.Minute)})
+
+	// Caches the routes to dst1 and dst2
+	rec := mockGetRoutesRequest(fmt.Sprintf("/routes?src=%s&dst=%s&dst=%s", src, dst1, dst2))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, int32(2), atomic.LoadInt32(&calls))
+
+	rec = mockGetRoutesRequest(fmt.Sprintf("/routes?src=%s&dst=%s&dst=%s&dst=%s&maxCalls=1", src, dst1, dst2, dst3))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, int32(3), atomic.LoadInt32(&calls))
+
+	var resp GetRoutesResp
+	assert.Nil(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	assert.Len(t, resp.Routes, 3)
+	assert.Empty(t, resp.Skipped)
+
+	// Only the cached route is free, the budget still applies to the others
+	rec = mockGetRoutesRequest(fmt.Sprintf("/routes?src=%s&dst=%s&dst=%s&dst=%s&maxCalls=1", src, dst1, "13.1,52.1", "13.2,52.2"))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, int32(4), atomic.LoadInt32(&calls))
+
+	resp = GetRoutesResp{}
+	assert.Nil(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	assert.Len(t, resp.Routes, 2)
+	assert.Equal(t, []string{"13.2,52.2"}, resp.Skipped)
+}
+
+func TestGetRoutesReturns400WhenMaxCallsIsNegative(t *testing.T) {
+	rec := mockGetRoutesRequest("/routes?src=13.388860,52.517037&dst=13.397634,52.529407&maxCalls=-1")
+
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Equal(t, `{"code":400,"message":"MaxCalls must be at least 0","error_code":"invalid_parameter"}`, rec.Body.String())
+}
+
+func TestGetRoutesReturnsFastestRoutesUpToLimit(t *testing.T) {
+	mockOsrmApi := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		switch {
+		case strings.Contains(r.URL.Path, "13.397634,52.529407"):
+			w.Write([]byte(`{"code":"Ok", "routes": [{"duration":300.1,"distance":1000.1}]}`))
+		case strings.Contains(r.URL.Path, "12.428555,52.523219"):
+			w.Write([]byte(`{"code":"Ok", "routes": [{"duration":100.1,"distance":500.1}]}`))
+		default:
+			w.Write([]byte(`{"code":"Ok", "routes": [{"duration":200.1,"distance":800.1}]}`))
+		}
+	}))
+	defer mockOsrmApi.Close()
+
+	router = setupRouter(Config{OsrmBaseURL: mockOsrmApi.URL})
+	url := "/routes?src=13.388860,52.517037&dst=13.397634,52.529407&dst=12.428555,52.523219&dst=13.428555,48.523219"
+
+	var resp GetRoutesResp
+	rec := mockGetRoutesRequest(url + "&limit=2")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	json.Unmarshal(rec.Body.Bytes(), &resp)
+	assert.Equal(t, []string{"12.428555,52.523219", "13.428555,48.523219"}, routeDestinations(resp.Routes))
+
+	resp = GetRoutesResp{}
+	rec = mockGetRoutesRequest(url + "&limit=0")
+	json.Unmarshal(rec.Body.Bytes(), &resp)
+	assert.Len(t, resp.Routes, 3)
+}
+
+func TestGetRoutesReturns400WhenLimitIsNegative(t *testing.T) {
+	rec := mockGetRoutesRequest("/routes?src=13.388860,52.517037&dst=13.397634,52.529407&limit=-1")
+
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Equal(t, `{"code":400,"message":"Limit must be at least 0","error_code":"invalid_parameter"}`, rec.Body.String())
+}
+
+func TestGetRoutesReturnsIndentedJsonWhenPretty(t *testing.T) {
+	src := "13.388860,52.517037"
+	dst := "13.397634,52.529407"
+
+	mockOsrmApi := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"code":"Ok", "routes": [{"duration":2490.1,"distance":3286.3}]}`))
+	}))
+	defer mockOsrmApi.Close()
+
+	router = setupRouter(Config{OsrmBaseURL: mockOsrmApi.URL})
+	rec := mockGetRoutesRequest(fmt.Sprintf("/routes?src=%s&dst=%s&pretty=true", src, dst))
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	expectedResp := `{
+    "source": "13.388860,52.517037",
+    "routes": [
+        {
+            "destination": "13.397634,52.529407",
+            "duration": 2490.1,
+            "distance": 3286.3
+        }
+    ],
+    "metadata": {
+        "detourRatio": 2.19
+    }
+}`
+	assert.Equal(t, expectedResp, rec.Body.String())
+}
+
+func TestGetRoutesEchoesEffectiveOptionsWhenDebug(t *testing.T) {
+	src := "13.388860,52.517037"
+	dst := "13.397634,52.529407"
+
+	mockOsrmApi := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"code":"Ok", "routes": [{"duration":2490.1,"distance":3286.3}]}`))
+	}))
+	defer mockOsrmApi.Close()
+
+	router = setupRouter(Config{OsrmBaseURL: mockOsrmApi.URL})
+	rec := mockGetRoutesRequest(fmt.Sprintf("/routes?src=%s&dst=%s&debug=true&maxCalls=5&roadClasses=true", src, dst))
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	var resp GetRoutesResp
+	json.Unmarshal(rec.Body.Bytes(), &resp)
+
+	expectedOptions := &EffectiveOptions{
+		Sort:            "duration",
+		Profile:         "driving",
+		Naming:          "camel",
+		RoadClasses:     true,
+		MaxCalls:        5,
+		OnEmpty:         "ok",
+		Strategy:        "waitgroup",
+		Timeout:         "10s",
+		RetryAttempts:   20,
+		RetryBackoff:    "1s",
+		RetryMaxBackoff: "30s",
+		MaxConcurrency:  16,
+	}
+	assert.Equal(t, expectedOptions, resp.Debug)
+
+	// The unit system inferred from the language is the one echoed
+	defer func() { unitsFromLanguage = false }()
+	unitsFromLanguage = true
+
+	rec = httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodGet, fmt.Sprintf("/routes?src=%s&dst=%s&debug=true", src, dst), nil)
+	req.Header.Set("Accept-Language", "en-US,en;q=0.9")
+	router.ServeHTTP(rec, req)
+
+	resp = GetRoutesResp{}
+	json.Unmarshal(rec.Body.Bytes(), &resp)
+	assert.Equal(t, "imperial", resp.Debug.Units)
+
+	rec = mockGetRoutesRequest(fmt.Sprintf("/routes?src=%s&dst=%s&debug=true&units=metric", src, dst))
+	resp = GetRoutesResp{}
+	json.Unmarshal(rec.Body.Bytes(), &resp)
+	assert.Equal(t, "metric", resp.Debug.Units)
+}
+
+func TestGetRoutesReturnsEmptyOrNotFoundWhenNoRoutesRemain(t *testing.T) {
+	mockOsrmApi := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"code":"NoRoute", "message": "Impossible route between points"}`))
+	}))
+	defer mockOsrmApi.Close()
+
+	router = setupRouter(Config{OsrmBaseURL: mockOsrmApi.URL})
+
+	rec := mockGetRoutesRequest("/routes?src=13.388860,52.517037&dst=13.397634,52.529407")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, `{"source":"13.388860,52.517037","routes":[],"warnings":["No routes found"],"failures":[{"destination":"13.397634,52.529407","status":422,"category":"no_route","message":"response code: 400. message: Impossible route between points"}]}`, rec.Body.String())
+
+	rec = mockGetRoutesRequest("/routes?src=13.388860,52.517037&dst=13.397634,52.529407&onEmpty=404")
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+	assert.Equal(t, `{"code":404,"message":"No routes found","error_code":"no_routes"}`, rec.Body.String())
+}
+
 func TestSortRoutesByDurationAsc(t *testing.T) {
 	routes := []Route{
-		{"13.397634,52.529407", 500, 100},
-		{"13.397634,52.529407", 200, 300},
-		{"13.397634,52.529407", 200, 100},
-		{"13.397634,52.529407", 100, 10},
-		{"13.397634,52.529407", 200, 50},
-		{"13.397634,52.529407", 200, 100},
-		{"13.397634,52.529407", 100, 100},
+		{Destination: "13.397634,52.529407", Duration: 500, Distance: 100},
+		{Destination: "13.397634,52.529407", Duration: 200, Distance: 300},
+		{Destination: "13.397634,52.529407", Duration: 200, Distance: 100},
+		{Destination: "13.397634,52.529407", Duration: 100, Distance: 10},
+		{Destination: "13.397634,52.529407", Duration: 200, Distance: 50},
+		{Destination: "13.397634,52.529407", Duration: 200, Distance: 100},
+		{Destination: "13.397634,52.529407", Duration: 100, Distance: 100},
 	}
 
 	expectedRoutes := []Route{
-		{"13.397634,52.529407", 100, 10},
-		{"13.397634,52.529407", 100, 100},
-		{"13.397634,52.529407", 200, 50},
-		{"13.397634,52.529407", 200, 100},
-		{"13.397634,52.529407", 200, 100},
-		{"13.397634,52.529407", 200, 300},
-		{"13.397634,52.529407", 500, 100},
+		{Destination: "13.397634,52.529407", Duration: 100, Distance: 10},
+		{Destination: "13.397634,52.529407", Duration: 100, Distance: 100},
+		{Destination: "13.397634,52.529407", Duration: 200, Distance: 50},
+		{Destination: "13.397634,52.529407", Duration: 200, Distance: 100},
+		{Destination: "13.397634,52.529407", Duration: 200, Distance: 100},
+		{Destination: "13.397634,52.529407", Duration: 200, Distance: 300},
+		{Destination: "13.397634,52.529407", Duration: 500, Distance: 100},
 	}
 
 	var output = GetRoutesResp{
@@ -135,3 +652,376 @@ func TestSortRoutesByDurationAsc(t *testing.T) {
 		t.Fatal("Sort order is not equal to", expectedRoutes)
 	}
 }
+
+func TestSortRoutesByDistanceAsc(t *testing.T) {
+	routes := []Route{
+		{Destination: "13.397634,52.529407", Duration: 100, Distance: 500},
+		{Destination: "13.397634,52.529407", Duration: 300, Distance: 200},
+		{Destination: "13.397634,52.529407", Duration: 100, Distance: 200},
+		{Destination: "13.397634,52.529407", Duration: 10, Distance: 100},
+	}
+
+	expectedRoutes := []Route{
+		{Destination: "13.397634,52.529407", Duration: 10, Distance: 100},
+		{Destination: "13.397634,52.529407", Duration: 100, Distance: 200},
+		{Destination: "13.397634,52.529407", Duration: 300, Distance: 200},
+		{Destination: "13.397634,52.529407", Duration: 100, Distance: 500},
+	}
+
+	var output = GetRoutesResp{
+		Source: "13.388860,52.517037",
+		Routes: routes,
+	}
+
+	output.sortRoutesByDistanceAsc()
+
+	assert.Equal(t, expectedRoutes, output.Routes)
+}
+
+func TestGetRoutesSortsByDistanceWhenRequested(t *testing.T) {
+	mockOsrmApi := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		if strings.Contains(r.URL.Path, "13.397634,52.529407") {
+			w.Write([]byte(`{"code":"Ok", "routes": [{"duration":100.1,"distance":900.1}]}`))
+		} else {
+			w.Write([]byte(`{"code":"Ok", "routes": [{"duration":200.1,"distance":400.1}]}`))
+		}
+	}))
+	defer mockOsrmApi.Close()
+
+	router = setupRouter(Config{OsrmBaseURL: mockOsrmApi.URL})
+	url := "/routes?src=13.388860,52.517037&dst=13.397634,52.529407&dst=12.428555,52.523219"
+
+	var resp GetRoutesResp
+	json.Unmarshal(mockGetRoutesRequest(url).Body.Bytes(), &resp)
+	assert.Equal(t, []string{"13.397634,52.529407", "12.428555,52.523219"}, routeDestinations(resp.Routes))
+
+	resp = GetRoutesResp{}
+	json.Unmarshal(mockGetRoutesRequest(url+"&sort=distance").Body.Bytes(), &resp)
+	assert.Equal(t, []string{"12.428555,52.523219", "13.397634,52.529407"}, routeDestinations(resp.Routes))
+}
+
+func TestGetRoutesReturns400WhenSortIsInvalid(t *testing.T) {
+	rec := mockGetRoutesRequest("/routes?src=13.388860,52.517037&dst=13.397634,52.529407&sort=weight")
+
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Equal(t, `{"code":400,"message":"Sort is not valid","error_code":"invalid_parameter"}`, rec.Body.String())
+}
+
+func TestGetRoutesNormalizesCoordinateSeparators(t *testing.T) {
+	var paths []string
+	var mu sync.Mutex
+	mockOsrmApi := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		paths = append(paths, r.URL.Path)
+		mu.Unlock()
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"code":"Ok", "routes": [{"duration":2490.1,"distance":3286.3}]}`))
+	}))
+	defer mockOsrmApi.Close()
+
+	router = setupRouter(Config{OsrmBaseURL: mockOsrmApi.URL})
+	rec := mockGetRoutesRequest("/routes?src=13.388860+52.517037&dst=13.397634%3B52.529407")
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, []string{"/route/v1/driving/13.388860,52.517037;13.397634,52.529407"}, paths)
+	assert.Contains(t, rec.Body.String(), `{"source":"13.388860,52.517037","routes":[{"destination":"13.397634,52.529407"`)
+}
+
+func TestGetRoutesReturns400WhenSrcNormalizesToNothing(t *testing.T) {
+	var osrmCalls int32
+	mockOsrmApi := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&osrmCalls, 1)
+	}))
+	defer mockOsrmApi.Close()
+
+	router = setupRouter(Config{OsrmBaseURL: mockOsrmApi.URL})
+
+	rec := mockGetRoutesRequest("/routes?src=%20%09%20&dst=13.397634,52.529407")
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Equal(t, `{"code":400,"message":"Src is empty once normalized","error_code":"invalid_coordinate"}`, rec.Body.String())
+
+	rec = mockGetRoutesRequest("/routes?src=%20,%20&dst=13.397634,52.529407")
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Equal(t, `{"code":400,"message":"Src is not a valid latitude and longitude","error_code":"invalid_coordinate"}`, rec.Body.String())
+
+	assert.Equal(t, int32(0), atomic.LoadInt32(&osrmCalls))
+}
+
+func TestGetRoutesRoutesDuplicateDestinationsOnce(t *testing.T) {
+	var paths []string
+	var mu sync.Mutex
+	mockOsrmApi := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		paths = append(paths, r.URL.Path)
+		mu.Unlock()
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"code":"Ok", "routes": [{"duration":2490.1,"distance":3286.3}]}`))
+	}))
+	defer mockOsrmApi.Close()
+
+	router = setupRouter(Config{OsrmBaseURL: mockOsrmApi.URL})
+
+	// The second destination only differs by its separator, so it's the same once normalized
+	rec := mockGetRoutesRequest("/routes?src=13.388860,52.517037&dst=13.397634,52.529407&dst=13.397634+52.529407&dst=13.397634,52.529407")
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, []string{"/route/v1/driving/13.388860,52.517037;13.397634,52.529407"}, paths)
+	assert.Contains(t, rec.Body.String(), `"routes":[{"destination":"13.397634,52.529407","duration":2490.1,"distance":3286.3}]`)
+}
+
+func TestGetRoutesUsesRequestedProfile(t *testing.T) {
+	var paths []string
+	var mu sync.Mutex
+	mockOsrmApi := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		paths = append(paths, r.URL.Path)
+		mu.Unlock()
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"code":"Ok", "routes": [{"duration":2490.1,"distance":3286.3}]}`))
+	}))
+	defer mockOsrmApi.Close()
+
+	router = setupRouter(Config{OsrmBaseURL: mockOsrmApi.URL})
+
+	rec := mockGetRoutesRequest("/routes?src=13.388860,52.517037&dst=13.397634,52.529407&profile=cycling")
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	rec = mockGetRoutesRequest("/routes?src=13.388860,52.517037&dst=13.397634,52.529407")
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	assert.Equal(t, []string{
+		"/route/v1/cycling/13.388860,52.517037;13.397634,52.529407",
+		"/route/v1/driving/13.388860,52.517037;13.397634,52.529407",
+	}, paths)
+}
+
+func TestGetRoutesReturns400WhenProfileIsUnsupported(t *testing.T) {
+	rec := mockGetRoutesRequest("/routes?src=13.388860,52.517037&dst=13.397634,52.529407&profile=flying")
+
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Equal(t, `{"code":400,"message":"Profile must be one of driving, walking, cycling","error_code":"invalid_parameter"}`, rec.Body.String())
+}
+
+func mockPostRoutesRequest(body string) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodPost, "/routes", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(rec, req)
+
+	return rec
+}
+
+func TestPostRoutesReturnsSameResponseAsGet(t *testing.T) {
+	mockOsrmApi := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		if strings.Contains(r.URL.Path, "13.397634,52.529407") {
+			w.Write([]byte(`{"code":"Ok", "routes": [{"duration":2490.1,"distance":3286.3}]}`))
+			return
+		}
+		w.Write([]byte(`{"code":"Ok", "routes": [{"duration":260.1,"distance":1886.3}]}`))
+	}))
+	defer mockOsrmApi.Close()
+
+	router = setupRouter(Config{OsrmBaseURL: mockOsrmApi.URL})
+
+	get := mockGetRoutesRequest("/routes?src=13.388860,52.517037&dst=13.397634,52.529407&dst=13.428555,52.523219")
+	post := mockPostRoutesRequest(`{"src":"13.388860,52.517037","dst":["13.397634,52.529407","13.428555,52.523219"]}`)
+
+	assert.Equal(t, http.StatusOK, post.Code)
+	assert.Equal(t, get.Body.String(), post.Body.String())
+}
+
+func TestPostRoutesReturns400WhenBodyIsInvalid(t *testing.T) {
+	rec := mockPostRoutesRequest(`{"src":"13.388860,52.517037","dst":["invalid"]}`)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Equal(t, `{"code":400,"message":"Dst is not a valid latitude and longitude","error_code":"invalid_coordinate"}`, rec.Body.String())
+
+	rec = mockPostRoutesRequest(`{"src":`)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Equal(t, `{"code":400,"message":"Invalid request: unexpected EOF","error_code":"invalid_parameter"}`, rec.Body.String())
+
+	// An empty list is as missing as a GET without dst
+	rec = mockPostRoutesRequest(`{"src":"13.388860,52.517037","dst":[]}`)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Equal(t, `{"code":400,"message":"Dst must be at least 1","error_code":"invalid_parameter"}`, rec.Body.String())
+}
+
+func TestPostRoutesHandlesCoordinatesInQueryString(t *testing.T) {
+	var paths []string
+	var mu sync.Mutex
+	mockOsrmApi := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		paths = append(paths, r.URL.Path)
+		mu.Unlock()
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"code":"Ok", "routes": [{"duration":2490.1,"distance":3286.3}]}`))
+	}))
+	defer mockOsrmApi.Close()
+
+	router = setupRouter(Config{OsrmBaseURL: mockOsrmApi.URL})
+	defer func() { postQueryCoordinates = queryCoordinatesReject }()
+
+	post := func() *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodPost, "/routes?dst=13.428555,52.523219", strings.NewReader(`{"src":"13.388860,52.517037","dst":["13.397634,52.529407"]}`))
+		req.Header.Set("Content-Type", "application/json")
+		router.ServeHTTP(rec, req)
+
+		return rec
+	}
+
+	rec := post()
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Equal(t, `{"code":400,"message":"Coordinates must be given either in the query string or in the body","error_code":"invalid_parameter"}`, rec.Body.String())
+	assert.Empty(t, paths)
+
+	postQueryCoordinates = queryCoordinatesBody
+	rec = post()
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, []string{"/route/v1/driving/13.388860,52.517037;13.397634,52.529407"}, paths)
+
+	paths = nil
+	postQueryCoordinates = queryCoordinatesQuery
+	rec = post()
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, []string{"/route/v1/driving/13.388860,52.517037;13.428555,52.523219"}, paths)
+}
+
+func TestGetRoutesRoutesToBBoxCenter(t *testing.T) {
+	var paths []string
+	var mu sync.Mutex
+	mockOsrmApi := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		paths = append(paths, r.URL.Path)
+		mu.Unlock()
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"code":"Ok", "routes": [{"duration":2490.1,"distance":3286.3}]}`))
+	}))
+	defer mockOsrmApi.Close()
+
+	router = setupRouter(Config{OsrmBaseURL: mockOsrmApi.URL})
+	rec := mockGetRoutesRequest("/routes?src=13.388860,52.517037&dst=13.38,52.51,13.40,52.53")
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, []string{"/route/v1/driving/13.388860,52.517037;13.39,52.52"}, paths)
+	assert.Contains(t, rec.Body.String(), `"routes":[{"destination":"13.39,52.52"`)
+
+	rec = mockGetRoutesRequest("/routes?src=13.388860,52.517037&dst=13.40,52.51,13.38,52.53")
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Equal(t, `{"code":400,"message":"Dst is not valid: degenerate bounding box: 13.40,52.51,13.38,52.53","error_code":"invalid_coordinate"}`, rec.Body.String())
+}
+
+func TestMakeRequestStopsRetryingWhenContextIsCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	var calls int32
+	mockOsrmApi := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		cancel()
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer mockOsrmApi.Close()
+
+	cfg := Config{OsrmBaseURL: mockOsrmApi.URL, RetryBackoff: time.Hour}
+	_, _, err := makeRequestWith429Retries(ctx, cfg, mockOsrmApi.URL)
+
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&calls))
+}
+
+func TestGetRoutesMakesNoCallsOnceClientIsGone(t *testing.T) {
+	var calls int32
+	mockOsrmApi := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"code":"Ok", "routes": [{"duration":2490.1,"distance":3286.3}]}`))
+	}))
+	defer mockOsrmApi.Close()
+
+	router = setupRouter(Config{OsrmBaseURL: mockOsrmApi.URL})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	rec := httptest.NewRecorder()
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, "/routes?src=13.388860,52.517037&dst=13.397634,52.529407&dst=12.428555,52.523219", nil)
+	router.ServeHTTP(rec, req)
+
+	assert.Equal(t, int32(0), atomic.LoadInt32(&calls))
+	assert.Contains(t, rec.Body.String(), `"category":"backend_error","message":"Get \"`+mockOsrmApi.URL)
+	assert.Contains(t, rec.Body.String(), `context canceled"`)
+}
+
+func TestGetRoutesRaisesDurationsBelowFloor(t *testing.T) {
+	mockOsrmApi := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		if strings.Contains(r.URL.Path, "13.388861,52.517037") {
+			w.Write([]byte(`{"code":"Ok", "routes": [{"duration":0.4,"distance":0.1}]}`))
+			return
+		}
+		w.Write([]byte(`{"code":"Ok", "routes": [{"duration":2490.1,"distance":3286.3}]}`))
+	}))
+	defer mockOsrmApi.Close()
+
+	router = setupRouter(Config{OsrmBaseURL: mockOsrmApi.URL, MinDuration: 30})
+	rec := mockGetRoutesRequest("/routes?src=13.388860,52.517037&dst=13.388861,52.517037&dst=13.397634,52.529407")
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"routes":[`+
+		`{"destination":"13.388861,52.517037","duration":30,"distance":0.1,"floored":true},`+
+		`{"destination":"13.397634,52.529407","duration":2490.1,"distance":3286.3}]`)
+}
+
+func TestRoutesReturns400WhenTooManyDestinations(t *testing.T) {
+	var calls int32
+	mockOsrmApi := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"code":"Ok", "routes": [{"duration":260.1,"distance":1886.3}]}`))
+	}))
+	defer mockOsrmApi.Close()
+
+	router = setupRouter(Config{OsrmBaseURL: mockOsrmApi.URL, MaxDestinations: 2})
+	expectedResp := `{"code":400,"message":"3 destinations are more than the maximum of 2","error_code":"invalid_parameter"}`
+
+	rec := mockGetRoutesRequest("/routes?src=13.388860,52.517037&dst=13.1,52.1&dst=13.2,52.2&dst=13.3,52.3")
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Equal(t, expectedResp, rec.Body.String())
+
+	rec = mockPostRoutesRequest(`{"src":"13.388860,52.517037","dst":["13.1,52.1","13.2,52.2","13.3,52.3"]}`)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Equal(t, expectedResp, rec.Body.String())
+	assert.Equal(t, int32(0), atomic.LoadInt32(&calls))
+
+	rec = mockGetRoutesRequest("/routes?src=13.388860,52.517037&dst=13.1,52.1&dst=13.2,52.2")
+	assert.Equal(t, http.StatusOK, rec.Code)
+}
+
+func TestPreFiltersAlwaysKeepADestination(t *testing.T) {
+	src := "13.388860,52.517037"
+	dsts := []string{"13.397634,52.529407", "13.397734,52.529407", "12.428555,52.523219"}
+
+	// The tightest settings of prefilterNearest and cluster combined, maxCalls keeps at least one as well
+	nearest, _ := nearestDestinations(src, dsts, 1)
+	assert.Len(t, nearest, 1)
+	clustered, _ := clusterDestinations(dsts, 1000000)
+	assert.Len(t, clustered, 1)
+}
+
+func TestGetRoutesKeepsNoRoutesWarningWhenDestinationsFail(t *testing.T) {
+	mockOsrmApi := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"code":"NoRoute", "message": "Impossible route between points"}`))
+	}))
+	defer mockOsrmApi.Close()
+
+	router = setupRouter(Config{OsrmBaseURL: mockOsrmApi.URL})
+	rec := mockGetRoutesRequest("/routes?src=13.388860,52.517037&dst=13.397634,52.529407&dst=13.428555,52.523219&prefilterNearest=1&maxCalls=1")
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"warnings":["No routes found"]`)
+	assert.NotContains(t, rec.Body.String(), "No destinations were left")
+
+	rec = mockGetRoutesRequest("/routes?src=13.388860,52.517037&dst=13.397634,52.529407&onEmpty=404")
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+}
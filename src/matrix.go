@@ -0,0 +1,243 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Most cells a matrix may have, so a single request can't have OSRM compute millions of routes
+var maxMatrixCells = 10000
+
+type MatrixParams struct {
+	Src     []string `form:"src" json:"src" binding:"required,min=1" validate:"latlng,noedge"`
+	Dst     []string `form:"dst" json:"dst" binding:"required,min=1" validate:"latlng,noedge"`
+	Profile string   `form:"profile" json:"profile" validate:"omitempty,profile"`
+	Units   string   `form:"units" json:"units" validate:"omitempty,oneof=metric imperial"`
+
+	// Answer with the best source per destination instead of the whole matrix
+	Union bool `form:"union" json:"union"`
+
+	// Return where OSRM snapped every source and destination onto the road network
+	IncludeSnapped bool `form:"includeSnapped" json:"includeSnapped"`
+}
+
+// MatrixResp holds the duration and distance of every source to every destination, Durations[i][j]
+// being the one from Sources[i] to Destinations[j]. Pairs OSRM couldn't route are null. With
+// units the cells are also given in minutes and in kilometers or miles.
+type MatrixResp struct {
+	Sources          []string     `json:"sources"`
+	Destinations     []string     `json:"destinations"`
+	Durations        [][]*float64 `json:"durations"`
+	Distances        [][]*float64 `json:"distances"`
+	Units            string       `json:"units,omitempty"`
+	DurationsMinutes [][]*float64 `json:"durationsMinutes,omitempty"`
+	DistancesKm      [][]*float64 `json:"distancesKm,omitempty"`
+	DistancesMiles   [][]*float64 `json:"distancesMiles,omitempty"`
+
+	// The [lng, lat] OSRM snapped Sources[i] and Destinations[j] to, with includeSnapped
+	SnappedSources      [][2]float64 `json:"snappedSources,omitempty"`
+	SnappedDestinations [][2]float64 `json:"snappedDestinations,omitempty"`
+}
+
+type OsrmApiTableData struct {
+	Code         string              `json:"code"`
+	Message      string              `json:"message"`
+	Durations    [][]*float64        `json:"durations"`
+	Distances    [][]*float64        `json:"distances"`
+	Sources      []OsrmTableWaypoint `json:"sources"`
+	Destinations []OsrmTableWaypoint `json:"destinations"`
+}
+
+// OsrmTableWaypoint is where OSRM snapped a source or destination of the table onto the road network
+type OsrmTableWaypoint struct {
+	Location []float64 `json:"location"`
+}
+
+// getMatrix answers GET /matrix with the durations and distances between every src and dst
+func getMatrix(cfg Config) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var query MatrixParams
+		err := c.ShouldBindQuery(&query)
+		respondMatrix(c, cfg, query, err)
+	}
+}
+
+// postMatrix takes the parameters of GET /matrix as a JSON body, for matrices too large for a URL
+func postMatrix(cfg Config) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var query MatrixParams
+		err := c.ShouldBindJSON(&query)
+		respondMatrix(c, cfg, query, err)
+	}
+}
+
+func respondMatrix(c *gin.Context, cfg Config, query MatrixParams, err error) {
+	if err == nil {
+		normalizeCoordinates(query.Src)
+		normalizeCoordinates(query.Dst)
+		err = validate.Struct(query)
+	}
+
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrResp{
+			Code:      http.StatusBadRequest,
+			Message:   validationErrMsg(err),
+			ErrorCode: validationErrCode(err),
+		})
+		return
+	}
+
+	// A union doesn't list the sources, so there would be nothing to report the snapped ones with
+	if query.Union && query.IncludeSnapped {
+		c.JSON(http.StatusBadRequest, ErrResp{
+			Code:      http.StatusBadRequest,
+			Message:   "IncludeSnapped can't be combined with union",
+			ErrorCode: errCodeInvalidParameter,
+		})
+		return
+	}
+
+	// The units parameter always wins over the language, as on /routes
+	if query.Units == "" && unitsFromLanguage {
+		query.Units = unitsForLanguage(c.GetHeader("Accept-Language"))
+	}
+
+	// A union lists every destination once, so repetitions don't need to be routed
+	if query.Union {
+		query.Dst = uniqueCoordinates(query.Dst)
+	}
+
+	if cells := len(query.Src) * len(query.Dst); cells > maxMatrixCells {
+		c.JSON(http.StatusBadRequest, ErrResp{
+			Code:      http.StatusBadRequest,
+			Message:   fmt.Sprintf("%d sources and %d destinations make %d cells, more than the maximum of %d", len(query.Src), len(query.Dst), cells, maxMatrixCells),
+			ErrorCode: errCodeInvalidParameter,
+		})
+		return
+	}
+
+	data, err := getTableData(c.Request.Context(), cfg, query.Src, query.Dst, query.Profile)
+	if err != nil {
+		c.JSON(http.StatusBadGateway, ErrResp{
+			Code:      http.StatusBadGateway,
+			Message:   fmt.Sprintf("Could not compute the matrix: %s", err),
+			ErrorCode: errCodeBackendError,
+		})
+		return
+	}
+
+	if query.Union {
+		union := unionOfDestinations(query.Src, query.Dst, data)
+		if query.Units != "" {
+			addUnionUnits(&union, query.Units)
+		}
+		c.JSON(http.StatusOK, union)
+		return
+	}
+
+	resp := MatrixResp{
+		Sources:      query.Src,
+		Destinations: query.Dst,
+		Durations:    data.Durations,
+		Distances:    data.Distances,
+	}
+	if query.Units != "" {
+		addMatrixUnits(&resp, query.Units)
+	}
+	if query.IncludeSnapped {
+		resp.SnappedSources, err = snappedLocations(data.Sources, len(query.Src))
+		if err == nil {
+			resp.SnappedDestinations, err = snappedLocations(data.Destinations, len(query.Dst))
+		}
+		if err != nil {
+			c.JSON(http.StatusBadGateway, ErrResp{
+				Code:      http.StatusBadGateway,
+				Message:   fmt.Sprintf("Could not compute the matrix: %s", err),
+				ErrorCode: errCodeBackendError,
+			})
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, resp)
+}
+
+// getTableData asks the OSRM table service for the durations and distances in a single call. The
+// sources and destinations are passed as one list of coordinates, the sources first.
+func getTableData(ctx context.Context, cfg Config, srcs []string, dsts []string, profile string) (OsrmApiTableData, error) {
+	var data OsrmApiTableData
+
+	if profile == "" {
+		profile = osrmProfiles[0]
+	}
+
+	sources := make([]string, len(srcs))
+	for i := range srcs {
+		sources[i] = strconv.Itoa(i)
+	}
+	destinations := make([]string, len(dsts))
+	for i := range dsts {
+		destinations[i] = strconv.Itoa(len(srcs) + i)
+	}
+
+	// OSRM expects the lists separated by unescaped commas and semicolons
+	query := "annotations=duration,distance&sources=" + strings.Join(sources, ";") + "&destinations=" + strings.Join(destinations, ";")
+
+	coordinates := strings.Join(append(append([]string{}, srcs...), dsts...), ";")
+	release, err := acquireFetchBudget(ctx, cfg.FetchBudget)
+	if err != nil {
+		return data, err
+	}
+	defer release()
+
+	resp, body, err := makeRequestWith429Retries(ctx, cfg, cfg.OsrmBaseURL+fmt.Sprintf(osrmTablePath, profile, coordinates)+"?"+query)
+	if err != nil {
+		return data, err
+	}
+
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusBadRequest {
+		return data, fmt.Errorf("response code: %d", resp.StatusCode)
+	}
+
+	if err := json.Unmarshal(body, &data); err != nil {
+		return data, err
+	}
+
+	if data.Code != "Ok" {
+		return data, fmt.Errorf("response code: %d. message: %s", resp.StatusCode, data.Message)
+	}
+	if len(data.Durations) != len(srcs) || len(data.Distances) != len(srcs) {
+		return data, errors.New("the table doesn't have a row per source")
+	}
+	for i := range srcs {
+		if len(data.Durations[i]) != len(dsts) || len(data.Distances[i]) != len(dsts) {
+			return data, errors.New("the table doesn't have a column per destination")
+		}
+	}
+
+	return data, nil
+}
+
+// snappedLocations returns the [lng, lat] of every waypoint of the table, of which there must be n
+func snappedLocations(waypoints []OsrmTableWaypoint, n int) ([][2]float64, error) {
+	if len(waypoints) != n {
+		return nil, errors.New("the table doesn't have a snapped location per coordinate")
+	}
+
+	locations := make([][2]float64, n)
+	for i, waypoint := range waypoints {
+		if len(waypoint.Location) != 2 {
+			return nil, errors.New("the table has a snapped location that isn't a lng,lat pair")
+		}
+		locations[i] = [2]float64{waypoint.Location[0], waypoint.Location[1]}
+	}
+
+	return locations, nil
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func mockTableOsrmApi(requests *[]string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		*requests = append(*requests, r.URL.Path+"?"+r.URL.RawQuery)
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"code":"Ok",` +
+			`"durations":[[260.1,2490.1],[null,700.4]],` +
+			`"distances":[[1886.3,3286.3],[null,5100.9]]}`))
+	}))
+}
+
+func TestGetMatrixReturnsDurationsAndDistances(t *testing.T) {
+	var requests []string
+	mockOsrmApi := mockTableOsrmApi(&requests)
+	defer mockOsrmApi.Close()
+
+	router = setupRouter(Config{OsrmBaseURL: mockOsrmApi.URL})
+	rec := mockGetRoutesRequest("/matrix?src=13.388860,52.517037&src=13.428555,52.523219&dst=13.397634,52.529407&dst=13.412,52.5&profile=cycling")
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, []string{"/table/v1/cycling/13.388860,52.517037;13.428555,52.523219;13.397634,52.529407;13.412,52.5" +
+		"?annotations=duration,distance&sources=0;1&destinations=2;3"}, requests)
+	assert.Equal(t, `{"sources":["13.388860,52.517037","13.428555,52.523219"],"destinations":["13.397634,52.529407","13.412,52.5"],`+
+		`"durations":[[260.1,2490.1],[null,700.4]],"distances":[[1886.3,3286.3],[null,5100.9]]}`, rec.Body.String())
+}
+
+func TestGetMatrixMakesSingleTableCallForManySources(t *testing.T) {
+	var requests []string
+	mockOsrmApi := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.URL.Path+"?"+r.URL.RawQuery)
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"code":"Ok",` +
+			`"durations":[[11,12],[21,22],[31,32]],` +
+			`"distances":[[110,120],[210,220],[310,320]]}`))
+	}))
+	defer mockOsrmApi.Close()
+
+	router = setupRouter(Config{OsrmBaseURL: mockOsrmApi.URL})
+	rec := mockGetRoutesRequest("/matrix?src=13.388860,52.517037&src=13.428555,52.523219&src=13.5,52.6&dst=13.397634,52.529407&dst=13.412,52.5")
+
+	// One call for the three sources, the destinations indexed after them
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, []string{"/table/v1/driving/13.388860,52.517037;13.428555,52.523219;13.5,52.6;13.397634,52.529407;13.412,52.5" +
+		"?annotations=duration,distance&sources=0;1;2&destinations=3;4"}, requests)
+	assert.Equal(t, `{"sources":["13.388860,52.517037","13.428555,52.523219","13.5,52.6"],"destinations":["13.397634,52.529407","13.412,52.5"],`+
+		`"durations":[[11,12],[21,22],[31,32]],"distances":[[110,120],[210,220],[310,320]]}`, rec.Body.String())
+}
+
+func TestPostMatrixReturnsSameResponseAsGet(t *testing.T) {
+	var requests []string
+	mockOsrmApi := mockTableOsrmApi(&requests)
+	defer mockOsrmApi.Close()
+
+	router = setupRouter(Config{OsrmBaseURL: mockOsrmApi.URL})
+	get := mockGetRoutesRequest("/matrix?src=13.388860,52.517037&src=13.428555,52.523219&dst=13.397634,52.529407&dst=13.412,52.5")
+
+	rec := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodPost, "/matrix", strings.NewReader(
+		`{"src":["13.388860,52.517037","13.428555,52.523219"],"dst":["13.397634,52.529407","13.412,52.5"]}`))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, get.Body.String(), rec.Body.String())
+	assert.Equal(t, requests[0], requests[1])
+}
+
+func TestGetMatrixReturns400WhenCoordinatesAreInvalid(t *testing.T) {
+	router = setupRouter(Config{})
+
+	rec := mockGetRoutesRequest("/matrix?src=13.388860,52.517037&src=invalid&dst=13.397634,52.529407")
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Equal(t, `{"code":400,"message":"Src is not a valid latitude and longitude","error_code":"invalid_coordinate"}`, rec.Body.String())
+
+	rec = mockGetRoutesRequest("/matrix?src=13.388860,52.517037")
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+}
+
+func TestGetMatrixReturns400WhenTooManyCells(t *testing.T) {
+	router = setupRouter(Config{})
+	maxMatrixCells = 3
+	defer func() { maxMatrixCells = 10000 }()
+
+	rec := mockGetRoutesRequest("/matrix?src=13.388860,52.517037&src=13.428555,52.523219&dst=13.397634,52.529407&dst=13.412,52.5")
+
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Equal(t, `{"code":400,"message":"2 sources and 2 destinations make 4 cells, more than the maximum of 3","error_code":"invalid_parameter"}`, rec.Body.String())
+}
+
+func TestGetMatrixReturns502WhenOsrmFails(t *testing.T) {
+	mockOsrmApi := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"code":"InvalidQuery","message":"Query string malformed close to position 42"}`))
+	}))
+	defer mockOsrmApi.Close()
+
+	router = setupRouter(Config{OsrmBaseURL: mockOsrmApi.URL})
+	rec := mockGetRoutesRequest("/matrix?src=13.388860,52.517037&dst=13.397634,52.529407")
+
+	assert.Equal(t, http.StatusBadGateway, rec.Code)
+	assert.Equal(t, `{"code":502,"message":"Could not compute the matrix: response code: 400. message: Query string malformed close to position 42","error_code":"backend_error"}`, rec.Body.String())
+}
+
+func TestGetMatrixIncludesSnappedCoordinates(t *testing.T) {
+	mockOsrmApi := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"code":"Ok",` +
+			`"durations":[[260.1,2490.1]],"distances":[[1886.3,3286.3]],` +
+			`"sources":[{"location":[13.388799,52.517033],"distance":4.1,"name":"Friedrichstraße"}],` +
+			`"destinations":[{"location":[13.397631,52.529432],"distance":2.8,"name":"Torstraße"},{"location":[13.412001,52.500123],"distance":13.6,"name":""}]}`))
+	}))
+	defer mockOsrmApi.Close()
+
+	router = setupRouter(Config{OsrmBaseURL: mockOsrmApi.URL})
+	url := "/matrix?src=13.388860,52.517037&dst=13.397634,52.529407&dst=13.412,52.5"
+
+	rec := mockGetRoutesRequest(url + "&includeSnapped=true")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"snappedSources":[[13.388799,52.517033]],"snappedDestinations":[[13.397631,52.529432],[13.412001,52.500123]]}`)
+
+	// Not included unless asked for
+	rec = mockGetRoutesRequest(url)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.NotContains(t, rec.Body.String(), "snapped")
+}
+
+func TestGetMatrixReturns502WhenSnappedCoordinatesAreMissing(t *testing.T) {
+	mockOsrmApi := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"code":"Ok","durations":[[260.1]],"distances":[[1886.3]],` +
+			`"sources":[{"location":[13.388799,52.517033]}],"destinations":[]}`))
+	}))
+	defer mockOsrmApi.Close()
+
+	router = setupRouter(Config{OsrmBaseURL: mockOsrmApi.URL})
+	rec := mockGetRoutesRequest("/matrix?src=13.388860,52.517037&dst=13.397634,52.529407&includeSnapped=true")
+
+	assert.Equal(t, http.StatusBadGateway, rec.Code)
+	assert.Equal(t, `{"code":502,"message":"Could not compute the matrix: the table doesn't have a snapped location per coordinate","error_code":"backend_error"}`, rec.Body.String())
+}
+
+func TestGetMatrixReturns400WhenIncludeSnappedIsCombinedWithUnion(t *testing.T) {
+	router = setupRouter(Config{})
+
+	rec := mockGetRoutesRequest("/matrix?src=13.388860,52.517037&dst=13.397634,52.529407&union=true&includeSnapped=true")
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Equal(t, `{"code":400,"message":"IncludeSnapped can't be combined with union","error_code":"invalid_parameter"}`, rec.Body.String())
+}
@@ -0,0 +1,41 @@
+package main
+
+import "math"
+
+// Metadata describes the response as a whole rather than a single route
+type Metadata struct {
+	DetourRatio  *float64           `json:"detourRatio,omitempty"`
+	Reachability []ReachabilityBand `json:"reachability,omitempty"`
+	Fingerprint  string             `json:"fingerprint,omitempty"`
+	Engine       *EngineInfo        `json:"engine,omitempty"`
+
+	// Altitudes of the 3D coordinates by the coordinate they were stripped from, with COORDINATE_ALTITUDES=keep
+	Altitudes map[string]float64 `json:"altitudes,omitempty"`
+}
+
+// detourRatio divides the routed distance by the straight-line distance summed over all routes,
+// giving an indication of how winding the routes of the batch are
+func detourRatio(src string, routes []Route) *float64 {
+	from, err := parseCoordinate(src)
+	if err != nil {
+		return nil
+	}
+
+	var routed, straight float64
+	for _, route := range routes {
+		to, err := parseCoordinate(route.Destination)
+		if err != nil {
+			continue
+		}
+
+		routed += route.Distance
+		straight += haversineDistance(from, to)
+	}
+
+	if straight == 0 {
+		return nil
+	}
+
+	ratio := math.Round(routed/straight*100) / 100
+	return &ratio
+}
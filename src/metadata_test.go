@@ -0,0 +1,30 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDetourRatio(t *testing.T) {
+	src := "13.388860,52.517037"
+	dst1 := "13.397634,52.529407"
+	dst2 := "13.428555,52.523219"
+
+	from, _ := parseCoordinate(src)
+	to1, _ := parseCoordinate(dst1)
+	to2, _ := parseCoordinate(dst2)
+	straight := haversineDistance(from, to1) + haversineDistance(from, to2)
+
+	routes := []Route{
+		{Destination: dst1, Distance: straight},
+		{Destination: dst2, Distance: straight},
+	}
+
+	assert.Equal(t, 2.0, *detourRatio(src, routes))
+}
+
+func TestDetourRatioWithoutRoutes(t *testing.T) {
+	assert.Nil(t, detourRatio("13.388860,52.517037", nil))
+	assert.Nil(t, detourRatio("13.388860,52.517037", []Route{{Destination: "13.388860,52.517037", Distance: 10}}))
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var metrics = newMetricsRegistry(prometheus.DefaultRegisterer)
+
+// metricsRegistry holds the metrics exposed on GET /metrics, registered on a Prometheus registerer
+type metricsRegistry struct {
+	requests          *prometheus.CounterVec
+	osrmDuration      prometheus.Histogram
+	osrmResponses     *prometheus.CounterVec
+	osrmRetries       prometheus.Counter
+	routeCacheLookups *prometheus.CounterVec
+}
+
+func newMetricsRegistry(reg prometheus.Registerer) *metricsRegistry {
+	m := &metricsRegistry{
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "routes_http_requests_total",
+			Help: "Requests handled, by method, route and status code.",
+		}, []string{"method", "path", "code"}),
+		osrmDuration: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Name:    "routes_osrm_request_duration_seconds",
+			Help:    "Duration of the calls to OSRM.",
+			Buckets: prometheus.DefBuckets,
+		}),
+		osrmResponses: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "routes_osrm_responses_total",
+			Help: "Responses from OSRM, by status code.",
+		}, []string{"code"}),
+		osrmRetries: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "routes_osrm_429_retries_total",
+			Help: "OSRM calls retried after a 429.",
+		}),
+		routeCacheLookups: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "routes_route_cache_lookups_total",
+			Help: "Lookups in the route cache, by whether the route was cached.",
+		}, []string{"result"}),
+	}
+	reg.MustRegister(m.requests, m.osrmDuration, m.osrmResponses, m.osrmRetries, m.routeCacheLookups)
+
+	return m
+}
+
+// registerFetchBudgetMetrics exposes how much of the fetch budget is taken
+func registerFetchBudgetMetrics(reg prometheus.Registerer, fetchBudget *weightedSemaphore) {
+	reg.MustRegister(
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Name: "routes_osrm_fetch_budget_in_use",
+			Help: "OSRM fetches in flight across all requests.",
+		}, func() float64 { return float64(fetchBudget.inUse()) }),
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Name: "routes_osrm_fetch_budget_size",
+			Help: "Most OSRM fetches in flight across all requests, OSRM_GLOBAL_CONCURRENCY.",
+		}, func() float64 { return float64(fetchBudget.size) }),
+	)
+}
+
+// observeOsrmCall records the duration of an OSRM call and the status code it was answered with
+func (m *metricsRegistry) observeOsrmCall(d time.Duration, code int) {
+	m.osrmDuration.Observe(d.Seconds())
+	m.osrmResponses.WithLabelValues(strconv.Itoa(code)).Inc()
+}
+
+func (m *metricsRegistry) observeOsrmRetry() {
+	m.osrmRetries.Inc()
+}
+
+// observeRouteCache records whether a route was found in the route cache
+func (m *metricsRegistry) observeRouteCache(hit bool) {
+	result := "miss"
+	if hit {
+		result = "hit"
+	}
+	m.routeCacheLookups.WithLabelValues(result).Inc()
+}
+
+// middleware counts the requests by method, route and status code
+func (m *metricsRegistry) middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Next()
+
+		path := c.FullPath()
+		if path == "" {
+			path = "unmatched"
+		}
+
+		m.requests.WithLabelValues(c.Request.Method, path, strconv.Itoa(c.Writer.Status())).Inc()
+	}
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/assert"
+)
+
+// histogramCount returns how many observations h has recorded so far
+func histogramCount(t *testing.T, h prometheus.Histogram) uint64 {
+	var m dto.Metric
+	assert.Nil(t, h.Write(&m))
+
+	return m.GetHistogram().GetSampleCount()
+}
+
+func TestGetMetricsReportsRequestsAndOsrmCalls(t *testing.T) {
+	var calls int32
+	mockOsrmApi := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"code":"Ok", "routes": [{"duration":2490.1,"distance":3286.3}]}`))
+	}))
+	defer mockOsrmApi.Close()
+
+	// The metrics are registered once per process, so only the increase during this test is asserted
+	ok := testutil.ToFloat64(metrics.requests.WithLabelValues("GET", "/routes", "200"))
+	badRequest := testutil.ToFloat64(metrics.requests.WithLabelValues("GET", "/routes", "400"))
+	durations := histogramCount(t, metrics.osrmDuration)
+	osrmOk := testutil.ToFloat64(metrics.osrmResponses.WithLabelValues("200"))
+	osrmTooMany := testutil.ToFloat64(metrics.osrmResponses.WithLabelValues("429"))
+	retries := testutil.ToFloat64(metrics.osrmRetries)
+
+	router = setupRouter(Config{OsrmBaseURL: mockOsrmApi.URL, RetryBackoff: time.Millisecond})
+	url := "/routes?src=13.388860,52.517037"
+	for i := 0; i < 50; i++ {
+		url += fmt.Sprintf("&dst=13.%d,52.529407", 100+i)
+	}
+
+	assert.Equal(t, http.StatusOK, mockGetRoutesRequest(url).Code)
+	assert.Equal(t, http.StatusBadRequest, mockGetRoutesRequest("/routes").Code)
+
+	assert.Equal(t, ok+1, testutil.ToFloat64(metrics.requests.WithLabelValues("GET", "/routes", "200")))
+	assert.Equal(t, badRequest+1, testutil.ToFloat64(metrics.requests.WithLabelValues("GET", "/routes", "400")))
+	assert.Equal(t, durations+51, histogramCount(t, metrics.osrmDuration))
+	assert.Equal(t, osrmOk+50, testutil.ToFloat64(metrics.osrmResponses.WithLabelValues("200")))
+	assert.Equal(t, osrmTooMany+1, testutil.ToFloat64(metrics.osrmResponses.WithLabelValues("429")))
+	assert.Equal(t, retries+1, testutil.ToFloat64(metrics.osrmRetries))
+
+	rec := mockGetRoutesRequest("/metrics")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Header().Get("Content-Type"), "text/plain")
+
+	body := rec.Body.String()
+	assert.Contains(t, body, `routes_http_requests_total{code="200",method="GET",path="/routes"}`)
+	assert.Contains(t, body, "# TYPE routes_osrm_request_duration_seconds histogram\n")
+	assert.Contains(t, body, `routes_osrm_responses_total{code="429"}`)
+	assert.Contains(t, body, "# TYPE routes_osrm_429_retries_total counter\n")
+}
+
+func TestMetricsRegistryBucketsOsrmDurations(t *testing.T) {
+	m := newMetricsRegistry(prometheus.NewRegistry())
+	m.observeOsrmCall(20*time.Millisecond, http.StatusOK)
+	m.observeOsrmCall(3*time.Second, http.StatusOK)
+
+	var metric dto.Metric
+	assert.Nil(t, m.osrmDuration.Write(&metric))
+
+	var counts []uint64
+	for _, bucket := range metric.GetHistogram().GetBucket() {
+		counts = append(counts, bucket.GetCumulativeCount())
+	}
+	assert.Equal(t, []uint64{0, 0, 1, 1, 1, 1, 1, 1, 1, 2, 2}, counts)
+	assert.Equal(t, uint64(2), metric.GetHistogram().GetSampleCount())
+	assert.InDelta(t, 3.02, metric.GetHistogram().GetSampleSum(), 1e-9)
+	assert.Equal(t, float64(2), testutil.ToFloat64(m.osrmResponses.WithLabelValues("200")))
+}
@@ -0,0 +1,185 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"unicode"
+)
+
+const (
+	namingCamel = "camel"
+	namingSnake = "snake"
+)
+
+// marshalWithNaming marshals v to JSON and rewrites the key of every struct field with the given naming
+// convention. The struct tags use camelCase, so camel is returned as is.
+func marshalWithNaming(v any, naming string) ([]byte, error) {
+	data, err := json.Marshal(v)
+	if err != nil || naming != namingSnake {
+		return data, err
+	}
+
+	return renameJSONKeys(data, reflect.TypeOf(v), toSnakeCase)
+}
+
+// renameJSONKeys walks the JSON document token by token so the key order is kept intact. t is the
+// type the document was marshalled from, only keys of its struct fields are renamed. Map keys are
+// data such as destinations or addresses and are kept as they are.
+func renameJSONKeys(data []byte, t reflect.Type, rename func(string) string) ([]byte, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+
+	var buf bytes.Buffer
+	if err := writeRenamedValue(dec, &buf, t, rename); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// writeRenamedValue writes the next value of dec, t is its type or nil when it isn't known
+func writeRenamedValue(dec *json.Decoder, buf *bytes.Buffer, t reflect.Type, rename func(string) string) error {
+	for t != nil && t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	tok, err := dec.Token()
+	if err != nil {
+		return err
+	}
+
+	delim, ok := tok.(json.Delim)
+	if !ok {
+		b, err := json.Marshal(tok)
+		if err != nil {
+			return err
+		}
+		buf.Write(b)
+		return nil
+	}
+
+	switch delim {
+	case '{':
+		var fields map[string]reflect.Type
+		if t != nil && t.Kind() == reflect.Struct {
+			fields = jsonFieldTypes(t)
+		}
+
+		buf.WriteByte('{')
+		for i := 0; dec.More(); i++ {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+
+			keyTok, err := dec.Token()
+			if err != nil {
+				return err
+			}
+
+			name := keyTok.(string)
+			var valueType reflect.Type
+			switch {
+			case fields != nil:
+				valueType = fields[name]
+				name = rename(name)
+			case t != nil && t.Kind() == reflect.Map:
+				valueType = t.Elem()
+			}
+
+			key, err := json.Marshal(name)
+			if err != nil {
+				return err
+			}
+			buf.Write(key)
+			buf.WriteByte(':')
+
+			if err := writeRenamedValue(dec, buf, valueType, rename); err != nil {
+				return err
+			}
+		}
+		buf.WriteByte('}')
+	case '[':
+		var elemType reflect.Type
+		if t != nil && (t.Kind() == reflect.Slice || t.Kind() == reflect.Array) {
+			elemType = t.Elem()
+		}
+
+		buf.WriteByte('[')
+		for i := 0; dec.More(); i++ {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+
+			if err := writeRenamedValue(dec, buf, elemType, rename); err != nil {
+				return err
+			}
+		}
+		buf.WriteByte(']')
+	}
+
+	// Consume the closing delimiter
+	_, err = dec.Token()
+	return err
+}
+
+// jsonFieldTypes returns the type of every field of the struct type t by the key encoding/json
+// marshals it under, including the fields promoted from embedded structs
+func jsonFieldTypes(t reflect.Type) map[string]reflect.Type {
+	fields := make(map[string]reflect.Type, t.NumField())
+	var embedded []reflect.Type
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		tag := field.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+
+		name, _, _ := strings.Cut(tag, ",")
+		fieldType := field.Type
+		for fieldType.Kind() == reflect.Pointer {
+			fieldType = fieldType.Elem()
+		}
+		if field.Anonymous && name == "" && fieldType.Kind() == reflect.Struct {
+			embedded = append(embedded, fieldType)
+			continue
+		}
+		if !field.IsExported() {
+			continue
+		}
+
+		if name == "" {
+			name = field.Name
+		}
+		fields[name] = field.Type
+	}
+
+	// Fields of the struct itself win over promoted ones
+	for _, embeddedType := range embedded {
+		for name, fieldType := range jsonFieldTypes(embeddedType) {
+			if _, ok := fields[name]; !ok {
+				fields[name] = fieldType
+			}
+		}
+	}
+
+	return fields
+}
+
+// toSnakeCase converts a camelCase key such as snapDistance to snap_distance
+func toSnakeCase(s string) string {
+	var b strings.Builder
+	for i, r := range s {
+		if unicode.IsUpper(r) {
+			if i > 0 {
+				b.WriteByte('_')
+			}
+			r = unicode.ToLower(r)
+		}
+		b.WriteRune(r)
+	}
+
+	return b.String()
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestToSnakeCase(t *testing.T) {
+	assert.Equal(t, "source", toSnakeCase("source"))
+	assert.Equal(t, "snap_distance", toSnakeCase("snapDistance"))
+	assert.Equal(t, "uses_motorway", toSnakeCase("usesMotorway"))
+}
+
+func TestMarshalWithNamingKeepsCamelCaseByDefault(t *testing.T) {
+	hasToll := true
+	resp := GetRoutesResp{
+		Source: "13.388860,52.517037",
+		Routes: []Route{{Destination: "13.397634,52.529407", Duration: 260.1, Distance: 1886.3, HasToll: &hasToll}},
+	}
+
+	body, err := marshalWithNaming(resp, "")
+
+	assert.Nil(t, err)
+	assert.Equal(t, `{"source":"13.388860,52.517037","routes":[{"destination":"13.397634,52.529407","duration":260.1,"distance":1886.3,"hasToll":true}]}`, string(body))
+}
+
+func TestMarshalWithNamingSnake(t *testing.T) {
+	hasToll := true
+	snapDistance := 11.12
+	resp := GetRoutesResp{
+		Source:   "13.388860,52.517037",
+		Routes:   []Route{{Destination: "13.397634,52.529407", Duration: 260.1, Distance: 1886.3, SnapDistance: &snapDistance, HasToll: &hasToll}},
+		Warnings: []string{"a warning"},
+	}
+
+	body, err := marshalWithNaming(resp, namingSnake)
+
+	assert.Nil(t, err)
+	assert.Equal(t, `{"source":"13.388860,52.517037","routes":[{"destination":"13.397634,52.529407","duration":260.1,"distance":1886.3,"snap_distance":11.12,"has_toll":true}],"warnings":["a warning"]}`, string(body))
+}
+
+func TestMarshalWithNamingSnakeKeepsMapKeys(t *testing.T) {
+	resp := GetRoutesResp{
+		Source:   "13.388860,52.517037",
+		Routes:   []Route{{Destination: "13.397634,52.529407", Duration: 260.1, Distance: 1886.3}},
+		Geocoded: map[string]string{"Alexanderplatz, Berlin": "13.412950,52.521918"},
+	}
+
+	body, err := marshalWithNaming(resp, namingSnake)
+	assert.Nil(t, err)
+	assert.Contains(t, string(body), `"geocoded":{"Alexanderplatz, Berlin":"13.412950,52.521918"}`)
+
+	keyed := resp.keyByDestination()
+	keyed.NextCursor = "abc"
+	body, err = marshalWithNaming(keyed, namingSnake)
+	assert.Nil(t, err)
+	assert.Equal(t, `{"source":"13.388860,52.517037","geocoded":{"Alexanderplatz, Berlin":"13.412950,52.521918"},"next_cursor":"abc",`+
+		`"routes":{"13.397634,52.529407":{"destination":"13.397634,52.529407","duration":260.1,"distance":1886.3}},"order":["13.397634,52.529407"]}`, string(body))
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+type NearestQueryParams struct {
+	Src []string `form:"src" binding:"required" validate:"latlng,noedge"`
+	Dst string   `form:"dst" binding:"required" validate:"latlng,noedge"`
+}
+
+// NearestResp names the source with the shortest route to the destination
+type NearestResp struct {
+	Destination string   `json:"destination"`
+	Source      string   `json:"source"`
+	Duration    float64  `json:"duration"`
+	Distance    float64  `json:"distance"`
+	Unreachable []string `json:"unreachable,omitempty"`
+}
+
+// getNearestSource routes every source to the single destination and returns the fastest one,
+// e.g. to find the depot closest to a customer
+func getNearestSource(cfg Config) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var query NearestQueryParams
+
+		err := c.ShouldBindQuery(&query)
+		if err == nil {
+			normalizeCoordinates(query.Src)
+			query.Dst = normalizeCoordinate(query.Dst)
+			err = validate.Struct(query)
+		}
+
+		if err != nil {
+			c.JSON(http.StatusBadRequest, ErrResp{
+				Code:      http.StatusBadRequest,
+				Message:   validationErrMsg(err),
+				ErrorCode: validationErrCode(err),
+			})
+			return
+		}
+
+		if len(query.Src) > cfg.MaxDestinations {
+			c.JSON(http.StatusBadRequest, ErrResp{
+				Code:      http.StatusBadRequest,
+				Message:   fmt.Sprintf("%d sources are more than the maximum of %d", len(query.Src), cfg.MaxDestinations),
+				ErrorCode: errCodeInvalidParameter,
+			})
+			return
+		}
+
+		// Every route carries its source as destination, so the fastest one sorts first like in GET /routes
+		routes, failures := collectBounded(c.Request.Context(), cfg, query.Src, func(ctx context.Context, src string) (Route, error) {
+			route, err := getRouteData(ctx, cfg, src, query.Dst, RouteOptions{})
+			route.Destination = src
+			return route, err
+		})
+		if len(routes) == 0 {
+			c.JSON(http.StatusNotFound, ErrResp{
+				Code:      http.StatusNotFound,
+				Message:   "No routes found",
+				ErrorCode: errCodeNoRoutes,
+			})
+			return
+		}
+
+		sorted := GetRoutesResp{Routes: routes}
+		sorted.sortRoutesByDurationAsc()
+		fastest := sorted.Routes[0]
+
+		resp := NearestResp{Destination: query.Dst, Source: fastest.Destination, Duration: fastest.Duration, Distance: fastest.Distance}
+		sortFailures(failures, query.Src)
+		for _, failure := range failures {
+			resp.Unreachable = append(resp.Unreachable, failure.Destination)
+		}
+
+		c.JSON(http.StatusOK, resp)
+	}
+}
+
+type NearestDestinationParams struct {
+	Src     string   `form:"src" binding:"required" validate:"latlng,noedge"`
+	Dst     []string `form:"dst" binding:"required" validate:"latlng,noedge"`
+	Profile string   `form:"profile" validate:"omitempty,profile"`
+}
+
+// NearestDestinationResp is the fastest route from the source, flattened next to the source
+type NearestDestinationResp struct {
+	Source string `json:"source"`
+	Route
+}
+
+// getNearestDestination routes the source to every destination and returns only the fastest
+// route, e.g. to find the customer closest to a courier
+func getNearestDestination(cfg Config) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var query NearestDestinationParams
+
+		err := c.ShouldBindQuery(&query)
+		if err == nil {
+			query.Src = normalizeCoordinate(query.Src)
+			normalizeCoordinates(query.Dst)
+			err = validate.Struct(query)
+		}
+
+		if err != nil {
+			c.JSON(http.StatusBadRequest, ErrResp{
+				Code:      http.StatusBadRequest,
+				Message:   validationErrMsg(err),
+				ErrorCode: validationErrCode(err),
+			})
+			return
+		}
+
+		dsts := uniqueCoordinates(query.Dst)
+		if len(dsts) > cfg.MaxDestinations {
+			c.JSON(http.StatusBadRequest, ErrResp{
+				Code:      http.StatusBadRequest,
+				Message:   fmt.Sprintf("%d destinations are more than the maximum of %d", len(dsts), cfg.MaxDestinations),
+				ErrorCode: errCodeInvalidParameter,
+			})
+			return
+		}
+
+		routes, _ := collectWithWaitGroup(c.Request.Context(), cfg, query.Src, dsts, RouteOptions{Profile: query.Profile})
+		if len(routes) == 0 {
+			c.JSON(http.StatusNotFound, ErrResp{
+				Code:      http.StatusNotFound,
+				Message:   "No routes found",
+				ErrorCode: errCodeNoRoutes,
+			})
+			return
+		}
+
+		// Sorted like GET /routes, ties on duration go to the shorter distance, then to the lower destination
+		resp := GetRoutesResp{Routes: routes}
+		resp.sortRoutesByDurationAsc()
+
+		c.JSON(http.StatusOK, NearestDestinationResp{Source: query.Src, Route: resp.Routes[0]})
+	}
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func mockNearestRequest(url string) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodGet, url, nil)
+	router.ServeHTTP(rec, req)
+
+	return rec
+}
+
+func TestGetNearestSourceReturnsFastestSource(t *testing.T) {
+	mockOsrmApi := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case strings.HasPrefix(r.URL.Path, "/route/v1/driving/13.388860,52.517037;"):
+			w.WriteHeader(http.StatusOK)
+			w.Write([]byte(`{"code":"Ok", "routes": [{"duration":2490.1,"distance":3286.3}]}`))
+		case strings.HasPrefix(r.URL.Path, "/route/v1/driving/13.428555,52.523219;"):
+			w.WriteHeader(http.StatusOK)
+			w.Write([]byte(`{"code":"Ok", "routes": [{"duration":260.1,"distance":1886.3}]}`))
+		default:
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"code":"NoRoute", "message": "Impossible route between points"}`))
+		}
+	}))
+	defer mockOsrmApi.Close()
+
+	router = setupRouter(Config{OsrmBaseURL: mockOsrmApi.URL})
+	rec := mockNearestRequest("/routes/nearest?src=13.388860,52.517037&src=13.428555,52.523219&src=10.428555,29.523219&dst=13.397634,52.529407")
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, `{"destination":"13.397634,52.529407","source":"13.428555,52.523219","duration":260.1,"distance":1886.3,`+
+		`"unreachable":["10.428555,29.523219"]}`, rec.Body.String())
+}
+
+func TestGetNearestSourceReturns404WhenNoSourceIsRoutable(t *testing.T) {
+	mockOsrmApi := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"code":"NoRoute", "message": "Impossible route between points"}`))
+	}))
+	defer mockOsrmApi.Close()
+
+	router = setupRouter(Config{OsrmBaseURL: mockOsrmApi.URL})
+	rec := mockNearestRequest("/routes/nearest?src=13.388860,52.517037&dst=13.397634,52.529407")
+
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+	assert.Equal(t, `{"code":404,"message":"No routes found","error_code":"no_routes"}`, rec.Body.String())
+}
+
+func TestGetNearestSourceBoundsCallsInFlight(t *testing.T) {
+	var inFlight, maxInFlight int32
+	mockOsrmApi := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&inFlight, 1)
+		defer atomic.AddInt32(&inFlight, -1)
+		for {
+			highest := atomic.LoadInt32(&maxInFlight)
+			if n <= highest || atomic.CompareAndSwapInt32(&maxInFlight, highest, n) {
+				break
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"code":"Ok", "routes": [{"duration":260.1,"distance":1886.3}]}`))
+	}))
+	defer mockOsrmApi.Close()
+
+	router = setupRouter(Config{OsrmBaseURL: mockOsrmApi.URL, MaxConcurrency: 2})
+	url := "/routes/nearest?dst=13.397634,52.529407"
+	for i := 0; i < 8; i++ {
+		url += fmt.Sprintf("&src=13.4%d,52.5", i)
+	}
+	rec := mockNearestRequest(url)
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.LessOrEqual(t, atomic.LoadInt32(&maxInFlight), int32(2))
+
+	// Sources tie on duration and distance, the lower one wins like in GET /routes
+	assert.Contains(t, rec.Body.String(), `"source":"13.40,52.5"`)
+}
+
+func TestGetNearestSourceReturns400WhenThereAreTooManySources(t *testing.T) {
+	router = setupRouter(Config{MaxDestinations: 2})
+	rec := mockNearestRequest("/routes/nearest?src=13.388860,52.517037&src=13.428555,52.523219&src=13.412,52.5&dst=13.397634,52.529407")
+
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Equal(t, `{"code":400,"message":"3 sources are more than the maximum of 2","error_code":"invalid_parameter"}`, rec.Body.String())
+}
+
+func TestGetNearestSourceReturns400WhenDstIsMissing(t *testing.T) {
+	rec := mockNearestRequest("/routes/nearest?src=13.388860,52.517037")
+
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+}
+
+func TestGetNearestDestinationReturnsFastestRoute(t *testing.T) {
+	mockOsrmApi := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case strings.HasSuffix(r.URL.Path, ";13.397634,52.529407"):
+			w.WriteHeader(http.StatusOK)
+			w.Write([]byte(`{"code":"Ok", "routes": [{"duration":2490.1,"distance":3286.3}]}`))
+		case strings.HasSuffix(r.URL.Path, ";13.428555,52.523219"), strings.HasSuffix(r.URL.Path, ";13.412,52.5"):
+			w.WriteHeader(http.StatusOK)
+			w.Write([]byte(`{"code":"Ok", "routes": [{"duration":260.1,"distance":1886.3}]}`))
+		default:
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"code":"NoRoute", "message": "Impossible route between points"}`))
+		}
+	}))
+	defer mockOsrmApi.Close()
+
+	router = setupRouter(Config{OsrmBaseURL: mockOsrmApi.URL})
+	rec := mockNearestRequest("/routes/nearest-destination?src=13.388860,52.517037&dst=13.397634,52.529407&dst=13.428555,52.523219&dst=10.428555,29.523219&dst=13.412,52.5")
+
+	// 13.412,52.5 ties with 13.428555,52.523219 on duration and distance, the lower destination wins like in GET /routes
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, `{"source":"13.388860,52.517037","destination":"13.412,52.5","duration":260.1,"distance":1886.3}`, rec.Body.String())
+}
+
+func TestGetNearestDestinationReturns404WhenNoDestinationIsRoutable(t *testing.T) {
+	mockOsrmApi := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"code":"NoRoute", "message": "Impossible route between points"}`))
+	}))
+	defer mockOsrmApi.Close()
+
+	router = setupRouter(Config{OsrmBaseURL: mockOsrmApi.URL})
+	rec := mockNearestRequest("/routes/nearest-destination?src=13.388860,52.517037&dst=13.397634,52.529407&dst=13.428555,52.523219")
+
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+	assert.Equal(t, `{"code":404,"message":"No routes found","error_code":"no_routes"}`, rec.Body.String())
+}
+
+func TestGetNearestDestinationReturns400WhenDstIsMissing(t *testing.T) {
+	rec := mockNearestRequest("/routes/nearest-destination?src=13.388860,52.517037")
+
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+}
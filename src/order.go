@@ -0,0 +1,28 @@
+package main
+
+// orderRoutes returns the routes in the order of their destinations in order. Routes to
+// destinations missing from order follow in their current order unless omitUnordered is set.
+func orderRoutes(routes []Route, order []string, omitUnordered bool) []Route {
+	byDestination := make(map[string][]Route, len(routes))
+	for _, route := range routes {
+		byDestination[route.Destination] = append(byDestination[route.Destination], route)
+	}
+
+	ordered := make([]Route, 0, len(routes))
+	for _, dst := range order {
+		ordered = append(ordered, byDestination[dst]...)
+		delete(byDestination, dst)
+	}
+
+	if omitUnordered {
+		return ordered
+	}
+
+	for _, route := range routes {
+		if _, ok := byDestination[route.Destination]; ok {
+			ordered = append(ordered, route)
+		}
+	}
+
+	return ordered
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOrderRoutes(t *testing.T) {
+	routes := []Route{{Destination: "a", Duration: 1}, {Destination: "b", Duration: 2}, {Destination: "c", Duration: 3}}
+
+	assert.Equal(t, []Route{{Destination: "c", Duration: 3}, {Destination: "a", Duration: 1}, {Destination: "b", Duration: 2}},
+		orderRoutes(routes, []string{"c", "x", "a"}, false))
+	assert.Equal(t, []Route{{Destination: "c", Duration: 3}, {Destination: "a", Duration: 1}},
+		orderRoutes(routes, []string{"c", "x", "a"}, true))
+}
+
+func TestGetRoutesReturnsRoutesInClientOrder(t *testing.T) {
+	dst1 := "13.397634,52.529407"
+	dst2 := "12.428555,52.523219"
+	dst3 := "13.428555,48.523219"
+	dst4 := "10.428555,29.523219"
+
+	mockOsrmApi := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case strings.Contains(r.URL.Path, dst1):
+			w.WriteHeader(http.StatusOK)
+			w.Write([]byte(`{"code":"Ok", "routes": [{"duration":2490.1,"distance":3286.3}]}`))
+		case strings.Contains(r.URL.Path, dst2):
+			w.WriteHeader(http.StatusOK)
+			w.Write([]byte(`{"code":"Ok", "routes": [{"duration":260.1,"distance":1886.3}]}`))
+		case strings.Contains(r.URL.Path, dst3):
+			w.WriteHeader(http.StatusOK)
+			w.Write([]byte(`{"code":"Ok", "routes": [{"duration":960.1,"distance":5886.3}]}`))
+		default:
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"code":"NoRoute", "message": "Impossible route between points"}`))
+		}
+	}))
+	defer mockOsrmApi.Close()
+
+	router = setupRouter(Config{OsrmBaseURL: mockOsrmApi.URL})
+	query := fmt.Sprintf("/routes?src=13.388860,52.517037&dst=%s&dst=%s&dst=%s&dst=%s&order=%s&order=%s&order=%s",
+		dst1, dst2, dst3, dst4, dst1, dst4, dst3)
+
+	var resp GetRoutesResp
+	rec := mockGetRoutesRequest(query)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Nil(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	assert.Equal(t, []string{dst1, dst3, dst2}, routeDestinations(resp.Routes))
+	assert.Equal(t, dst4, resp.Failures[0].Destination)
+
+	rec = mockGetRoutesRequest(query + "&unordered=omit")
+	assert.Nil(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	assert.Equal(t, []string{dst1, dst3}, routeDestinations(resp.Routes))
+}
+
+func TestGetRoutesReturns400WhenOrderIsInvalid(t *testing.T) {
+	rec := mockGetRoutesRequest("/routes?src=13.388860,52.517037&dst=13.397634,52.529407&order=north")
+
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+}
+
+func routeDestinations(routes []Route) []string {
+	dsts := make([]string, 0, len(routes))
+	for _, route := range routes {
+		dsts = append(dsts, route.Destination)
+	}
+
+	return dsts
+}
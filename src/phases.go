@@ -0,0 +1,17 @@
+package main
+
+import "time"
+
+// PhaseTimings breaks the handling of a /routes request down into its phases, in milliseconds
+type PhaseTimings struct {
+	Validation float64 `json:"validation"`
+	Fetch      float64 `json:"fetch"`
+	Filter     float64 `json:"filter"`
+	Sort       float64 `json:"sort"`
+	Marshal    float64 `json:"marshal"`
+}
+
+// since adds the time elapsed since start to a phase, so a phase can be measured in several parts
+func (p *PhaseTimings) since(phase *float64, start time.Time) {
+	*phase += milliseconds(time.Since(start))
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetRoutesReturnsPhaseTimingsWhenRequested(t *testing.T) {
+	mockOsrmApi := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"code":"Ok", "routes": [{"duration":2490.1,"distance":3286.3}]}`))
+	}))
+	defer mockOsrmApi.Close()
+
+	router = setupRouter(Config{OsrmBaseURL: mockOsrmApi.URL})
+	url := "/routes?src=13.388860,52.517037&dst=13.397634,52.529407"
+
+	rec := mockGetRoutesRequest(url)
+	assert.NotContains(t, rec.Body.String(), `"timings"`)
+
+	rec = mockGetRoutesRequest(url + "&timings=true")
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	var body struct {
+		Timings map[string]float64 `json:"timings"`
+	}
+	assert.Nil(t, json.Unmarshal(rec.Body.Bytes(), &body))
+	for _, phase := range []string{"validation", "fetch", "filter", "sort", "marshal"} {
+		assert.Contains(t, body.Timings, phase)
+		assert.GreaterOrEqual(t, body.Timings[phase], 0.0, phase)
+	}
+}
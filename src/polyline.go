@@ -0,0 +1,44 @@
+package main
+
+import (
+	"math"
+	"strings"
+)
+
+// Tolerance in meters the preview polyline is simplified with
+var previewTolerance = 50.0
+
+// routePreview simplifies the route geometry and encodes it as a polyline for quick map previews
+func routePreview(geometry []Coordinate) string {
+	return encodePolyline(douglasPeucker(geometry, previewTolerance))
+}
+
+// encodePolyline encodes the points with the Google polyline algorithm at a precision of 5 decimals
+func encodePolyline(points []Coordinate) string {
+	var b strings.Builder
+
+	var prevLat, prevLng int64
+	for _, p := range points {
+		lat := int64(math.Round(p.Lat * 1e5))
+		lng := int64(math.Round(p.Lng * 1e5))
+
+		writePolylineValue(&b, lat-prevLat)
+		writePolylineValue(&b, lng-prevLng)
+		prevLat, prevLng = lat, lng
+	}
+
+	return b.String()
+}
+
+func writePolylineValue(b *strings.Builder, v int64) {
+	u := uint64(v) << 1
+	if v < 0 {
+		u = ^u
+	}
+
+	for u >= 0x20 {
+		b.WriteByte(byte((0x20 | (u & 0x1f)) + 63))
+		u >>= 5
+	}
+	b.WriteByte(byte(u + 63))
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// decodePolyline reverses encodePolyline
+func decodePolyline(s string) []Coordinate {
+	var (
+		points   []Coordinate
+		lat, lng int64
+	)
+
+	next := func() int64 {
+		var result uint64
+		for shift := 0; len(s) > 0; shift += 5 {
+			c := uint64(s[0]) - 63
+			s = s[1:]
+			result |= (c & 0x1f) << shift
+			if c < 0x20 {
+				break
+			}
+		}
+		if result&1 == 1 {
+			return ^int64(result >> 1)
+		}
+		return int64(result >> 1)
+	}
+
+	for len(s) > 0 {
+		lat += next()
+		lng += next()
+		points = append(points, Coordinate{Lng: float64(lng) / 1e5, Lat: float64(lat) / 1e5})
+	}
+
+	return points
+}
+
+func TestEncodePolyline(t *testing.T) {
+	points := []Coordinate{{Lng: -120.2, Lat: 38.5}, {Lng: -120.95, Lat: 40.7}, {Lng: -126.453, Lat: 43.252}}
+
+	assert.Equal(t, "_p~iF~ps|U_ulLnnqC_mqNvxq`@", encodePolyline(points))
+	assert.Equal(t, points, decodePolyline(encodePolyline(points)))
+}
+
+func TestGetRoutesReturnsSimplifiedPreview(t *testing.T) {
+	var query string
+	mockOsrmApi := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query = r.URL.RawQuery
+		w.WriteHeader(http.StatusOK)
+		// The middle points stray less than a meter from the straight line, except for a detour at 13.39
+		w.Write([]byte(`{"code":"Ok", "routes": [{"duration":260.1,"distance":1886.3,"geometry":{"type":"LineString","coordinates":[` +
+			`[13.38,52.5],[13.385,52.505001],[13.39,52.51],[13.395,52.505001],[13.4,52.5]]}}]}`))
+	}))
+	defer mockOsrmApi.Close()
+
+	router = setupRouter(Config{OsrmBaseURL: mockOsrmApi.URL})
+	rec := mockGetRoutesRequest("/routes?src=13.388860,52.517037&dst=13.397634,52.529407&preview=true")
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "geometries=geojson&overview=full", query)
+
+	var resp GetRoutesResp
+	assert.Nil(t, json.Unmarshal(rec.Body.Bytes(), &resp))
+	assert.Equal(t, []Coordinate{{Lng: 13.38, Lat: 52.5}, {Lng: 13.39, Lat: 52.51}, {Lng: 13.4, Lat: 52.5}},
+		decodePolyline(resp.Routes[0].Preview))
+}
+
+func TestGetRoutesReturnsGeometry(t *testing.T) {
+	var query string
+	mockOsrmApi := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query = r.URL.RawQuery
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"code":"Ok", "routes": [{"duration":260.1,"distance":1886.3,"geometry":{"type":"LineString","coordinates":[` +
+			`[13.38,52.5],[13.39,52.51],[13.4,52.5]]}}]}`))
+	}))
+	defer mockOsrmApi.Close()
+
+	router = setupRouter(Config{OsrmBaseURL: mockOsrmApi.URL})
+	rec := mockGetRoutesRequest("/routes?src=13.388860,52.517037&dst=13.397634,52.529407&geometry=true")
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "geometries=geojson&overview=full", query)
+	assert.Contains(t, rec.Body.String(), `"geometry":[[13.38,52.5],[13.39,52.51],[13.4,52.5]]`)
+
+	rec = mockGetRoutesRequest("/routes?src=13.388860,52.517037&dst=13.397634,52.529407")
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "overview=false", query)
+	assert.NotContains(t, rec.Body.String(), `"geometry"`)
+}
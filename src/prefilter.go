@@ -0,0 +1,50 @@
+package main
+
+import "sort"
+
+// nearestDestinations keeps the k destinations nearest to src by straight-line distance, in input
+// order, and returns the others separately. It is a cheap way to avoid routing to destinations that
+// are obviously too far to be the nearest by road.
+func nearestDestinations(src string, dsts []string, k int) ([]string, []string) {
+	if len(dsts) <= k {
+		return dsts, nil
+	}
+
+	from, err := parseCoordinate(src)
+	if err != nil {
+		return dsts, nil
+	}
+
+	distances := make([]float64, len(dsts))
+	for i, dst := range dsts {
+		to, err := parseCoordinate(dst)
+		if err != nil {
+			return dsts, nil
+		}
+		distances[i] = haversineDistance(from, to)
+	}
+
+	byDistance := make([]int, len(dsts))
+	for i := range byDistance {
+		byDistance[i] = i
+	}
+	sort.SliceStable(byDistance, func(i, j int) bool {
+		return distances[byDistance[i]] < distances[byDistance[j]]
+	})
+
+	kept := make([]bool, len(dsts))
+	for _, i := range byDistance[:k] {
+		kept[i] = true
+	}
+
+	var nearest, rest []string
+	for i, dst := range dsts {
+		if kept[i] {
+			nearest = append(nearest, dst)
+		} else {
+			rest = append(rest, dst)
+		}
+	}
+
+	return nearest, rest
+}
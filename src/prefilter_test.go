@@ -0,0 +1,62 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNearestDestinations(t *testing.T) {
+	src := "13.388860,52.517037"
+	dsts := []string{"13.428555,48.523219", "13.397634,52.529407", "12.428555,52.523219", "13.388860,52.527037"}
+
+	nearest, rest := nearestDestinations(src, dsts, 2)
+	assert.Equal(t, []string{"13.397634,52.529407", "13.388860,52.527037"}, nearest)
+	assert.Equal(t, []string{"13.428555,48.523219", "12.428555,52.523219"}, rest)
+
+	nearest, rest = nearestDestinations(src, dsts, 4)
+	assert.Equal(t, dsts, nearest)
+	assert.Empty(t, rest)
+}
+
+func TestGetRoutesRoutesOnlyPrefilteredNearestDestinations(t *testing.T) {
+	var (
+		mu     sync.Mutex
+		routed []string
+	)
+	mockOsrmApi := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		routed = append(routed, r.URL.Path[strings.LastIndex(r.URL.Path, ";")+1:])
+		mu.Unlock()
+
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"code":"Ok", "routes": [{"duration":2490.1,"distance":3286.3}]}`))
+	}))
+	defer mockOsrmApi.Close()
+
+	router = setupRouter(Config{OsrmBaseURL: mockOsrmApi.URL})
+	rec := mockGetRoutesRequest(fmt.Sprintf("/routes?src=13.388860,52.517037&dst=%s&dst=%s&dst=%s&prefilterNearest=1",
+		"13.428555,48.523219", "13.397634,52.529407", "12.428555,52.523219"))
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, []string{"13.397634,52.529407"}, routed)
+	assert.Contains(t, rec.Body.String(), `"skipped":["13.428555,48.523219","12.428555,52.523219"]`)
+
+	routed = nil
+	mockGetRoutesRequest("/routes?src=13.388860,52.517037&dst=13.428555,48.523219&dst=13.397634,52.529407&prefilterNearest=0")
+	sort.Strings(routed)
+	assert.Equal(t, []string{"13.397634,52.529407", "13.428555,48.523219"}, routed)
+}
+
+func TestGetRoutesReturns400WhenPrefilterNearestIsNegative(t *testing.T) {
+	rec := mockGetRoutesRequest("/routes?src=13.388860,52.517037&dst=13.397634,52.529407&prefilterNearest=-1")
+
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Equal(t, `{"code":400,"message":"PrefilterNearest must be at least 0","error_code":"invalid_parameter"}`, rec.Body.String())
+}
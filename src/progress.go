@@ -0,0 +1,91 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// progressInterval is how often a request with progress=true reports how far it got
+var progressInterval = 5 * time.Second
+
+// routeProgress counts the destinations of a request that have been routed so far
+type routeProgress struct {
+	total     int
+	completed atomic.Int64
+}
+
+type routeProgressKey struct{}
+
+// withRouteProgress returns a context the collectors count their routed destinations to
+func withRouteProgress(ctx context.Context, progress *routeProgress) context.Context {
+	return context.WithValue(ctx, routeProgressKey{}, progress)
+}
+
+// routeProgressFrom returns the progress ctx counts to, nil when it doesn't count any
+func routeProgressFrom(ctx context.Context) *routeProgress {
+	progress, _ := ctx.Value(routeProgressKey{}).(*routeProgress)
+
+	return progress
+}
+
+// done counts a destination as routed, whether it yielded a route or a failure
+func (p *routeProgress) done() {
+	if p != nil {
+		p.completed.Add(1)
+	}
+}
+
+// Prefix of the line ending a progress stream that failed after its status was committed
+const progressErrorPrefix = "error: "
+
+// streamProgress writes a "completed/total completed" line and flushes it every interval, keeping
+// clients and proxies from timing out on a quiet connection while a large batch is routed. Once
+// the first line is out the status is committed to 200 as plain text, the ETag follows as a trailer
+// and the body follows the last line. A request failing after that ends with an error line instead,
+// see writeErrResp. The returned stop waits for the stream to end, so the body can be written after it.
+func streamProgress(c *gin.Context, progress *routeProgress, interval time.Duration) (stop func()) {
+	ticker := time.NewTicker(interval)
+	quit := make(chan struct{})
+	stopped := make(chan struct{})
+
+	go func() {
+		defer close(stopped)
+		for {
+			select {
+			case <-ticker.C:
+				if !c.Writer.Written() {
+					c.Header("Content-Type", "text/plain; charset=utf-8")
+					c.Header("Trailer", "ETag")
+				}
+				fmt.Fprintf(c.Writer, "%d/%d completed\n", progress.completed.Load(), progress.total)
+				c.Writer.Flush()
+			case <-quit:
+				return
+			}
+		}
+	}()
+
+	return func() {
+		ticker.Stop()
+		close(quit)
+		<-stopped
+	}
+}
+
+// writeErrResp answers with resp and its status. When progress lines already committed the
+// status to 200, the stream ends with a line of the error prefix and resp as JSON instead.
+func writeErrResp(c *gin.Context, resp ErrResp) {
+	if !c.Writer.Written() {
+		c.JSON(resp.Code, resp)
+		return
+	}
+
+	// ErrResp only holds plain values, encoding it can't fail
+	line, _ := json.Marshal(resp)
+	fmt.Fprintf(c.Writer, "%s%s\n", progressErrorPrefix, line)
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetRoutesStreamsProgressBeforeBody(t *testing.T) {
+	mockOsrmApi := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(50 * time.Millisecond)
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"code":"Ok", "routes": [{"duration":260.1,"distance":1886.3}]}`))
+	}))
+	defer mockOsrmApi.Close()
+
+	defer func(interval time.Duration) { progressInterval = interval }(progressInterval)
+	progressInterval = 10 * time.Millisecond
+
+	router = setupRouter(Config{OsrmBaseURL: mockOsrmApi.URL})
+	rec := mockGetRoutesRequest("/routes?src=13.388860,52.517037&dst=13.397634,52.529407&dst=13.428555,52.523219&progress=true")
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.True(t, rec.Flushed)
+	assert.Regexp(t, regexp.MustCompile(`^([0-2]/2 completed\n)+\{`), rec.Body.String())
+	assert.Equal(t, "text/plain; charset=utf-8", rec.Result().Header.Get("Content-Type"))
+
+	// The ETag is only known once the routes are, it follows the body as a trailer
+	assert.Empty(t, rec.Result().Header.Get("ETag"))
+	assert.NotEmpty(t, rec.Result().Trailer.Get("ETag"))
+
+	body := rec.Body.String()
+	var resp GetRoutesResp
+	assert.Nil(t, json.Unmarshal([]byte(body[strings.Index(body, "{"):]), &resp))
+	assert.Len(t, resp.Routes, 2)
+}
+
+func TestGetRoutesWithoutProgressOnlyWritesBody(t *testing.T) {
+	mockOsrmApi := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(50 * time.Millisecond)
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"code":"Ok", "routes": [{"duration":260.1,"distance":1886.3}]}`))
+	}))
+	defer mockOsrmApi.Close()
+
+	defer func(interval time.Duration) { progressInterval = interval }(progressInterval)
+	progressInterval = 10 * time.Millisecond
+
+	router = setupRouter(Config{OsrmBaseURL: mockOsrmApi.URL})
+	rec := mockGetRoutesRequest("/routes?src=13.388860,52.517037&dst=13.397634,52.529407")
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.True(t, strings.HasPrefix(rec.Body.String(), "{"))
+}
+
+func TestGetRoutesEndsProgressWithErrorLineWhenFailingAfterStreaming(t *testing.T) {
+	mockOsrmApi := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(50 * time.Millisecond)
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"code":"NoRoute", "routes": []}`))
+	}))
+	defer mockOsrmApi.Close()
+
+	defer func(interval time.Duration) { progressInterval = interval }(progressInterval)
+	progressInterval = 10 * time.Millisecond
+
+	router = setupRouter(Config{OsrmBaseURL: mockOsrmApi.URL})
+	rec := mockGetRoutesRequest("/routes?src=13.388860,52.517037&dst=13.397634,52.529407&progress=true&onEmpty=404")
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Regexp(t, regexp.MustCompile(`^([0-1]/1 completed\n)+error: \{"code":404,"message":"No routes found","error_code":"no_routes"\}\n$`), rec.Body.String())
+}
+
+func TestWriteErrRespAnswersWithStatusBeforeStreaming(t *testing.T) {
+	rec := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(rec)
+
+	writeErrResp(c, ErrResp{Code: http.StatusNotFound, Message: "No routes found", ErrorCode: errCodeNoRoutes})
+
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+	assert.Equal(t, `{"code":404,"message":"No routes found","error_code":"no_routes"}`, rec.Body.String())
+}
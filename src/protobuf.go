@@ -0,0 +1,341 @@
+package main
+
+import (
+	"encoding/json"
+	"math"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+const protobufContentType = "application/x-protobuf"
+
+// Field numbers as declared in routes.proto
+const (
+	respSourceField     protowire.Number = 1
+	respRoutesField     protowire.Number = 2
+	respWarningsField   protowire.Number = 3
+	respWeightNameField protowire.Number = 4
+	respSkippedField    protowire.Number = 5
+	respMetadataField   protowire.Number = 6
+	respFailuresField   protowire.Number = 7
+	respDeltaField      protowire.Number = 8
+	respGeocodedField   protowire.Number = 9
+	respNextCursorField protowire.Number = 10
+	respRequestField    protowire.Number = 11
+	respRemovedField    protowire.Number = 12
+	respExcludedField   protowire.Number = 13
+
+	metadataDetourRatioField  protowire.Number = 1
+	metadataReachabilityField protowire.Number = 2
+	metadataFingerprintField  protowire.Number = 3
+	metadataEngineField       protowire.Number = 4
+	metadataAltitudesField    protowire.Number = 5
+
+	engineNameField        protowire.Number = 1
+	engineVersionField     protowire.Number = 2
+	engineDataVersionField protowire.Number = 3
+
+	reachabilityMinutesField   protowire.Number = 1
+	reachabilityReachableField protowire.Number = 2
+
+	routeDestinationField  protowire.Number = 1
+	routeDurationField     protowire.Number = 2
+	routeDistanceField     protowire.Number = 3
+	routeWeightField       protowire.Number = 4
+	routeSnapDistanceField protowire.Number = 5
+	routeHasTollField      protowire.Number = 6
+	routeUsesMotorwayField protowire.Number = 7
+	routeTimezoneField     protowire.Number = 8
+	routePreviewField      protowire.Number = 9
+	routeTurnsField        protowire.Number = 10
+	routeFlooredField      protowire.Number = 11
+	routeAdjustedField     protowire.Number = 12
+
+	routeDurationMinutesField protowire.Number = 13
+	routeDistanceKmField      protowire.Number = 14
+	routeDistanceMilesField   protowire.Number = 15
+	routeGeometryField        protowire.Number = 16
+	routeStepsField           protowire.Number = 17
+	routeAlternativesField    protowire.Number = 18
+	routeCachedField          protowire.Number = 19
+
+	stepTypeField     protowire.Number = 1
+	stepModifierField protowire.Number = 2
+	stepNameField     protowire.Number = 3
+	stepDistanceField protowire.Number = 4
+	stepDurationField protowire.Number = 5
+
+	alternativeDurationField protowire.Number = 1
+	alternativeDistanceField protowire.Number = 2
+
+	failureDestinationField protowire.Number = 1
+	failureStatusField      protowire.Number = 2
+	failureCategoryField    protowire.Number = 3
+	failureMessageField     protowire.Number = 4
+	failureHintField        protowire.Number = 5
+	failureParametersField  protowire.Number = 6
+	failureProfileField     protowire.Number = 7
+
+	exclusionDestinationField    protowire.Number = 1
+	exclusionExcludedReasonField protowire.Number = 2
+
+	// Map entries are encoded as messages with the key and value as their first two fields
+	mapKeyField   protowire.Number = 1
+	mapValueField protowire.Number = 2
+)
+
+func acceptsProtobuf(c *gin.Context) bool {
+	return strings.Contains(c.GetHeader("Accept"), protobufContentType)
+}
+
+// marshalProto encodes the response as the GetRoutesResp message in routes.proto
+func (o *GetRoutesResp) marshalProto() []byte {
+	var b []byte
+
+	b = appendString(b, respSourceField, o.Source)
+	for _, route := range o.Routes {
+		b = protowire.AppendTag(b, respRoutesField, protowire.BytesType)
+		b = protowire.AppendBytes(b, route.marshalProto())
+	}
+	for _, warning := range o.Warnings {
+		b = protowire.AppendTag(b, respWarningsField, protowire.BytesType)
+		b = protowire.AppendString(b, warning)
+	}
+	b = appendString(b, respWeightNameField, o.WeightName)
+	if o.Delta {
+		b = protowire.AppendTag(b, respDeltaField, protowire.VarintType)
+		b = protowire.AppendVarint(b, protowire.EncodeBool(o.Delta))
+	}
+	for _, skipped := range o.Skipped {
+		b = protowire.AppendTag(b, respSkippedField, protowire.BytesType)
+		b = protowire.AppendString(b, skipped)
+	}
+	if o.Metadata != nil {
+		b = protowire.AppendTag(b, respMetadataField, protowire.BytesType)
+		b = protowire.AppendBytes(b, o.Metadata.marshalProto())
+	}
+	for _, failure := range o.Failures {
+		b = protowire.AppendTag(b, respFailuresField, protowire.BytesType)
+		b = protowire.AppendBytes(b, failure.marshalProto())
+	}
+	// Sorted, so the same response always encodes to the same bytes
+	for _, address := range sortedKeys(o.Geocoded) {
+		var entry []byte
+		entry = appendString(entry, mapKeyField, address)
+		entry = appendString(entry, mapValueField, o.Geocoded[address])
+		b = protowire.AppendTag(b, respGeocodedField, protowire.BytesType)
+		b = protowire.AppendBytes(b, entry)
+	}
+	b = appendString(b, respNextCursorField, o.NextCursor)
+	if o.Request != nil {
+		// QueryParams only holds plain values, encoding it can't fail
+		request, _ := json.Marshal(o.Request)
+		b = appendString(b, respRequestField, string(request))
+	}
+	for _, removed := range o.Removed {
+		b = protowire.AppendTag(b, respRemovedField, protowire.BytesType)
+		b = protowire.AppendString(b, removed)
+	}
+	for _, exclusion := range o.Excluded {
+		b = protowire.AppendTag(b, respExcludedField, protowire.BytesType)
+		b = protowire.AppendBytes(b, exclusion.marshalProto())
+	}
+
+	return b
+}
+
+func (r *Route) marshalProto() []byte {
+	var b []byte
+
+	b = appendString(b, routeDestinationField, r.Destination)
+	b = appendDouble(b, routeDurationField, r.Duration)
+	b = appendDouble(b, routeDistanceField, r.Distance)
+
+	// Optional fields are written whenever they are set, even when zero
+	if r.Weight != nil {
+		b = protowire.AppendTag(b, routeWeightField, protowire.Fixed64Type)
+		b = protowire.AppendFixed64(b, math.Float64bits(*r.Weight))
+	}
+	if r.SnapDistance != nil {
+		b = protowire.AppendTag(b, routeSnapDistanceField, protowire.Fixed64Type)
+		b = protowire.AppendFixed64(b, math.Float64bits(*r.SnapDistance))
+	}
+	if r.HasToll != nil {
+		b = protowire.AppendTag(b, routeHasTollField, protowire.VarintType)
+		b = protowire.AppendVarint(b, protowire.EncodeBool(*r.HasToll))
+	}
+	if r.UsesMotorway != nil {
+		b = protowire.AppendTag(b, routeUsesMotorwayField, protowire.VarintType)
+		b = protowire.AppendVarint(b, protowire.EncodeBool(*r.UsesMotorway))
+	}
+	b = appendString(b, routeTimezoneField, r.Timezone)
+	b = appendString(b, routePreviewField, r.Preview)
+	if r.Turns != nil {
+		b = protowire.AppendTag(b, routeTurnsField, protowire.VarintType)
+		b = protowire.AppendVarint(b, uint64(*r.Turns))
+	}
+	if r.Floored {
+		b = protowire.AppendTag(b, routeFlooredField, protowire.VarintType)
+		b = protowire.AppendVarint(b, protowire.EncodeBool(r.Floored))
+	}
+	if r.Adjusted {
+		b = protowire.AppendTag(b, routeAdjustedField, protowire.VarintType)
+		b = protowire.AppendVarint(b, protowire.EncodeBool(r.Adjusted))
+	}
+	if r.DurationMinutes != nil {
+		b = protowire.AppendTag(b, routeDurationMinutesField, protowire.Fixed64Type)
+		b = protowire.AppendFixed64(b, math.Float64bits(*r.DurationMinutes))
+	}
+	if r.DistanceKm != nil {
+		b = protowire.AppendTag(b, routeDistanceKmField, protowire.Fixed64Type)
+		b = protowire.AppendFixed64(b, math.Float64bits(*r.DistanceKm))
+	}
+	if r.DistanceMiles != nil {
+		b = protowire.AppendTag(b, routeDistanceMilesField, protowire.Fixed64Type)
+		b = protowire.AppendFixed64(b, math.Float64bits(*r.DistanceMiles))
+	}
+	if len(r.Geometry) > 0 {
+		// Repeated scalars are packed into a single length delimited field
+		var packed []byte
+		for _, point := range r.Geometry {
+			packed = protowire.AppendFixed64(packed, math.Float64bits(point[0]))
+			packed = protowire.AppendFixed64(packed, math.Float64bits(point[1]))
+		}
+		b = protowire.AppendTag(b, routeGeometryField, protowire.BytesType)
+		b = protowire.AppendBytes(b, packed)
+	}
+	for _, step := range r.Steps {
+		b = protowire.AppendTag(b, routeStepsField, protowire.BytesType)
+		b = protowire.AppendBytes(b, step.marshalProto())
+	}
+	if r.Cached != nil {
+		b = protowire.AppendTag(b, routeCachedField, protowire.VarintType)
+		b = protowire.AppendVarint(b, protowire.EncodeBool(*r.Cached))
+	}
+	for _, alternative := range r.Alternatives {
+		b = protowire.AppendTag(b, routeAlternativesField, protowire.BytesType)
+		b = protowire.AppendBytes(b, alternative.marshalProto())
+	}
+
+	return b
+}
+
+func (s *Step) marshalProto() []byte {
+	var b []byte
+
+	b = appendString(b, stepTypeField, s.Type)
+	b = appendString(b, stepModifierField, s.Modifier)
+	b = appendString(b, stepNameField, s.Name)
+	b = appendDouble(b, stepDistanceField, s.Distance)
+	b = appendDouble(b, stepDurationField, s.Duration)
+
+	return b
+}
+
+func (a *Alternative) marshalProto() []byte {
+	var b []byte
+
+	b = appendDouble(b, alternativeDurationField, a.Duration)
+	b = appendDouble(b, alternativeDistanceField, a.Distance)
+
+	return b
+}
+
+func (f *Failure) marshalProto() []byte {
+	var b []byte
+
+	b = appendString(b, failureDestinationField, f.Destination)
+	if f.Status != 0 {
+		b = protowire.AppendTag(b, failureStatusField, protowire.VarintType)
+		b = protowire.AppendVarint(b, uint64(f.Status))
+	}
+	b = appendString(b, failureCategoryField, f.Category)
+	b = appendString(b, failureMessageField, f.Message)
+	b = appendString(b, failureHintField, f.Hint)
+	for _, p := range f.Parameters {
+		b = protowire.AppendTag(b, failureParametersField, protowire.BytesType)
+		b = protowire.AppendString(b, p)
+	}
+	b = appendString(b, failureProfileField, f.Profile)
+
+	return b
+}
+
+func (e *Exclusion) marshalProto() []byte {
+	var b []byte
+
+	b = appendString(b, exclusionDestinationField, e.Destination)
+	b = appendString(b, exclusionExcludedReasonField, e.ExcludedReason)
+
+	return b
+}
+
+func (m *Metadata) marshalProto() []byte {
+	var b []byte
+
+	if m.DetourRatio != nil {
+		b = protowire.AppendTag(b, metadataDetourRatioField, protowire.Fixed64Type)
+		b = protowire.AppendFixed64(b, math.Float64bits(*m.DetourRatio))
+	}
+	for _, band := range m.Reachability {
+		b = protowire.AppendTag(b, metadataReachabilityField, protowire.BytesType)
+		b = protowire.AppendBytes(b, band.marshalProto())
+	}
+	b = appendString(b, metadataFingerprintField, m.Fingerprint)
+	if m.Engine != nil {
+		b = protowire.AppendTag(b, metadataEngineField, protowire.BytesType)
+		b = protowire.AppendBytes(b, m.Engine.marshalProto())
+	}
+	for _, coordinate := range sortedKeys(m.Altitudes) {
+		var entry []byte
+		entry = appendString(entry, mapKeyField, coordinate)
+		entry = appendDouble(entry, mapValueField, m.Altitudes[coordinate])
+		b = protowire.AppendTag(b, metadataAltitudesField, protowire.BytesType)
+		b = protowire.AppendBytes(b, entry)
+	}
+
+	return b
+}
+
+func (e *EngineInfo) marshalProto() []byte {
+	var b []byte
+
+	b = appendString(b, engineNameField, e.Name)
+	b = appendString(b, engineVersionField, e.Version)
+	b = appendString(b, engineDataVersionField, e.DataVersion)
+
+	return b
+}
+
+func (r *ReachabilityBand) marshalProto() []byte {
+	var b []byte
+
+	b = appendDouble(b, reachabilityMinutesField, r.Minutes)
+	if r.Reachable != 0 {
+		b = protowire.AppendTag(b, reachabilityReachableField, protowire.VarintType)
+		b = protowire.AppendVarint(b, uint64(r.Reachable))
+	}
+
+	return b
+}
+
+// proto3 leaves out scalar fields holding their default value
+func appendString(b []byte, num protowire.Number, v string) []byte {
+	if v == "" {
+		return b
+	}
+
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendString(b, v)
+}
+
+func appendDouble(b []byte, num protowire.Number, v float64) []byte {
+	if v == 0 {
+		return b
+	}
+
+	b = protowire.AppendTag(b, num, protowire.Fixed64Type)
+	return protowire.AppendFixed64(b, math.Float64bits(v))
+}
@@ -0,0 +1,466 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// unmarshalProtoResp decodes a GetRoutesResp message from routes.proto
+func unmarshalProtoResp(t *testing.T, b []byte) GetRoutesResp {
+	var resp GetRoutesResp
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		assert.GreaterOrEqual(t, n, 0)
+		b = b[n:]
+
+		switch {
+		case num == respSourceField && typ == protowire.BytesType:
+			v, n := protowire.ConsumeString(b)
+			resp.Source, b = v, b[n:]
+		case num == respRoutesField && typ == protowire.BytesType:
+			v, n := protowire.ConsumeBytes(b)
+			resp.Routes, b = append(resp.Routes, unmarshalProtoRoute(t, v)), b[n:]
+		case num == respWarningsField && typ == protowire.BytesType:
+			v, n := protowire.ConsumeString(b)
+			resp.Warnings, b = append(resp.Warnings, v), b[n:]
+		case num == respWeightNameField && typ == protowire.BytesType:
+			v, n := protowire.ConsumeString(b)
+			resp.WeightName, b = v, b[n:]
+		case num == respSkippedField && typ == protowire.BytesType:
+			v, n := protowire.ConsumeString(b)
+			resp.Skipped, b = append(resp.Skipped, v), b[n:]
+		case num == respFailuresField && typ == protowire.BytesType:
+			v, n := protowire.ConsumeBytes(b)
+			resp.Failures, b = append(resp.Failures, unmarshalProtoFailure(t, v)), b[n:]
+		case num == respDeltaField && typ == protowire.VarintType:
+			v, n := protowire.ConsumeVarint(b)
+			resp.Delta, b = protowire.DecodeBool(v), b[n:]
+		case num == respMetadataField && typ == protowire.BytesType:
+			v, n := protowire.ConsumeBytes(b)
+			resp.Metadata, b = unmarshalProtoMetadata(t, v), b[n:]
+		case num == respRequestField && typ == protowire.BytesType:
+			v, n := protowire.ConsumeBytes(b)
+			resp.Request, b = &QueryParams{}, b[n:]
+			assert.Nil(t, json.Unmarshal(v, resp.Request))
+		case num == respRemovedField && typ == protowire.BytesType:
+			v, n := protowire.ConsumeString(b)
+			resp.Removed, b = append(resp.Removed, v), b[n:]
+		case num == respExcludedField && typ == protowire.BytesType:
+			v, n := protowire.ConsumeBytes(b)
+			resp.Excluded, b = append(resp.Excluded, unmarshalProtoExclusion(t, v)), b[n:]
+		case num == respNextCursorField && typ == protowire.BytesType:
+			v, n := protowire.ConsumeString(b)
+			resp.NextCursor, b = v, b[n:]
+		case num == respGeocodedField && typ == protowire.BytesType:
+			v, n := protowire.ConsumeBytes(b)
+			if resp.Geocoded == nil {
+				resp.Geocoded = make(map[string]string)
+			}
+			address, coordinate := unmarshalProtoMapEntry(t, v)
+			resp.Geocoded[address], b = coordinate, b[n:]
+		default:
+			t.Fatalf("unexpected field %d", num)
+		}
+	}
+
+	return resp
+}
+
+func unmarshalProtoMapEntry(t *testing.T, b []byte) (string, string) {
+	var key, value string
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		assert.GreaterOrEqual(t, n, 0)
+		assert.Equal(t, protowire.BytesType, typ)
+		b = b[n:]
+
+		v, n := protowire.ConsumeString(b)
+		b = b[n:]
+		switch num {
+		case mapKeyField:
+			key = v
+		case mapValueField:
+			value = v
+		}
+	}
+
+	return key, value
+}
+
+func unmarshalProtoExclusion(t *testing.T, b []byte) Exclusion {
+	var exclusion Exclusion
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		assert.GreaterOrEqual(t, n, 0)
+		assert.Equal(t, protowire.BytesType, typ)
+		b = b[n:]
+
+		v, n := protowire.ConsumeString(b)
+		b = b[n:]
+		switch num {
+		case exclusionDestinationField:
+			exclusion.Destination = v
+		case exclusionExcludedReasonField:
+			exclusion.ExcludedReason = v
+		}
+	}
+
+	return exclusion
+}
+
+func unmarshalProtoFailure(t *testing.T, b []byte) Failure {
+	var failure Failure
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		assert.GreaterOrEqual(t, n, 0)
+		b = b[n:]
+
+		switch {
+		case num == failureStatusField && typ == protowire.VarintType:
+			v, n := protowire.ConsumeVarint(b)
+			failure.Status, b = int(v), b[n:]
+		case typ == protowire.BytesType:
+			v, n := protowire.ConsumeString(b)
+			b = b[n:]
+			switch num {
+			case failureDestinationField:
+				failure.Destination = v
+			case failureCategoryField:
+				failure.Category = v
+			case failureMessageField:
+				failure.Message = v
+			case failureHintField:
+				failure.Hint = v
+			case failureParametersField:
+				failure.Parameters = append(failure.Parameters, v)
+			case failureProfileField:
+				failure.Profile = v
+			}
+		default:
+			t.Fatalf("unexpected field %d", num)
+		}
+	}
+
+	return failure
+}
+
+func unmarshalProtoAltitudeEntry(t *testing.T, b []byte) (string, float64) {
+	var (
+		key   string
+		value float64
+	)
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		assert.GreaterOrEqual(t, n, 0)
+		b = b[n:]
+
+		switch {
+		case num == mapKeyField && typ == protowire.BytesType:
+			v, n := protowire.ConsumeString(b)
+			key, b = v, b[n:]
+		case num == mapValueField && typ == protowire.Fixed64Type:
+			v, n := protowire.ConsumeFixed64(b)
+			value, b = math.Float64frombits(v), b[n:]
+		default:
+			t.Fatalf("unexpected field %d", num)
+		}
+	}
+
+	return key, value
+}
+
+func unmarshalProtoMetadata(t *testing.T, b []byte) *Metadata {
+	var metadata Metadata
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		assert.GreaterOrEqual(t, n, 0)
+		b = b[n:]
+
+		switch {
+		case num == metadataDetourRatioField && typ == protowire.Fixed64Type:
+			v, n := protowire.ConsumeFixed64(b)
+			f := math.Float64frombits(v)
+			metadata.DetourRatio, b = &f, b[n:]
+		case num == metadataReachabilityField && typ == protowire.BytesType:
+			v, n := protowire.ConsumeBytes(b)
+			metadata.Reachability, b = append(metadata.Reachability, unmarshalProtoReachabilityBand(t, v)), b[n:]
+		case num == metadataFingerprintField && typ == protowire.BytesType:
+			v, n := protowire.ConsumeBytes(b)
+			metadata.Fingerprint, b = string(v), b[n:]
+		case num == metadataEngineField && typ == protowire.BytesType:
+			v, n := protowire.ConsumeBytes(b)
+			metadata.Engine, b = unmarshalProtoEngineInfo(t, v), b[n:]
+		case num == metadataAltitudesField && typ == protowire.BytesType:
+			v, n := protowire.ConsumeBytes(b)
+			if metadata.Altitudes == nil {
+				metadata.Altitudes = make(map[string]float64)
+			}
+			coordinate, altitude := unmarshalProtoAltitudeEntry(t, v)
+			metadata.Altitudes[coordinate], b = altitude, b[n:]
+		default:
+			t.Fatalf("unexpected field %d", num)
+		}
+	}
+
+	return &metadata
+}
+
+func unmarshalProtoEngineInfo(t *testing.T, b []byte) *EngineInfo {
+	var engine EngineInfo
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		assert.GreaterOrEqual(t, n, 0)
+		b = b[n:]
+
+		switch {
+		case num == engineNameField && typ == protowire.BytesType:
+			v, n := protowire.ConsumeBytes(b)
+			engine.Name, b = string(v), b[n:]
+		case num == engineVersionField && typ == protowire.BytesType:
+			v, n := protowire.ConsumeBytes(b)
+			engine.Version, b = string(v), b[n:]
+		case num == engineDataVersionField && typ == protowire.BytesType:
+			v, n := protowire.ConsumeBytes(b)
+			engine.DataVersion, b = string(v), b[n:]
+		default:
+			t.Fatalf("unexpected field %d", num)
+		}
+	}
+
+	return &engine
+}
+
+func unmarshalProtoReachabilityBand(t *testing.T, b []byte) ReachabilityBand {
+	var band ReachabilityBand
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		assert.GreaterOrEqual(t, n, 0)
+		b = b[n:]
+
+		switch {
+		case num == reachabilityMinutesField && typ == protowire.Fixed64Type:
+			v, n := protowire.ConsumeFixed64(b)
+			band.Minutes, b = math.Float64frombits(v), b[n:]
+		case num == reachabilityReachableField && typ == protowire.VarintType:
+			v, n := protowire.ConsumeVarint(b)
+			band.Reachable, b = int(v), b[n:]
+		default:
+			t.Fatalf("unexpected field %d", num)
+		}
+	}
+
+	return band
+}
+
+func unmarshalProtoRoute(t *testing.T, b []byte) Route {
+	var route Route
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		assert.GreaterOrEqual(t, n, 0)
+		b = b[n:]
+
+		switch {
+		case num == routeDestinationField && typ == protowire.BytesType:
+			v, n := protowire.ConsumeString(b)
+			route.Destination, b = v, b[n:]
+		case num == routeTimezoneField && typ == protowire.BytesType:
+			v, n := protowire.ConsumeString(b)
+			route.Timezone, b = v, b[n:]
+		case num == routePreviewField && typ == protowire.BytesType:
+			v, n := protowire.ConsumeString(b)
+			route.Preview, b = v, b[n:]
+		case num == routeGeometryField && typ == protowire.BytesType:
+			packed, n := protowire.ConsumeBytes(b)
+			b = b[n:]
+			for len(packed) >= 16 {
+				lng, _ := protowire.ConsumeFixed64(packed)
+				lat, _ := protowire.ConsumeFixed64(packed[8:])
+				route.Geometry = append(route.Geometry, [2]float64{math.Float64frombits(lng), math.Float64frombits(lat)})
+				packed = packed[16:]
+			}
+		case num == routeStepsField && typ == protowire.BytesType:
+			v, n := protowire.ConsumeBytes(b)
+			route.Steps, b = append(route.Steps, unmarshalProtoStep(t, v)), b[n:]
+		case num == routeAlternativesField && typ == protowire.BytesType:
+			v, n := protowire.ConsumeBytes(b)
+			route.Alternatives, b = append(route.Alternatives, unmarshalProtoAlternative(t, v)), b[n:]
+		case typ == protowire.Fixed64Type:
+			v, n := protowire.ConsumeFixed64(b)
+			f := math.Float64frombits(v)
+			b = b[n:]
+			switch num {
+			case routeDurationField:
+				route.Duration = f
+			case routeDistanceField:
+				route.Distance = f
+			case routeWeightField:
+				route.Weight = &f
+			case routeSnapDistanceField:
+				route.SnapDistance = &f
+			case routeDurationMinutesField:
+				route.DurationMinutes = &f
+			case routeDistanceKmField:
+				route.DistanceKm = &f
+			case routeDistanceMilesField:
+				route.DistanceMiles = &f
+			}
+		case typ == protowire.VarintType:
+			v, n := protowire.ConsumeVarint(b)
+			flag := protowire.DecodeBool(v)
+			b = b[n:]
+			switch num {
+			case routeHasTollField:
+				route.HasToll = &flag
+			case routeUsesMotorwayField:
+				route.UsesMotorway = &flag
+			case routeTurnsField:
+				turns := int(v)
+				route.Turns = &turns
+			case routeFlooredField:
+				route.Floored = flag
+			case routeAdjustedField:
+				route.Adjusted = flag
+			case routeCachedField:
+				route.Cached = &flag
+			}
+		default:
+			t.Fatalf("unexpected field %d", num)
+		}
+	}
+
+	return route
+}
+
+func unmarshalProtoStep(t *testing.T, b []byte) Step {
+	var step Step
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		assert.GreaterOrEqual(t, n, 0)
+		b = b[n:]
+
+		switch {
+		case num == stepTypeField && typ == protowire.BytesType:
+			v, n := protowire.ConsumeString(b)
+			step.Type, b = v, b[n:]
+		case num == stepModifierField && typ == protowire.BytesType:
+			v, n := protowire.ConsumeString(b)
+			step.Modifier, b = v, b[n:]
+		case num == stepNameField && typ == protowire.BytesType:
+			v, n := protowire.ConsumeString(b)
+			step.Name, b = v, b[n:]
+		case num == stepDistanceField && typ == protowire.Fixed64Type:
+			v, n := protowire.ConsumeFixed64(b)
+			step.Distance, b = math.Float64frombits(v), b[n:]
+		case num == stepDurationField && typ == protowire.Fixed64Type:
+			v, n := protowire.ConsumeFixed64(b)
+			step.Duration, b = math.Float64frombits(v), b[n:]
+		default:
+			t.Fatalf("unexpected field %d", num)
+		}
+	}
+
+	return step
+}
+
+func unmarshalProtoAlternative(t *testing.T, b []byte) Alternative {
+	var alternative Alternative
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		assert.GreaterOrEqual(t, n, 0)
+		b = b[n:]
+
+		switch {
+		case num == alternativeDurationField && typ == protowire.Fixed64Type:
+			v, n := protowire.ConsumeFixed64(b)
+			alternative.Duration, b = math.Float64frombits(v), b[n:]
+		case num == alternativeDistanceField && typ == protowire.Fixed64Type:
+			v, n := protowire.ConsumeFixed64(b)
+			alternative.Distance, b = math.Float64frombits(v), b[n:]
+		default:
+			t.Fatalf("unexpected field %d", num)
+		}
+	}
+
+	return alternative
+}
+
+func TestMarshalProtoRoundTrip(t *testing.T) {
+	weight := 0.0
+	detourRatio := 1.38
+	hasToll := false
+	usesMotorway := true
+	cached := true
+	minutes, km := 41.5, 3.29
+	resp := GetRoutesResp{
+		Source:     "13.388860,52.517037",
+		WeightName: "routability",
+		Routes: []Route{
+			{Destination: "12.428555,52.523219", Duration: 260.1, Distance: 1886.3, Weight: &weight, HasToll: &hasToll, UsesMotorway: &usesMotorway, Cached: &cached,
+				Alternatives: []Alternative{{Duration: 301.4, Distance: 1702.9}, {Duration: 322, Distance: 2011.5}}},
+			{Destination: "13.397634,52.529407", Duration: 2490.1, Distance: 3286.3, Floored: true, Adjusted: true, DurationMinutes: &minutes, DistanceKm: &km,
+				Geometry: [][2]float64{{13.388860, 52.517037}, {13.397634, 52.529407}},
+				Steps:    []Step{{Type: "depart", Name: "Unter den Linden", Distance: 120.4, Duration: 15.2}, {Type: "turn", Modifier: "left", Distance: 0, Duration: 0}}},
+		},
+		Warnings: []string{"a warning"},
+		Skipped:  []string{"13.428555,52.523219"},
+		Failures: []Failure{
+			{Destination: "10.428555,29.523219", Status: 422, Category: categoryNoRoute, Message: "no route"},
+			{Destination: "10.428555,30.523219", Status: 400, Category: categoryInvalidValue, Message: "invalid", Parameters: []string{"overview=false", "steps=true"}, Profile: "cycling"},
+		},
+		Metadata: &Metadata{
+			DetourRatio: &detourRatio,
+			Fingerprint: "4f2a-9c1e",
+			Engine:      &EngineInfo{Name: "osrm-routed", Version: "5.27.1", DataVersion: "2023-06-01"},
+			Altitudes:   map[string]float64{"13.388860,52.517037": 34.5, "13.397634,52.529407": -2},
+		},
+		Geocoded:   map[string]string{"Alexanderplatz, Berlin": "13.41053,52.52177", "Brandenburger Tor": "13.377704,52.516275"},
+		NextCursor: "ZHVyYXRpb258MjYwLjF8MTg4Ni4zfDEyLjQyODU1NSw1Mi41MjMyMTk",
+		Request:    &QueryParams{Src: "13.388860,52.517037", Dst: []string{"13.397634,52.529407"}, Profile: "driving", Echo: true},
+		Delta:      true,
+		Removed:    []string{"13.428555,48.523219"},
+		Excluded:   []Exclusion{{Destination: "13.428555,52.523219", ExcludedReason: "routing it would exceed maxCalls of 1"}},
+	}
+
+	assert.Equal(t, resp, unmarshalProtoResp(t, resp.marshalProto()))
+}
+
+func TestGetRoutesReturnsProtobufWhenAccepted(t *testing.T) {
+	osrmApiPath := "/route/v1/driving/%s;%s"
+	src := "13.388860,52.517037"
+	dst1 := "13.397634,52.529407"
+	dst2 := "12.428555,52.523219"
+
+	mockOsrmApi := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		if r.URL.Path == fmt.Sprintf(osrmApiPath, src, dst1) {
+			w.Write([]byte(`{"code":"Ok", "routes": [{"duration":2490.1,"distance":3286.3}]}`))
+			return
+		}
+		w.Write([]byte(`{"code":"Ok", "routes": [{"duration":260.1,"distance":1886.3}]}`))
+	}))
+	defer mockOsrmApi.Close()
+
+	router = setupRouter(Config{OsrmBaseURL: mockOsrmApi.URL})
+
+	rec := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodGet, fmt.Sprintf("/routes?src=%s&dst=%s&dst=%s", src, dst1, dst2), nil)
+	req.Header.Set("Accept", protobufContentType)
+	router.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, protobufContentType, rec.Header().Get("Content-Type"))
+
+	resp := unmarshalProtoResp(t, rec.Body.Bytes())
+	assert.Equal(t, src, resp.Source)
+	assert.Equal(t, []Route{
+		{Destination: dst2, Duration: 260.1, Distance: 1886.3},
+		{Destination: dst1, Duration: 2490.1, Distance: 3286.3},
+	}, resp.Routes)
+}
@@ -0,0 +1,214 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+var (
+	// Daily request quota per client, nil disables it
+	dailyQuota *DailyQuota
+
+	// How often the quota counters are written to DAILY_QUOTA_FILE
+	quotaFlushInterval = 10 * time.Second
+
+	// Proxies whose X-Forwarded-For header is trusted for the client address, none by default
+	trustedProxies []string
+
+	timeNow = time.Now
+)
+
+// QuotaStore counts requests per client and day
+type QuotaStore interface {
+	Increment(day string, key string) (int, error)
+}
+
+type DailyQuota struct {
+	Limit int
+	Store QuotaStore
+
+	// Clients are keyed by the subnet of their address with these prefix lengths, so rotating
+	// addresses within e.g. an IPv6 /64 doesn't bypass the quota. 0 keys by the full address.
+	IPv4Prefix int
+	IPv6Prefix int
+}
+
+// middleware rejects clients with 429 once they have used up their quota for the current UTC day
+func (q *DailyQuota) middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		day := timeNow().UTC().Format("2006-01-02")
+
+		count, err := q.Store.Increment(day, q.key(c))
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusInternalServerError, ErrResp{
+				Code:      http.StatusInternalServerError,
+				Message:   "Could not check quota",
+				ErrorCode: errCodeInternal,
+			})
+			return
+		}
+
+		if count > q.Limit {
+			c.AbortWithStatusJSON(http.StatusTooManyRequests, ErrResp{
+				Code:      http.StatusTooManyRequests,
+				Message:   fmt.Sprintf("Daily quota of %d requests exceeded", q.Limit),
+				ErrorCode: errCodeQuotaExceeded,
+			})
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// Clients authenticated with an API key share their quota across addresses. Only a key validated by
+// requireAPIKey counts, and it is stored hashed as the counters may be written to disk. Addresses are
+// taken from X-Forwarded-For only when the request came through one of the trusted proxies.
+func (q *DailyQuota) key(c *gin.Context) string {
+	if key := c.GetString(apiKeyContextKey); key != "" {
+		sum := sha256.Sum256([]byte(key))
+		return "key:" + hex.EncodeToString(sum[:8])
+	}
+
+	ip := net.ParseIP(c.ClientIP())
+	if ip == nil {
+		return "ip:" + c.ClientIP()
+	}
+
+	if ip4 := ip.To4(); ip4 != nil {
+		return "ip:" + subnet(ip4, q.IPv4Prefix, 32)
+	}
+
+	return "ip:" + subnet(ip, q.IPv6Prefix, 128)
+}
+
+// subnet returns the network of ip with the given prefix length in CIDR notation
+func subnet(ip net.IP, prefix int, bits int) string {
+	if prefix <= 0 || prefix > bits {
+		prefix = bits
+	}
+
+	network := &net.IPNet{IP: ip.Mask(net.CIDRMask(prefix, bits)), Mask: net.CIDRMask(prefix, bits)}
+	return network.String()
+}
+
+// MemoryQuotaStore keeps the counters of the current day in memory and, when a path is set, Flush
+// writes them to disk so a restart doesn't reset them
+type MemoryQuotaStore struct {
+	mu     sync.Mutex
+	path   string
+	dirty  bool
+	Day    string         `json:"day"`
+	Counts map[string]int `json:"counts"`
+}
+
+func newMemoryQuotaStore(path string) (*MemoryQuotaStore, error) {
+	s := &MemoryQuotaStore{
+		path:   path,
+		Counts: make(map[string]int),
+	}
+
+	if path == "" {
+		return s, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err = json.Unmarshal(data, s); err != nil {
+		return nil, err
+	}
+	if s.Counts == nil {
+		s.Counts = make(map[string]int)
+	}
+
+	return s, nil
+}
+
+func (s *MemoryQuotaStore) Increment(day string, key string) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Counters only matter for the current day
+	if s.Day != day {
+		s.Day = day
+		s.Counts = make(map[string]int)
+	}
+
+	s.Counts[key]++
+	s.dirty = true
+
+	return s.Counts[key], nil
+}
+
+// flushEvery writes the counters to disk every interval, logging failures. Counters of the last
+// interval are lost on a crash, which beats rewriting the file on every request.
+func (s *MemoryQuotaStore) flushEvery(interval time.Duration) {
+	for range time.Tick(interval) {
+		if err := s.Flush(); err != nil {
+			log.Printf("could not write quota file: %s", err)
+		}
+	}
+}
+
+// Flush writes the counters to disk if they changed since the last flush
+func (s *MemoryQuotaStore) Flush() error {
+	s.mu.Lock()
+	if s.path == "" || !s.dirty {
+		s.mu.Unlock()
+		return nil
+	}
+
+	data, err := json.Marshal(s)
+	if err != nil {
+		s.mu.Unlock()
+		return err
+	}
+	s.dirty = false
+	s.mu.Unlock()
+
+	if err := s.write(data); err != nil {
+		s.mu.Lock()
+		s.dirty = true
+		s.mu.Unlock()
+		return err
+	}
+
+	return nil
+}
+
+func (s *MemoryQuotaStore) write(data []byte) error {
+	// Write to a temporary file first so a crash never leaves a half written file behind
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err = tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err = tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), s.path)
+}
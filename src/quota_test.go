@@ -0,0 +1,161 @@
+package main
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func setupQuotaRouter(q *DailyQuota) *gin.Engine {
+	r := gin.New()
+	r.GET("/routes", q.middleware(), func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+
+	return r
+}
+
+func mockQuotaRequest(r *gin.Engine, remoteAddr string, apiKey string) int {
+	return mockQuotaRecorder(r, remoteAddr, apiKey).Code
+}
+
+func mockQuotaRecorder(r *gin.Engine, remoteAddr string, apiKey string) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodGet, "/routes", nil)
+	req.RemoteAddr = remoteAddr
+	if apiKey != "" {
+		req.Header.Set("X-API-Key", apiKey)
+	}
+	r.ServeHTTP(rec, req)
+
+	return rec
+}
+
+func TestDailyQuotaReturns429WhenExceeded(t *testing.T) {
+	store, _ := newMemoryQuotaStore("")
+	r := setupQuotaRouter(&DailyQuota{Limit: 2, Store: store})
+
+	assert.Equal(t, http.StatusOK, mockQuotaRequest(r, "10.0.0.1:1234", ""))
+	assert.Equal(t, http.StatusOK, mockQuotaRequest(r, "10.0.0.1:1234", ""))
+	rec := mockQuotaRecorder(r, "10.0.0.1:1234", "")
+	assert.Equal(t, http.StatusTooManyRequests, rec.Code)
+	assert.Equal(t, `{"code":429,"message":"Daily quota of 2 requests exceeded","error_code":"quota_exceeded"}`, rec.Body.String())
+
+	// Other clients have their own quota, an API key nobody validated doesn't make another client
+	assert.Equal(t, http.StatusOK, mockQuotaRequest(r, "10.0.0.2:1234", ""))
+	assert.Equal(t, http.StatusTooManyRequests, mockQuotaRequest(r, "10.0.0.1:1234", "secret"))
+}
+
+func TestDailyQuotaIsSharedByValidatedAPIKey(t *testing.T) {
+	store, _ := newMemoryQuotaStore("")
+	q := &DailyQuota{Limit: 2, Store: store}
+	r := gin.New()
+	r.GET("/routes", requireAPIKey("secret", "X-Custom-Key"), q.middleware(), func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+
+	request := func(remoteAddr string) int {
+		rec := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodGet, "/routes", nil)
+		req.RemoteAddr = remoteAddr
+		req.Header.Set("X-Custom-Key", "secret")
+		r.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	assert.Equal(t, http.StatusOK, request("10.0.0.1:1234"))
+	assert.Equal(t, http.StatusOK, request("10.0.0.2:1234"))
+	assert.Equal(t, http.StatusTooManyRequests, request("10.0.0.3:1234"))
+	assert.NotContains(t, store.Counts, "key:secret")
+}
+
+func TestDailyQuotaTrustsForwardedForOnlyFromTrustedProxies(t *testing.T) {
+	store, _ := newMemoryQuotaStore("")
+	dailyQuota = &DailyQuota{Limit: 1, Store: store}
+	defer func() {
+		dailyQuota = nil
+		trustedProxies = nil
+	}()
+
+	request := func(r *gin.Engine, forwardedFor string) int {
+		rec := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodGet, "/routes", nil)
+		req.RemoteAddr = "10.0.0.1:1234"
+		req.Header.Set("X-Forwarded-For", forwardedFor)
+		r.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	// Without trusted proxies every request counts for the address of the connection
+	r := setupRouter(Config{})
+	assert.Equal(t, http.StatusBadRequest, request(r, "192.0.2.1"))
+	assert.Equal(t, http.StatusTooManyRequests, request(r, "192.0.2.2"))
+
+	trustedProxies = []string{"10.0.0.0/8"}
+	r = setupRouter(Config{})
+	assert.Equal(t, http.StatusBadRequest, request(r, "192.0.2.3"))
+	assert.Equal(t, http.StatusBadRequest, request(r, "192.0.2.4"))
+	assert.Equal(t, http.StatusTooManyRequests, request(r, "192.0.2.4"))
+}
+
+func TestDailyQuotaResetsOnNextDay(t *testing.T) {
+	now := time.Date(2023, 6, 1, 23, 59, 0, 0, time.UTC)
+	timeNow = func() time.Time { return now }
+	defer func() { timeNow = time.Now }()
+
+	store, _ := newMemoryQuotaStore("")
+	r := setupQuotaRouter(&DailyQuota{Limit: 1, Store: store})
+
+	assert.Equal(t, http.StatusOK, mockQuotaRequest(r, "10.0.0.1:1234", ""))
+	assert.Equal(t, http.StatusTooManyRequests, mockQuotaRequest(r, "10.0.0.1:1234", ""))
+
+	now = now.Add(time.Minute)
+	assert.Equal(t, http.StatusOK, mockQuotaRequest(r, "10.0.0.1:1234", ""))
+}
+
+func TestDailyQuotaIsPersistedAcrossRestarts(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "quota.json")
+
+	store, err := newMemoryQuotaStore(path)
+	assert.Nil(t, err)
+	r := setupQuotaRouter(&DailyQuota{Limit: 1, Store: store})
+	assert.Equal(t, http.StatusOK, mockQuotaRequest(r, "10.0.0.1:1234", ""))
+
+	// Counters only reach the disk with a flush
+	unflushed, err := newMemoryQuotaStore(path)
+	assert.Nil(t, err)
+	assert.Empty(t, unflushed.Counts)
+	assert.Nil(t, store.Flush())
+
+	restarted, err := newMemoryQuotaStore(path)
+	assert.Nil(t, err)
+	r = setupQuotaRouter(&DailyQuota{Limit: 1, Store: restarted})
+	assert.Equal(t, http.StatusTooManyRequests, mockQuotaRequest(r, "10.0.0.1:1234", ""))
+}
+
+func TestDailyQuotaIsSharedWithinSubnet(t *testing.T) {
+	store, _ := newMemoryQuotaStore("")
+	r := setupQuotaRouter(&DailyQuota{Limit: 2, Store: store, IPv4Prefix: 24, IPv6Prefix: 64})
+
+	assert.Equal(t, http.StatusOK, mockQuotaRequest(r, "[2001:db8:1:2::1]:1234", ""))
+	assert.Equal(t, http.StatusOK, mockQuotaRequest(r, "[2001:db8:1:2:ffff::9]:1234", ""))
+	assert.Equal(t, http.StatusTooManyRequests, mockQuotaRequest(r, "[2001:db8:1:2:abcd::1]:1234", ""))
+	assert.Equal(t, http.StatusOK, mockQuotaRequest(r, "[2001:db8:1:3::1]:1234", ""))
+
+	assert.Equal(t, http.StatusOK, mockQuotaRequest(r, "10.0.0.1:1234", ""))
+	assert.Equal(t, http.StatusOK, mockQuotaRequest(r, "10.0.0.2:1234", ""))
+	assert.Equal(t, http.StatusTooManyRequests, mockQuotaRequest(r, "10.0.0.3:1234", ""))
+	assert.Equal(t, http.StatusOK, mockQuotaRequest(r, "10.0.1.1:1234", ""))
+}
+
+func TestSubnet(t *testing.T) {
+	assert.Equal(t, "2001:db8:1:2::/64", subnet(net.ParseIP("2001:db8:1:2:3:4:5:6"), 64, 128))
+	assert.Equal(t, "2001:db8:1:2:3:4:5:6/128", subnet(net.ParseIP("2001:db8:1:2:3:4:5:6"), 0, 128))
+	assert.Equal(t, "10.0.0.1/32", subnet(net.ParseIP("10.0.0.1").To4(), 32, 32))
+}
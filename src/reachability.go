@@ -0,0 +1,29 @@
+package main
+
+import "sort"
+
+// ReachabilityBand counts the routed destinations reachable within Minutes of the source
+type ReachabilityBand struct {
+	Minutes   float64 `json:"minutes"`
+	Reachable int     `json:"reachable"`
+}
+
+// reachability counts the routes within each time band, in ascending order of the bands.
+// The bands are cumulative, a destination reachable in 4 minutes counts towards 5 and 10.
+func reachability(routes []Route, bands []float64) []ReachabilityBand {
+	sorted := append([]float64(nil), bands...)
+	sort.Float64s(sorted)
+
+	summary := make([]ReachabilityBand, 0, len(sorted))
+	for _, minutes := range sorted {
+		band := ReachabilityBand{Minutes: minutes}
+		for _, route := range routes {
+			if route.Duration <= minutes*60 {
+				band.Reachable++
+			}
+		}
+		summary = append(summary, band)
+	}
+
+	return summary
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReachability(t *testing.T) {
+	routes := []Route{{Duration: 120}, {Duration: 300}, {Duration: 301}, {Duration: 840}, {Duration: 2000}}
+
+	assert.Equal(t, []ReachabilityBand{
+		{Minutes: 5, Reachable: 2},
+		{Minutes: 10, Reachable: 3},
+		{Minutes: 15, Reachable: 4},
+	}, reachability(routes, []float64{10, 5, 15}))
+	assert.Equal(t, []ReachabilityBand{{Minutes: 1, Reachable: 0}}, reachability(nil, []float64{1}))
+}
+
+func TestGetRoutesReturnsReachabilityBands(t *testing.T) {
+	mockOsrmApi := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		if strings.Contains(r.URL.Path, "13.397634,52.529407") {
+			w.Write([]byte(`{"code":"Ok", "routes": [{"duration":490.1,"distance":3286.3}]}`))
+			return
+		}
+		w.Write([]byte(`{"code":"Ok", "routes": [{"duration":260.1,"distance":1886.3}]}`))
+	}))
+	defer mockOsrmApi.Close()
+
+	router = setupRouter(Config{OsrmBaseURL: mockOsrmApi.URL})
+	rec := mockGetRoutesRequest(fmt.Sprintf("/routes?src=13.388860,52.517037&dst=%s&dst=%s&bands=5&bands=10&bands=15",
+		"13.397634,52.529407", "13.428555,52.523219"))
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(),
+		`"reachability":[{"minutes":5,"reachable":1},{"minutes":10,"reachable":2},{"minutes":15,"reachable":2}]}`)
+}
+
+func TestGetRoutesReturns400WhenBandIsNotPositive(t *testing.T) {
+	rec := mockGetRoutesRequest("/routes?src=13.388860,52.517037&dst=13.397634,52.529407&bands=0")
+
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"message":"Bands[0] must be greater than 0"`)
+}
@@ -0,0 +1,182 @@
+package main
+
+import (
+	"container/list"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultRouteCacheTTL = 5 * time.Minute
+
+	// How long past the TTL a route may be served to clients accepting stale ones with max-stale
+	defaultRouteCacheMaxStale = time.Hour
+)
+
+// lruCache keeps up to size values for ttl, evicting the least recently used one when it is full.
+// It is shared by the goroutines of all requests, so every access takes the lock.
+type lruCache[K comparable, V any] struct {
+	mu      sync.Mutex
+	size    int
+	ttl     time.Duration
+	entries map[K]*list.Element
+
+	// How long past the ttl expired values are kept for lookups that accept stale ones
+	maxStale time.Duration
+
+	// Most recently used first
+	order *list.List
+
+	now func() time.Time
+}
+
+type lruEntry[K comparable, V any] struct {
+	key     K
+	value   V
+	expires time.Time
+}
+
+// routeLRU caches the routes of recent OSRM calls by routeCacheKey
+type routeLRU = lruCache[string, Route]
+
+// routeCacheKey identifies a route by the OSRM URL it was fetched from and the options shaping it
+func routeCacheKey(osrmURL string, opts RouteOptions) string {
+	opts.BypassCache = false
+	opts.MaxStale = 0
+
+	return fmt.Sprintf("%s|%+v", osrmURL, opts)
+}
+
+// isRouteCached reports whether the routes from src to dst are cached for every profile, so routing
+// them won't call OSRM
+func isRouteCached(cfg Config, src string, dst string, opts RouteOptions, profiles []string) bool {
+	if cfg.RouteCache == nil || opts.BypassCache {
+		return false
+	}
+
+	for _, profile := range profiles {
+		opts.Profile = profile
+		osrmURL, _ := osrmRouteURL(cfg, src, dst, opts)
+		if !cfg.RouteCache.has(routeCacheKey(osrmURL, opts), opts.MaxStale) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func newRouteLRU(size int, ttl time.Duration) *routeLRU {
+	return newLRUCache[string, Route](size, ttl)
+}
+
+// maxStaleFromCacheControl returns how long past its TTL a client accepts a cached route, from the
+// max-stale directive of its Cache-Control header. A bare max-stale accepts any age, which the
+// cache bounds by its own maxStale.
+func maxStaleFromCacheControl(header string) time.Duration {
+	for _, directive := range strings.Split(header, ",") {
+		name, value, hasValue := strings.Cut(strings.TrimSpace(directive), "=")
+		if !strings.EqualFold(name, "max-stale") {
+			continue
+		}
+		if !hasValue {
+			return math.MaxInt64
+		}
+
+		seconds, err := strconv.ParseInt(strings.Trim(value, `"`), 10, 64)
+		if err != nil || seconds < 0 {
+			return 0
+		}
+		if seconds > int64(math.MaxInt64/time.Second) {
+			return math.MaxInt64
+		}
+		return time.Duration(seconds) * time.Second
+	}
+
+	return 0
+}
+
+func newLRUCache[K comparable, V any](size int, ttl time.Duration) *lruCache[K, V] {
+	return &lruCache[K, V]{
+		size:    size,
+		ttl:     ttl,
+		entries: make(map[K]*list.Element, size),
+		order:   list.New(),
+		now:     time.Now,
+	}
+}
+
+// get returns the value cached under key, ok is false when there is none or it expired
+func (c *lruCache[K, V]) get(key K) (value V, ok bool) {
+	return c.getStale(key, 0)
+}
+
+// getStale is get accepting a value that expired up to maxStale ago, bounded by the maxStale of the
+// cache
+func (c *lruCache[K, V]) getStale(key K, maxStale time.Duration) (value V, ok bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	element, ok := c.entries[key]
+	if !ok {
+		return value, false
+	}
+
+	entry := element.Value.(*lruEntry[K, V])
+	now := c.now()
+	if !now.Before(entry.expires.Add(c.maxStale)) {
+		c.order.Remove(element)
+		delete(c.entries, key)
+		return value, false
+	}
+	if !now.Before(entry.expires.Add(c.acceptedStaleness(maxStale))) {
+		return value, false
+	}
+	c.order.MoveToFront(element)
+
+	return entry.value, true
+}
+
+// has reports whether a value is cached under key that expired no more than maxStale ago, without
+// making it the most recently used
+func (c *lruCache[K, V]) has(key K, maxStale time.Duration) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	element, ok := c.entries[key]
+
+	return ok && c.now().Before(element.Value.(*lruEntry[K, V]).expires.Add(c.acceptedStaleness(maxStale)))
+}
+
+func (c *lruCache[K, V]) acceptedStaleness(maxStale time.Duration) time.Duration {
+	if maxStale > c.maxStale {
+		return c.maxStale
+	}
+	if maxStale < 0 {
+		return 0
+	}
+	return maxStale
+}
+
+func (c *lruCache[K, V]) put(key K, value V) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	expires := c.now().Add(c.ttl)
+	if element, ok := c.entries[key]; ok {
+		entry := element.Value.(*lruEntry[K, V])
+		entry.value, entry.expires = value, expires
+		c.order.MoveToFront(element)
+		return
+	}
+
+	c.entries[key] = c.order.PushFront(&lruEntry[K, V]{key: key, value: value, expires: expires})
+	if c.order.Len() > c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*lruEntry[K, V]).key)
+	}
+}
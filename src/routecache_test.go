@@ -0,0 +1,205 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRouteLRUEvictsLeastRecentlyUsed(t *testing.T) {
+	cache := newRouteLRU(2, time.Minute)
+	cache.put("a", Route{Destination: "a"})
+	cache.put("b", Route{Destination: "b"})
+
+	// Using a makes b the least recently used
+	_, ok := cache.get("a")
+	assert.True(t, ok)
+	cache.put("c", Route{Destination: "c"})
+
+	_, ok = cache.get("b")
+	assert.False(t, ok)
+	route, ok := cache.get("a")
+	assert.True(t, ok)
+	assert.Equal(t, Route{Destination: "a"}, route)
+	_, ok = cache.get("c")
+	assert.True(t, ok)
+}
+
+func TestRouteLRUExpiresEntries(t *testing.T) {
+	now := time.Date(2023, 6, 1, 12, 0, 0, 0, time.UTC)
+	cache := newRouteLRU(10, time.Minute)
+	cache.now = func() time.Time { return now }
+
+	cache.put("a", Route{Destination: "a"})
+	now = now.Add(59 * time.Second)
+	_, ok := cache.get("a")
+	assert.True(t, ok)
+
+	now = now.Add(time.Second)
+	_, ok = cache.get("a")
+	assert.False(t, ok)
+	assert.Equal(t, 0, cache.order.Len())
+}
+
+func TestRouteLRUServesStaleEntriesUpToMaxStale(t *testing.T) {
+	now := time.Date(2023, 6, 1, 12, 0, 0, 0, time.UTC)
+	cache := newRouteLRU(10, time.Minute)
+	cache.maxStale = 10 * time.Minute
+	cache.now = func() time.Time { return now }
+
+	cache.put("a", Route{Destination: "a"})
+	now = now.Add(3 * time.Minute)
+
+	// Two minutes past the TTL
+	_, ok := cache.get("a")
+	assert.False(t, ok)
+	_, ok = cache.getStale("a", time.Minute)
+	assert.False(t, ok)
+	route, ok := cache.getStale("a", 5*time.Minute)
+	assert.True(t, ok)
+	assert.Equal(t, Route{Destination: "a"}, route)
+	assert.True(t, cache.has("a", 5*time.Minute))
+	assert.False(t, cache.has("a", 0))
+
+	// Asking for more than the cache keeps is bounded by it
+	now = now.Add(10 * time.Minute)
+	_, ok = cache.getStale("a", time.Hour)
+	assert.False(t, ok)
+	assert.Equal(t, 0, cache.order.Len())
+}
+
+func TestMaxStaleFromCacheControl(t *testing.T) {
+	for header, want := range map[string]time.Duration{
+		"":                          0,
+		"no-cache":                  0,
+		"max-stale=120":             2 * time.Minute,
+		"no-transform, max-stale=5": 5 * time.Second,
+		`Max-Stale="30"`:            30 * time.Second,
+		"max-stale":                 math.MaxInt64,
+		"max-stale=soon":            0,
+		"max-stale=-5":              0,
+	} {
+		assert.Equal(t, want, maxStaleFromCacheControl(header), header)
+	}
+}
+
+func TestRouteLRUIsSafeForConcurrentUse(t *testing.T) {
+	cache := newRouteLRU(8, time.Minute)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			key := fmt.Sprint(i % 10)
+			cache.put(key, Route{Destination: key})
+			cache.get(key)
+		}(i)
+	}
+	wg.Wait()
+
+	assert.Equal(t, 8, cache.order.Len())
+	assert.Len(t, cache.entries, 8)
+}
+
+func TestGetRoutesAnswersRepeatedRoutesFromCache(t *testing.T) {
+	var calls int32
+	mockOsrmApi := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"code":"Ok", "routes": [{"duration":2490.1,"distance":3286.3}]}`))
+	}))
+	defer mockOsrmApi.Close()
+
+	router = setupRouter(Config{OsrmBaseURL: mockOsrmApi.URL, RouteCache: newRouteLRU(100, time.Minute)})
+	hits := testutil.ToFloat64(metrics.routeCacheLookups.WithLabelValues("hit"))
+	misses := testutil.ToFloat64(metrics.routeCacheLookups.WithLabelValues("miss"))
+
+	url := "/routes?src=13.388860,52.517037&dst=13.397634,52.529407"
+	first := mockGetRoutesRequest(url)
+	second := mockGetRoutesRequest(url)
+
+	assert.Equal(t, http.StatusOK, second.Code)
+	assert.Contains(t, first.Body.String(), `"distance":3286.3,"cached":false}`)
+	assert.Contains(t, second.Body.String(), `"distance":3286.3,"cached":true}`)
+	assert.Equal(t, first.Body.String(), strings.Replace(second.Body.String(), `"cached":true`, `"cached":false`, 1))
+	assert.Equal(t, int32(1), atomic.LoadInt32(&calls))
+
+	// Other options ask OSRM for something else
+	assert.Equal(t, http.StatusOK, mockGetRoutesRequest(url+"&profile=cycling").Code)
+	assert.Equal(t, int32(2), atomic.LoadInt32(&calls))
+
+	// Turns and road classes share the OSRM call but not the route
+	assert.Equal(t, http.StatusOK, mockGetRoutesRequest(url+"&turns=true").Code)
+	assert.Equal(t, http.StatusOK, mockGetRoutesRequest(url+"&roadClasses=true").Code)
+	assert.Equal(t, int32(4), atomic.LoadInt32(&calls))
+
+	// cache=false always asks OSRM
+	rec := mockGetRoutesRequest(url + "&cache=false")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"cached":false`)
+	assert.Equal(t, int32(5), atomic.LoadInt32(&calls))
+
+	assert.Equal(t, hits+1, testutil.ToFloat64(metrics.routeCacheLookups.WithLabelValues("hit")))
+	assert.Equal(t, misses+4, testutil.ToFloat64(metrics.routeCacheLookups.WithLabelValues("miss")))
+}
+
+func TestGetRoutesServesStaleRoutesWithMaxStale(t *testing.T) {
+	var calls int32
+	mockOsrmApi := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"code":"Ok", "routes": [{"duration":2490.1,"distance":3286.3}]}`))
+	}))
+	defer mockOsrmApi.Close()
+
+	now := time.Date(2023, 6, 1, 12, 0, 0, 0, time.UTC)
+	cache := newRouteLRU(100, time.Minute)
+	cache.maxStale = 10 * time.Minute
+	cache.now = func() time.Time { return now }
+	router = setupRouter(Config{OsrmBaseURL: mockOsrmApi.URL, RouteCache: cache})
+
+	url := "/routes?src=13.388860,52.517037&dst=13.397634,52.529407"
+	request := func(cacheControl string) string {
+		rec := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodGet, url, nil)
+		req.Header.Set("Cache-Control", cacheControl)
+		router.ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusOK, rec.Code)
+
+		return rec.Body.String()
+	}
+
+	assert.Contains(t, request(""), `"cached":false`)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&calls))
+
+	// Five minutes past the TTL, served to clients accepting that much
+	now = now.Add(6 * time.Minute)
+	assert.Contains(t, request("max-stale=600"), `"cached":true`)
+	assert.Contains(t, request("max-stale"), `"cached":true`)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&calls))
+
+	// Refetched for a client accepting less, which makes the cached route fresh again
+	assert.Contains(t, request("max-stale=60"), `"cached":false`)
+	assert.Equal(t, int32(2), atomic.LoadInt32(&calls))
+	assert.Contains(t, request(""), `"cached":true`)
+
+	// Without max-stale nothing past the TTL is served
+	now = now.Add(2 * time.Minute)
+	assert.Contains(t, request(""), `"cached":false`)
+	assert.Equal(t, int32(3), atomic.LoadInt32(&calls))
+
+	// Nor past the bound of the cache, however much is accepted
+	now = now.Add(12 * time.Minute)
+	assert.Contains(t, request("max-stale"), `"cached":false`)
+	assert.Equal(t, int32(4), atomic.LoadInt32(&calls))
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"fmt"
+	"math"
+)
+
+const (
+	geometryLimitSimplify = "simplify"
+	geometryLimitReject   = "reject"
+)
+
+var (
+	// Maximum number of geometry points per route, 0 means no limit
+	maxGeometryPoints = 0
+
+	// What happens to a route whose geometry has more than maxGeometryPoints
+	geometryLimitPolicy = geometryLimitSimplify
+)
+
+// limitGeometry applies the geometry limit to the route, simplifying its geometry or
+// returning an error depending on geometryLimitPolicy
+func limitGeometry(route *Route) error {
+	n := len(route.geometry)
+	if maxGeometryPoints <= 0 || n <= maxGeometryPoints {
+		return nil
+	}
+
+	if geometryLimitPolicy == geometryLimitReject {
+		return &RouteError{
+			Category: categoryGeometryTooLarge,
+			Message:  fmt.Sprintf("route geometry has %d points, more than the maximum of %d", n, maxGeometryPoints),
+		}
+	}
+
+	route.geometry = simplifyToCount(route.geometry, maxGeometryPoints)
+	return nil
+}
+
+// simplifyToCount simplifies the line with Douglas-Peucker, doubling the tolerance
+// until it has at most maxPoints points. The first and last points are always kept.
+func simplifyToCount(points []Coordinate, maxPoints int) []Coordinate {
+	if maxPoints < 2 {
+		maxPoints = 2
+	}
+
+	simplified := points
+	for tolerance := 1.0; len(simplified) > maxPoints; tolerance *= 2 {
+		simplified = douglasPeucker(points, tolerance)
+	}
+
+	return simplified
+}
+
+// douglasPeucker drops every point closer than tolerance meters to the line it would be replaced by
+func douglasPeucker(points []Coordinate, tolerance float64) []Coordinate {
+	if len(points) < 3 {
+		return points
+	}
+
+	last := len(points) - 1
+	index, farthest := 0, 0.0
+	for i := 1; i < last; i++ {
+		if d := segmentDistance(points[i], points[0], points[last]); d > farthest {
+			index, farthest = i, d
+		}
+	}
+
+	if farthest <= tolerance {
+		return []Coordinate{points[0], points[last]}
+	}
+
+	left := douglasPeucker(points[:index+1], tolerance)
+	right := douglasPeucker(points[index:], tolerance)
+
+	return append(left[:len(left)-1:len(left)-1], right...)
+}
+
+// segmentDistance returns the distance in meters from p to the segment between a and b,
+// projecting the coordinates onto a plane which is accurate enough at route scale
+func segmentDistance(p Coordinate, a Coordinate, b Coordinate) float64 {
+	scale := math.Cos(a.Lat * math.Pi / 180)
+	toMeters := earthRadiusMeters * math.Pi / 180
+
+	px, py := (p.Lng-a.Lng)*scale*toMeters, (p.Lat-a.Lat)*toMeters
+	bx, by := (b.Lng-a.Lng)*scale*toMeters, (b.Lat-a.Lat)*toMeters
+
+	length := bx*bx + by*by
+	if length == 0 {
+		return math.Hypot(px, py)
+	}
+
+	t := math.Max(0, math.Min(1, (px*bx+py*by)/length))
+	return math.Hypot(px-t*bx, py-t*by)
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSimplifyToCount(t *testing.T) {
+	// A zigzag along a straight line with a single large detour in the middle
+	points := []Coordinate{
+		{Lng: 13.0, Lat: 52.0},
+		{Lng: 13.001, Lat: 52.00001},
+		{Lng: 13.002, Lat: 52.0},
+		{Lng: 13.003, Lat: 52.01},
+		{Lng: 13.004, Lat: 52.0},
+		{Lng: 13.005, Lat: 52.00001},
+		{Lng: 13.006, Lat: 52.0},
+	}
+
+	assert.Equal(t, []Coordinate{points[0], points[3], points[6]}, simplifyToCount(points, 3))
+	assert.Equal(t, []Coordinate{points[0], points[6]}, simplifyToCount(points, 2))
+	assert.Equal(t, points, simplifyToCount(points, 10))
+}
+
+func TestGetRoutesLimitsGeometryPoints(t *testing.T) {
+	var coordinates []string
+	for i := 0; i < 50; i++ {
+		coordinates = append(coordinates, fmt.Sprintf("[13.%03d,52.%03d]", i, i%2))
+	}
+
+	mockOsrmApi := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"code":"Ok", "routes": [{"duration":260.1,"distance":1886.3,` +
+			`"geometry":{"type":"LineString","coordinates":[` + strings.Join(coordinates, ",") + `]}}]}`))
+	}))
+	defer mockOsrmApi.Close()
+
+	cfg := Config{OsrmBaseURL: mockOsrmApi.URL}
+	router = setupRouter(cfg)
+	maxGeometryPoints = 10
+	defer func() {
+		maxGeometryPoints = 0
+		geometryLimitPolicy = geometryLimitSimplify
+	}()
+
+	url := "/routes?src=13.388860,52.517037&dst=13.397634,52.529407&format=gpx"
+
+	rec := mockGetRoutesRequest(url)
+	var doc gpxDocument
+	assert.Nil(t, xml.Unmarshal(rec.Body.Bytes(), &doc))
+	assert.Len(t, doc.Tracks, 1)
+	assert.LessOrEqual(t, len(doc.Tracks[0].Segments[0].Points), 10)
+	assert.Equal(t, gpxWaypoint{Lat: 52, Lon: 13}, doc.Tracks[0].Segments[0].Points[0])
+
+	geometryLimitPolicy = geometryLimitReject
+	rec = mockGetRoutesRequest(url)
+	doc = gpxDocument{}
+	assert.Nil(t, xml.Unmarshal(rec.Body.Bytes(), &doc))
+	assert.Empty(t, doc.Tracks)
+
+	_, err := getRouteData(context.Background(), cfg, "13.388860,52.517037", "13.397634,52.529407", RouteOptions{Geometry: true})
+	assert.Equal(t, Failure{
+		Destination: "13.397634,52.529407",
+		Status:      http.StatusUnprocessableEntity,
+		Category:    categoryGeometryTooLarge,
+		Message:     "route geometry has 50 points, more than the maximum of 10",
+	}, newFailure("13.397634,52.529407", err))
+}
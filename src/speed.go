@@ -0,0 +1,23 @@
+package main
+
+import "math"
+
+// applySpeedFactor rescales the durations for a vehicle travelling factor times as fast as the
+// backend assumes, flagging the routes as adjusted. Durations keep OSRM's single decimal.
+// The alternatives are scaled in a copy, their slice is shared with the route cache.
+func applySpeedFactor(routes []Route, factor float64) {
+	for i := range routes {
+		routes[i].Duration = math.Round(routes[i].Duration/factor*10) / 10
+		routes[i].Adjusted = true
+		if routes[i].Alternatives == nil {
+			continue
+		}
+
+		alternatives := make([]Alternative, len(routes[i].Alternatives))
+		for j, alternative := range routes[i].Alternatives {
+			alternative.Duration = math.Round(alternative.Duration/factor*10) / 10
+			alternatives[j] = alternative
+		}
+		routes[i].Alternatives = alternatives
+	}
+}
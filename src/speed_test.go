@@ -0,0 +1,68 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestApplySpeedFactor(t *testing.T) {
+	routes := []Route{
+		{Destination: "13.397634,52.529407", Duration: 2490.1, Distance: 3286.3},
+		{Destination: "12.428555,52.523219", Duration: 100, Distance: 500, Alternatives: []Alternative{{Duration: 120, Distance: 450}}},
+	}
+
+	applySpeedFactor(routes, 0.8)
+
+	assert.Equal(t, []Route{
+		{Destination: "13.397634,52.529407", Duration: 3112.6, Distance: 3286.3, Adjusted: true},
+		{Destination: "12.428555,52.523219", Duration: 125, Distance: 500, Adjusted: true, Alternatives: []Alternative{{Duration: 150, Distance: 450}}},
+	}, routes)
+}
+
+func TestGetRoutesScalesDurationsBySpeedFactor(t *testing.T) {
+	mockOsrmApi := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"code":"Ok", "routes": [{"duration":2490.1,"distance":3286.3}]}`))
+	}))
+	defer mockOsrmApi.Close()
+
+	router = setupRouter(Config{OsrmBaseURL: mockOsrmApi.URL})
+
+	rec := mockGetRoutesRequest("/routes?src=13.388860,52.517037&dst=13.397634,52.529407&speedFactor=2")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"routes":[{"destination":"13.397634,52.529407","duration":1245.1,"distance":3286.3,"adjusted":true}]`)
+
+	rec = mockGetRoutesRequest("/routes?src=13.388860,52.517037&dst=13.397634,52.529407")
+	assert.Contains(t, rec.Body.String(), `"routes":[{"destination":"13.397634,52.529407","duration":2490.1,"distance":3286.3}]`)
+}
+
+func TestGetRoutesScalesCachedAlternativesOnlyOnce(t *testing.T) {
+	mockOsrmApi := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"code":"Ok", "routes": [{"duration":100,"distance":3286.3},{"duration":100,"distance":3011.2}]}`))
+	}))
+	defer mockOsrmApi.Close()
+
+	router = setupRouter(Config{OsrmBaseURL: mockOsrmApi.URL, RouteCache: newRouteLRU(100, time.Minute)})
+
+	url := "/routes?src=13.388860,52.517037&dst=13.397634,52.529407&alternatives=1&speedFactor=2"
+	for i := 0; i < 3; i++ {
+		rec := mockGetRoutesRequest(url)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Contains(t, rec.Body.String(), `"duration":50,"distance":3286.3,"adjusted":true,"alternatives":[{"duration":50,"distance":3011.2}]`)
+	}
+}
+
+func TestGetRoutesReturns400WhenSpeedFactorIsOutOfRange(t *testing.T) {
+	rec := mockGetRoutesRequest("/routes?src=13.388860,52.517037&dst=13.397634,52.529407&speedFactor=0.05")
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Equal(t, `{"code":400,"message":"SpeedFactor must be at least 0.1","error_code":"invalid_parameter"}`, rec.Body.String())
+
+	rec = mockGetRoutesRequest("/routes?src=13.388860,52.517037&dst=13.397634,52.529407&speedFactor=11")
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Equal(t, `{"code":400,"message":"SpeedFactor must be at most 10","error_code":"invalid_parameter"}`, rec.Body.String())
+}
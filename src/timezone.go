@@ -0,0 +1,127 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Time zone boundaries hardly ever change, so resolved zones are kept for long
+const (
+	defaultTimezoneCacheSize = 10000
+	defaultTimezoneCacheTTL  = 24 * time.Hour
+)
+
+// Resolves the time zones of timezone=true, nil when no lookup is configured
+var timezoneLookup TimezoneLookup
+
+// TimezoneLookup resolves the IANA time zone, such as Europe/Berlin, of a coordinate
+type TimezoneLookup interface {
+	Lookup(ctx context.Context, c Coordinate) (string, error)
+}
+
+// HTTPTimezoneLookup asks an external service for the time zone. URL takes the latitude
+// and longitude, in that order, and the service answers with {"timezone": "Europe/Berlin"}.
+type HTTPTimezoneLookup struct {
+	URL string
+}
+
+func (l *HTTPTimezoneLookup) Lookup(ctx context.Context, c Coordinate) (string, error) {
+	lat := strconv.FormatFloat(c.Lat, 'f', -1, 64)
+	lng := strconv.FormatFloat(c.Lng, 'f', -1, 64)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, fmt.Sprintf(l.URL, lat, lng), nil)
+	if err != nil {
+		return "", err
+	}
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("timezone lookup response code: %d", resp.StatusCode)
+	}
+
+	var data struct {
+		Timezone string `json:"timezone"`
+	}
+	if err = json.NewDecoder(resp.Body).Decode(&data); err != nil {
+		return "", err
+	}
+
+	return data.Timezone, nil
+}
+
+// CachedTimezoneLookup remembers up to size zones it resolved for ttl, evicting the least recently
+// used one when it is full
+type CachedTimezoneLookup struct {
+	lookup TimezoneLookup
+	zones  *lruCache[Coordinate, string]
+}
+
+func newCachedTimezoneLookup(lookup TimezoneLookup, size int, ttl time.Duration) *CachedTimezoneLookup {
+	return &CachedTimezoneLookup{lookup: lookup, zones: newLRUCache[Coordinate, string](size, ttl)}
+}
+
+func (l *CachedTimezoneLookup) Lookup(ctx context.Context, c Coordinate) (string, error) {
+	if zone, ok := l.zones.get(c); ok {
+		return zone, nil
+	}
+
+	zone, err := l.lookup.Lookup(ctx, c)
+	if err != nil {
+		return "", err
+	}
+	l.zones.put(c, zone)
+
+	return zone, nil
+}
+
+// addTimezones sets the time zone of every route's destination, looking up up to
+// cfg.MaxConcurrency zones at a time. Destinations whose zone can't be resolved are left
+// without one and reported in the returned warnings.
+func addTimezones(ctx context.Context, cfg Config, routes []Route) []string {
+	failed := make([]bool, len(routes))
+	inFlight := newInFlightLimit(cfg)
+
+	var wg sync.WaitGroup
+	for i := range routes {
+		c, err := parseCoordinate(routes[i].Destination)
+		if err != nil {
+			continue
+		}
+
+		inFlight <- struct{}{}
+		wg.Add(1)
+		go func(i int, c Coordinate) {
+			defer func() {
+				<-inFlight
+				wg.Done()
+			}()
+
+			zone, err := timezoneLookup.Lookup(ctx, c)
+			if err != nil {
+				failed[i] = true
+				return
+			}
+			routes[i].Timezone = zone
+		}(i, c)
+	}
+	wg.Wait()
+
+	var warnings []string
+	for i := range routes {
+		if failed[i] {
+			warnings = append(warnings, fmt.Sprintf("Could not resolve the time zone of %s", routes[i].Destination))
+		}
+	}
+
+	return warnings
+}
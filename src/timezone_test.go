@@ -0,0 +1,121 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func mockTimezoneApi(lookups *int32) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(lookups, 1)
+		switch r.URL.Query().Get("lat") + "," + r.URL.Query().Get("lng") {
+		case "52.529407,13.397634":
+			w.Write([]byte(`{"timezone":"Europe/Berlin"}`))
+		case "40.712776,-74.005974":
+			w.Write([]byte(`{"timezone":"America/New_York"}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+}
+
+func TestCachedTimezoneLookup(t *testing.T) {
+	var lookups int32
+	mockApi := mockTimezoneApi(&lookups)
+	defer mockApi.Close()
+
+	lookup := newCachedTimezoneLookup(&HTTPTimezoneLookup{URL: mockApi.URL + "/lookup?lat=%s&lng=%s"}, 100, time.Minute)
+
+	for i := 0; i < 3; i++ {
+		zone, err := lookup.Lookup(context.Background(), Coordinate{Lng: 13.397634, Lat: 52.529407})
+		assert.Nil(t, err)
+		assert.Equal(t, "Europe/Berlin", zone)
+	}
+
+	zone, err := lookup.Lookup(context.Background(), Coordinate{Lng: -74.005974, Lat: 40.712776})
+	assert.Nil(t, err)
+	assert.Equal(t, "America/New_York", zone)
+	assert.Equal(t, int32(2), atomic.LoadInt32(&lookups))
+
+	_, err = lookup.Lookup(context.Background(), Coordinate{Lng: 0, Lat: 0})
+	assert.NotNil(t, err)
+}
+
+func TestCachedTimezoneLookupEvictsLeastRecentlyUsed(t *testing.T) {
+	var lookups int32
+	mockApi := mockTimezoneApi(&lookups)
+	defer mockApi.Close()
+
+	lookup := newCachedTimezoneLookup(&HTTPTimezoneLookup{URL: mockApi.URL + "/lookup?lat=%s&lng=%s"}, 1, time.Minute)
+	berlin, newYork := Coordinate{Lng: 13.397634, Lat: 52.529407}, Coordinate{Lng: -74.005974, Lat: 40.712776}
+
+	for _, c := range []Coordinate{berlin, newYork, berlin} {
+		_, err := lookup.Lookup(context.Background(), c)
+		assert.Nil(t, err)
+	}
+	assert.Equal(t, int32(3), atomic.LoadInt32(&lookups))
+	assert.Equal(t, 1, lookup.zones.order.Len())
+}
+
+func TestCachedTimezoneLookupStopsWhenContextIsDone(t *testing.T) {
+	var lookups int32
+	mockApi := mockTimezoneApi(&lookups)
+	defer mockApi.Close()
+
+	lookup := newCachedTimezoneLookup(&HTTPTimezoneLookup{URL: mockApi.URL + "/lookup?lat=%s&lng=%s"}, 100, time.Minute)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := lookup.Lookup(ctx, Coordinate{Lng: 13.397634, Lat: 52.529407})
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Equal(t, int32(0), atomic.LoadInt32(&lookups))
+}
+
+func TestGetRoutesReturnsTimezones(t *testing.T) {
+	var lookups int32
+	mockApi := mockTimezoneApi(&lookups)
+	defer mockApi.Close()
+
+	mockOsrmApi := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		if strings.Contains(r.URL.Path, "1.0,1.0") {
+			w.Write([]byte(`{"code":"Ok", "routes": [{"duration":9490.1,"distance":9286.3}]}`))
+			return
+		}
+		w.Write([]byte(`{"code":"Ok", "routes": [{"duration":2490.1,"distance":3286.3}]}`))
+	}))
+	defer mockOsrmApi.Close()
+
+	router = setupRouter(Config{OsrmBaseURL: mockOsrmApi.URL})
+	timezoneLookup = newCachedTimezoneLookup(&HTTPTimezoneLookup{URL: mockApi.URL + "/lookup?lat=%s&lng=%s"}, 100, time.Minute)
+	defer func() { timezoneLookup = nil }()
+
+	rec := mockGetRoutesRequest("/routes?src=13.388860,52.517037&dst=13.397634,52.529407&dst=1.0,1.0&timezone=true")
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"routes":[`+
+		`{"destination":"13.397634,52.529407","duration":2490.1,"distance":3286.3,"timezone":"Europe/Berlin"},`+
+		`{"destination":"1.0,1.0","duration":9490.1,"distance":9286.3}],`+
+		`"warnings":["Could not resolve the time zone of 1.0,1.0"]`)
+}
+
+func TestGetRoutesWarnsWhenTimezonesAreNotConfigured(t *testing.T) {
+	mockOsrmApi := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"code":"Ok", "routes": [{"duration":2490.1,"distance":3286.3}]}`))
+	}))
+	defer mockOsrmApi.Close()
+
+	router = setupRouter(Config{OsrmBaseURL: mockOsrmApi.URL})
+	rec := mockGetRoutesRequest("/routes?src=13.388860,52.517037&dst=13.397634,52.529407&timezone=true")
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"warnings":["Time zones are not available on this server"]`)
+}
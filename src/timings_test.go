@@ -0,0 +1,62 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLatencyWindowPercentiles(t *testing.T) {
+	w := newLatencyWindow(100)
+	for i := 100; i >= 1; i-- {
+		w.record(time.Duration(i) * time.Millisecond)
+	}
+
+	sorted := w.snapshot()
+
+	assert.Len(t, sorted, 100)
+	assert.Equal(t, 50*time.Millisecond, percentile(sorted, 50))
+	assert.Equal(t, 90*time.Millisecond, percentile(sorted, 90))
+	assert.Equal(t, 99*time.Millisecond, percentile(sorted, 99))
+}
+
+func TestLatencyWindowOnlyKeepsRecentSamples(t *testing.T) {
+	w := newLatencyWindow(10)
+	for i := 0; i < 10; i++ {
+		w.record(time.Second)
+	}
+	for i := 0; i < 10; i++ {
+		w.record(time.Millisecond)
+	}
+
+	assert.Equal(t, time.Millisecond, percentile(w.snapshot(), 99))
+}
+
+func TestPercentileOfEmptyWindow(t *testing.T) {
+	assert.Equal(t, time.Duration(0), percentile(newLatencyWindow(10).snapshot(), 50))
+}
+
+func TestDebugTimingsEndpoint(t *testing.T) {
+	rec := mockGetRoutesRequest("/debug/timings")
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+
+	debugTimings = true
+	defer func() { debugTimings = false }()
+
+	original := osrmLatencies
+	defer func() { osrmLatencies = original }()
+	osrmLatencies = newLatencyWindow(10)
+	for i := 1; i <= 10; i++ {
+		osrmLatencies.record(time.Duration(i) * 10 * time.Millisecond)
+	}
+
+	rec = httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodGet, "/debug/timings", nil)
+	setupRouter(configFromEnv()).ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, `{"count":10,"p50":50,"p90":90,"p99":100}`, rec.Body.String())
+}
@@ -0,0 +1,60 @@
+package main
+
+// UnionResp lists every destination reachable from any of the sources once, together with the
+// source it is reached fastest from, e.g. to draw the area covered by a set of depots
+type UnionResp struct {
+	Destinations []BestSource `json:"destinations"`
+	Unreachable  []string     `json:"unreachable,omitempty"`
+	Units        string       `json:"units,omitempty"`
+}
+
+// BestSource is the fastest of the routes from the sources to a destination
+type BestSource struct {
+	Destination     string   `json:"destination"`
+	Source          string   `json:"source"`
+	Duration        float64  `json:"duration"`
+	Distance        float64  `json:"distance"`
+	DurationMinutes *float64 `json:"durationMinutes,omitempty"`
+	DistanceKm      *float64 `json:"distanceKm,omitempty"`
+	DistanceMiles   *float64 `json:"distanceMiles,omitempty"`
+}
+
+// unionOfDestinations picks the minimum over the sources of every column of the table. The
+// destinations must be unique, those no source reaches are listed as unreachable.
+func unionOfDestinations(srcs []string, dsts []string, data OsrmApiTableData) UnionResp {
+	resp := UnionResp{Destinations: make([]BestSource, 0, len(dsts))}
+
+	for j, dst := range dsts {
+		best := BestSource{Destination: dst}
+		found := false
+		for i, src := range srcs {
+			duration, distance := data.Durations[i][j], data.Distances[i][j]
+			if duration == nil || distance == nil {
+				continue
+			}
+
+			// Ties on duration go to the shorter distance, then to the source listed first, like GET /routes/nearest
+			if !found || *duration < best.Duration || (*duration == best.Duration && *distance < best.Distance) {
+				best.Source, best.Duration, best.Distance = src, *duration, *distance
+				found = true
+			}
+		}
+
+		if found {
+			resp.Destinations = append(resp.Destinations, best)
+		} else {
+			resp.Unreachable = append(resp.Unreachable, dst)
+		}
+	}
+
+	return resp
+}
+
+// addUnionUnits adds the duration in minutes and the distance in kilometers or miles to every
+// destination of the union
+func addUnionUnits(union *UnionResp, units string) {
+	union.Units = units
+	for i, best := range union.Destinations {
+		union.Destinations[i].DurationMinutes, union.Destinations[i].DistanceKm, union.Destinations[i].DistanceMiles = convertUnits(best.Duration, best.Distance, units)
+	}
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUnionOfDestinationsPicksBestSource(t *testing.T) {
+	f := func(v float64) *float64 { return &v }
+	srcs := []string{"13.388860,52.517037", "13.428555,52.523219", "13.412,52.5"}
+	dsts := []string{"13.397634,52.529407", "13.45,52.51", "13.5,52.6", "13.3,52.4"}
+	data := OsrmApiTableData{
+		Durations: [][]*float64{
+			{f(260.1), f(900), nil, f(100)},
+			{f(400.2), f(700.4), nil, f(100)},
+			{nil, f(700.4), nil, f(100)},
+		},
+		Distances: [][]*float64{
+			{f(1886.3), f(6000), nil, f(800)},
+			{f(3286.3), f(5100.9), nil, f(800)},
+			{nil, f(4900), nil, f(800)},
+		},
+	}
+
+	assert.Equal(t, UnionResp{
+		Destinations: []BestSource{
+			{Destination: "13.397634,52.529407", Source: "13.388860,52.517037", Duration: 260.1, Distance: 1886.3},
+			{Destination: "13.45,52.51", Source: "13.412,52.5", Duration: 700.4, Distance: 4900},
+			{Destination: "13.3,52.4", Source: "13.388860,52.517037", Duration: 100, Distance: 800},
+		},
+		Unreachable: []string{"13.5,52.6"},
+	}, unionOfDestinations(srcs, dsts, data))
+}
+
+func TestGetMatrixReturnsUnionOfDestinations(t *testing.T) {
+	var requests []string
+	mockOsrmApi := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.URL.Path+"?"+r.URL.RawQuery)
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"code":"Ok",` +
+			`"durations":[[260.1,2490.1,null],[400.2,700.4,null]],` +
+			`"distances":[[1886.3,3286.3,null],[3012.8,5100.9,null]]}`))
+	}))
+	defer mockOsrmApi.Close()
+
+	router = setupRouter(Config{OsrmBaseURL: mockOsrmApi.URL})
+	rec := mockGetRoutesRequest("/matrix?src=13.388860,52.517037&src=13.428555,52.523219" +
+		"&dst=13.397634,52.529407&dst=13.412,52.5&dst=13.397634,52.529407&dst=13.5,52.6&union=true")
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, []string{"/table/v1/driving/13.388860,52.517037;13.428555,52.523219;13.397634,52.529407;13.412,52.5;13.5,52.6" +
+		"?annotations=duration,distance&sources=0;1&destinations=2;3;4"}, requests)
+	assert.Equal(t, `{"destinations":[`+
+		`{"destination":"13.397634,52.529407","source":"13.388860,52.517037","duration":260.1,"distance":1886.3},`+
+		`{"destination":"13.412,52.5","source":"13.428555,52.523219","duration":700.4,"distance":5100.9}],`+
+		`"unreachable":["13.5,52.6"]}`, rec.Body.String())
+}
+
+func TestGetMatrixReturns502ForUnionWhenRowsAreShort(t *testing.T) {
+	mockOsrmApi := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"code":"Ok","durations":[[260.1,2490.1],[400.2]],"distances":[[1886.3,3286.3],[3012.8]]}`))
+	}))
+	defer mockOsrmApi.Close()
+
+	router = setupRouter(Config{OsrmBaseURL: mockOsrmApi.URL})
+	rec := mockGetRoutesRequest("/matrix?src=13.388860,52.517037&src=13.428555,52.523219" +
+		"&dst=13.397634,52.529407&dst=13.412,52.5&union=true")
+
+	assert.Equal(t, http.StatusBadGateway, rec.Code)
+	assert.Equal(t, `{"code":502,"message":"Could not compute the matrix: the table doesn't have a column per destination","error_code":"backend_error"}`, rec.Body.String())
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"math"
+	"strconv"
+	"strings"
+)
+
+const (
+	unitsMetric   = "metric"
+	unitsImperial = "imperial"
+
+	metersPerMile = 1609.344
+)
+
+var (
+	// Infer the units from the Accept-Language header of requests that don't ask for any
+	unitsFromLanguage = false
+
+	// Regions whose roads are signposted in miles
+	imperialRegions = map[string]bool{"US": true, "LR": true, "MM": true}
+)
+
+// unitsForLanguage infers the units from the most preferred language of an Accept-Language header
+// that names a region, such as en-US for imperial or de-DE for metric. Without a region, such as
+// plain en, nothing can be told and it returns "".
+func unitsForLanguage(header string) string {
+	var (
+		preferred string
+		best      = -1.0
+	)
+	for _, entry := range strings.Split(header, ",") {
+		tag, params, _ := strings.Cut(strings.TrimSpace(entry), ";")
+		q := 1.0
+		if value, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			parsed, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				continue
+			}
+			q = parsed
+		}
+
+		// Earlier entries win ties, as clients list them by preference
+		if tag != "" && tag != "*" && q > 0 && q > best {
+			preferred, best = tag, q
+		}
+	}
+
+	// The region is the first two letter subtag after the language, en-US or zh-Hant-TW
+	for _, subtag := range strings.Split(preferred, "-")[1:] {
+		if len(subtag) == 2 {
+			if imperialRegions[strings.ToUpper(subtag)] {
+				return unitsImperial
+			}
+			return unitsMetric
+		}
+	}
+
+	return ""
+}
+
+// addUnits adds the duration in minutes and the distance in kilometers or miles to the routes,
+// rounded to two decimal places. The seconds and meters are kept as they are.
+func addUnits(routes []Route, units string) {
+	for i := range routes {
+		routes[i].DurationMinutes, routes[i].DistanceKm, routes[i].DistanceMiles = convertUnits(routes[i].Duration, routes[i].Distance, units)
+	}
+}
+
+// convertUnits converts a duration in seconds to minutes and a distance in meters to kilometers,
+// or to miles with imperial units, rounded to two decimal places. The distance it doesn't convert
+// to is nil.
+func convertUnits(duration, distance float64, units string) (minutes, km, miles *float64) {
+	m := roundTo2(duration / 60)
+	if units == unitsImperial {
+		mi := roundTo2(distance / metersPerMile)
+		return &m, nil, &mi
+	}
+	k := roundTo2(distance / 1000)
+	return &m, &k, nil
+}
+
+// addMatrixUnits adds the durations in minutes and the distances in kilometers or miles to the
+// matrix, cell by cell. Cells OSRM couldn't route stay null.
+func addMatrixUnits(resp *MatrixResp, units string) {
+	resp.Units = units
+	resp.DurationsMinutes = make([][]*float64, len(resp.Durations))
+	distances := make([][]*float64, len(resp.Distances))
+	for i := range resp.Durations {
+		resp.DurationsMinutes[i] = make([]*float64, len(resp.Durations[i]))
+		distances[i] = make([]*float64, len(resp.Distances[i]))
+		for j := range resp.Durations[i] {
+			duration, distance := resp.Durations[i][j], resp.Distances[i][j]
+			if duration == nil || distance == nil {
+				continue
+			}
+
+			minutes, km, miles := convertUnits(*duration, *distance, units)
+			resp.DurationsMinutes[i][j] = minutes
+			if units == unitsImperial {
+				distances[i][j] = miles
+			} else {
+				distances[i][j] = km
+			}
+		}
+	}
+
+	if units == unitsImperial {
+		resp.DistancesMiles = distances
+	} else {
+		resp.DistancesKm = distances
+	}
+}
+
+func roundTo2(v float64) float64 {
+	return math.Round(v*100) / 100
+}
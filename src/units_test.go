@@ -0,0 +1,137 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetRoutesAddsHumanFriendlyUnits(t *testing.T) {
+	mockOsrmApi := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"code":"Ok", "routes": [{"duration":2490.1,"distance":3286.3}]}`))
+	}))
+	defer mockOsrmApi.Close()
+
+	router = setupRouter(Config{OsrmBaseURL: mockOsrmApi.URL})
+	url := "/routes?src=13.388860,52.517037&dst=13.397634,52.529407"
+
+	rec := mockGetRoutesRequest(url + "&units=metric")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"routes":[{"destination":"13.397634,52.529407","duration":2490.1,"distance":3286.3,"durationMinutes":41.5,"distanceKm":3.29}]`)
+
+	rec = mockGetRoutesRequest(url + "&units=imperial&naming=snake")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"routes":[{"destination":"13.397634,52.529407","duration":2490.1,"distance":3286.3,"duration_minutes":41.5,"distance_miles":2.04}]`)
+
+	rec = mockGetRoutesRequest(url)
+	assert.Contains(t, rec.Body.String(), `"routes":[{"destination":"13.397634,52.529407","duration":2490.1,"distance":3286.3}]`)
+}
+
+func TestGetRoutesReturns400WhenUnitsIsInvalid(t *testing.T) {
+	rec := mockGetRoutesRequest("/routes?src=13.388860,52.517037&dst=13.397634,52.529407&units=nautical")
+
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Equal(t, `{"code":400,"message":"Units is not valid","error_code":"invalid_parameter"}`, rec.Body.String())
+}
+
+func TestUnitsForLanguage(t *testing.T) {
+	assert.Equal(t, unitsImperial, unitsForLanguage("en-US"))
+	assert.Equal(t, unitsImperial, unitsForLanguage("en-us,en;q=0.9"))
+	assert.Equal(t, unitsMetric, unitsForLanguage("de-DE,de;q=0.9,en-US;q=0.8"))
+	assert.Equal(t, unitsMetric, unitsForLanguage("en-GB"))
+	assert.Equal(t, unitsMetric, unitsForLanguage("zh-Hant-TW"))
+	assert.Equal(t, unitsImperial, unitsForLanguage("fr-FR;q=0.5, en-US;q=0.9"))
+	assert.Equal(t, unitsMetric, unitsForLanguage("en-US;q=0, fr-CA"))
+
+	// Nothing to tell the region by
+	assert.Equal(t, "", unitsForLanguage(""))
+	assert.Equal(t, "", unitsForLanguage("en"))
+	assert.Equal(t, "", unitsForLanguage("*"))
+	assert.Equal(t, "", unitsForLanguage("es-419"))
+}
+
+func TestGetRoutesInfersUnitsFromAcceptLanguage(t *testing.T) {
+	mockOsrmApi := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"code":"Ok", "routes": [{"duration":2490.1,"distance":3286.3}]}`))
+	}))
+	defer mockOsrmApi.Close()
+
+	router = setupRouter(Config{OsrmBaseURL: mockOsrmApi.URL})
+	url := "/routes?src=13.388860,52.517037&dst=13.397634,52.529407"
+	request := func(url string, language string) string {
+		rec := httptest.NewRecorder()
+		req, _ := http.NewRequest(http.MethodGet, url, nil)
+		req.Header.Set("Accept-Language", language)
+		router.ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusOK, rec.Code)
+
+		return rec.Body.String()
+	}
+
+	// Opt-in, nothing is inferred by default
+	assert.Contains(t, request(url, "en-US"), `"routes":[{"destination":"13.397634,52.529407","duration":2490.1,"distance":3286.3}]`)
+
+	defer func() { unitsFromLanguage = false }()
+	unitsFromLanguage = true
+
+	assert.Contains(t, request(url, "en-US,en;q=0.9"), `"durationMinutes":41.5,"distanceMiles":2.04}]`)
+	assert.Contains(t, request(url, "de-DE,de;q=0.9"), `"durationMinutes":41.5,"distanceKm":3.29}]`)
+	assert.Contains(t, request(url, "en"), `"routes":[{"destination":"13.397634,52.529407","duration":2490.1,"distance":3286.3}]`)
+
+	// The units parameter always wins
+	assert.Contains(t, request(url+"&units=metric", "en-US"), `"durationMinutes":41.5,"distanceKm":3.29}]`)
+}
+
+func TestGetMatrixConvertsCellsToUnits(t *testing.T) {
+	var requests []string
+	mockOsrmApi := mockTableOsrmApi(&requests)
+	defer mockOsrmApi.Close()
+
+	router = setupRouter(Config{OsrmBaseURL: mockOsrmApi.URL})
+	url := "/matrix?src=13.388860,52.517037&src=13.428555,52.523219&dst=13.397634,52.529407&dst=13.412,52.5"
+
+	rec := mockGetRoutesRequest(url + "&units=metric")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"durations":[[260.1,2490.1],[null,700.4]],"distances":[[1886.3,3286.3],[null,5100.9]],`+
+		`"units":"metric","durationsMinutes":[[4.34,41.5],[null,11.67]],"distancesKm":[[1.89,3.29],[null,5.1]]}`)
+
+	rec = mockGetRoutesRequest(url + "&units=imperial")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"units":"imperial","durationsMinutes":[[4.34,41.5],[null,11.67]],"distancesMiles":[[1.17,2.04],[null,3.17]]}`)
+
+	rec = mockGetRoutesRequest(url + "&union=true&units=imperial")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, `{"destinations":[`+
+		`{"destination":"13.397634,52.529407","source":"13.388860,52.517037","duration":260.1,"distance":1886.3,"durationMinutes":4.34,"distanceMiles":1.17},`+
+		`{"destination":"13.412,52.5","source":"13.428555,52.523219","duration":700.4,"distance":5100.9,"durationMinutes":11.67,"distanceMiles":3.17}],`+
+		`"units":"imperial"}`, rec.Body.String())
+
+	// Without units the cells are only given in seconds and meters
+	rec = mockGetRoutesRequest(url)
+	assert.NotContains(t, rec.Body.String(), "units")
+
+	rec = mockGetRoutesRequest(url + "&units=nautical")
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+}
+
+func TestGetMatrixInfersUnitsFromAcceptLanguage(t *testing.T) {
+	var requests []string
+	mockOsrmApi := mockTableOsrmApi(&requests)
+	defer mockOsrmApi.Close()
+
+	router = setupRouter(Config{OsrmBaseURL: mockOsrmApi.URL})
+	defer func() { unitsFromLanguage = false }()
+	unitsFromLanguage = true
+
+	rec := httptest.NewRecorder()
+	req, _ := http.NewRequest(http.MethodGet, "/matrix?src=13.388860,52.517037&src=13.428555,52.523219&dst=13.397634,52.529407&dst=13.412,52.5", nil)
+	req.Header.Set("Accept-Language", "en-US")
+	router.ServeHTTP(rec, req)
+
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"units":"imperial"`)
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+)
+
+// warmUp routes a sample src/dst pair to verify the backend is reachable and configured correctly
+func warmUp(cfg Config, src string, dst string) error {
+	if !latLngPattern.MatchString(src) || !latLngPattern.MatchString(dst) {
+		return fmt.Errorf("invalid warm-up coordinates %s and %s", src, dst)
+	}
+
+	release, err := acquireFetchBudget(context.Background(), cfg.FetchBudget)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	_, err = getRouteData(context.Background(), cfg, src, dst, RouteOptions{})
+	return err
+}
+
+// runWarmUp only returns the warm-up error when strict, otherwise a failure is logged as a warning
+func runWarmUp(cfg Config, src string, dst string, strict bool) error {
+	err := warmUp(cfg, src, dst)
+	if err == nil {
+		log.Printf("warm-up route from %s to %s succeeded", src, dst)
+		return nil
+	}
+
+	if strict {
+		return fmt.Errorf("warm-up failed: %w", err)
+	}
+
+	log.Printf("WARNING: warm-up route from %s to %s failed, the routing backend may be misconfigured: %s", src, dst, err)
+	return nil
+}
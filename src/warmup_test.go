@@ -0,0 +1,37 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRunWarmUpFailsWhenStrict(t *testing.T) {
+	mockOsrmApi := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer mockOsrmApi.Close()
+
+	cfg := Config{OsrmBaseURL: mockOsrmApi.URL}
+
+	assert.NotNil(t, runWarmUp(cfg, "13.388860,52.517037", "13.397634,52.529407", true))
+	assert.Nil(t, runWarmUp(cfg, "13.388860,52.517037", "13.397634,52.529407", false))
+}
+
+func TestRunWarmUpSucceeds(t *testing.T) {
+	mockOsrmApi := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"code":"Ok", "routes": [{"duration":2490.1,"distance":3286.3}]}`))
+	}))
+	defer mockOsrmApi.Close()
+
+	cfg := Config{OsrmBaseURL: mockOsrmApi.URL}
+
+	assert.Nil(t, runWarmUp(cfg, "13.388860,52.517037", "13.397634,52.529407", true))
+}
+
+func TestWarmUpRejectsInvalidCoordinates(t *testing.T) {
+	assert.NotNil(t, warmUp(configFromEnv(), "invalid", "13.397634,52.529407"))
+}
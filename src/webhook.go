@@ -0,0 +1,137 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/go-playground/validator/v10"
+)
+
+const maxWebhookAttempts = 10
+
+var (
+	// Attempts to deliver a job's completion webhook, capped at maxWebhookAttempts
+	webhookAttempts = 3
+	webhookBackoff  = 1 * time.Second
+
+	// Hosts callback URLs may point to. Unset, any host is allowed as long as it doesn't resolve to a
+	// private, loopback or link-local address, so callbacks can't be used to reach internal services.
+	webhookAllowedHosts []string
+
+	// Webhooks have their own client, it refuses to connect to internal addresses and doesn't follow
+	// redirects, which could lead anywhere
+	webhookClient = &http.Client{
+		Timeout: 10 * time.Second,
+		Transport: &http.Transport{
+			DialContext: (&net.Dialer{Timeout: 5 * time.Second, Control: controlWebhookDial}).DialContext,
+		},
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+)
+
+// validateCallbackURL accepts http and https URLs to an allowed host. Without an allowlist, hosts
+// that are internal addresses or localhost are refused, names resolving to one are refused on dialing.
+func validateCallbackURL(fl validator.FieldLevel) bool {
+	u, err := url.Parse(fl.Field().String())
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Hostname() == "" {
+		return false
+	}
+
+	host := strings.ToLower(u.Hostname())
+	if len(webhookAllowedHosts) > 0 {
+		for _, allowed := range webhookAllowedHosts {
+			if host == strings.ToLower(allowed) {
+				return true
+			}
+		}
+		return false
+	}
+
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return false
+	}
+	if ip := net.ParseIP(host); ip != nil {
+		return isPublicAddress(ip)
+	}
+
+	return true
+}
+
+// controlWebhookDial refuses connections to internal addresses unless the hosts are allowlisted
+func controlWebhookDial(network string, address string, _ syscall.RawConn) error {
+	if len(webhookAllowedHosts) > 0 {
+		return nil
+	}
+
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	if ip := net.ParseIP(host); ip == nil || !isPublicAddress(ip) {
+		return errors.New("callback address " + host + " is not public")
+	}
+
+	return nil
+}
+
+func isPublicAddress(ip net.IP) bool {
+	return !ip.IsLoopback() && !ip.IsPrivate() && !ip.IsUnspecified() && !ip.IsLinkLocalUnicast() &&
+		!ip.IsLinkLocalMulticast() && !ip.IsInterfaceLocalMulticast() && !ip.IsMulticast()
+}
+
+// notifyWebhook POSTs the finished job to the callback URL, retrying with a doubling backoff
+// until the receiver answers with a 2xx status or the attempts run out
+func notifyWebhook(callbackURL string, job Job) error {
+	body, err := json.Marshal(job)
+	if err != nil {
+		return err
+	}
+
+	attempts := webhookAttempts
+	if attempts > maxWebhookAttempts {
+		attempts = maxWebhookAttempts
+	}
+	backoff := webhookBackoff
+
+	for i := 0; i < attempts; i++ {
+		if i > 0 {
+			time.Sleep(backoff)
+			backoff *= 2
+		}
+
+		var resp *http.Response
+		resp, err = webhookClient.Post(callbackURL, "application/json", bytes.NewReader(body))
+		if err != nil {
+			continue
+		}
+		resp.Body.Close()
+
+		if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+			return nil
+		}
+		err = fmt.Errorf("response code: %d", resp.StatusCode)
+	}
+
+	return fmt.Errorf("webhook failed after %d attempts: %w", attempts, err)
+}
+
+func notifyJobWebhook(callbackURL string, job Job) {
+	if callbackURL == "" {
+		return
+	}
+
+	if err := notifyWebhook(callbackURL, job); err != nil {
+		log.Printf("could not notify %s about job %s: %s", callbackURL, job.ID, err)
+	}
+}
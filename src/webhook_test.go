@@ -0,0 +1,129 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNotifyWebhookRetriesUntilDelivered(t *testing.T) {
+	webhookBackoff = time.Millisecond
+	webhookAllowedHosts = []string{"127.0.0.1"}
+	defer func() {
+		webhookBackoff = time.Second
+		webhookAllowedHosts = nil
+	}()
+
+	var calls int32
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer receiver.Close()
+
+	assert.Nil(t, notifyWebhook(receiver.URL, Job{ID: "1", Status: jobDone}))
+	assert.Equal(t, int32(3), atomic.LoadInt32(&calls))
+}
+
+func TestNotifyWebhookGivesUpAfterMaxAttempts(t *testing.T) {
+	webhookBackoff = time.Millisecond
+	webhookAttempts = 100
+	webhookAllowedHosts = []string{"127.0.0.1"}
+	defer func() {
+		webhookBackoff = time.Second
+		webhookAttempts = 3
+		webhookAllowedHosts = nil
+	}()
+
+	var calls int32
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer receiver.Close()
+
+	assert.NotNil(t, notifyWebhook(receiver.URL, Job{ID: "1", Status: jobDone}))
+	assert.Equal(t, int32(maxWebhookAttempts), atomic.LoadInt32(&calls))
+}
+
+func TestJobNotifiesWebhookOnCompletion(t *testing.T) {
+	mockOsrmApi := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(`{"code":"Ok", "routes": [{"duration":2490.1,"distance":3286.3}]}`))
+	}))
+	defer mockOsrmApi.Close()
+
+	router = setupRouter(Config{OsrmBaseURL: mockOsrmApi.URL})
+	webhookAllowedHosts = []string{"127.0.0.1"}
+	defer func() { webhookAllowedHosts = nil }()
+
+	received := make(chan Job, 1)
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var job Job
+		json.NewDecoder(r.Body).Decode(&job)
+		received <- job
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer receiver.Close()
+
+	body := fmt.Sprintf(`{"src":"13.388860,52.517037","dst":["13.397634,52.529407"],"callbackUrl":"%s/hook"}`, receiver.URL)
+	rec := mockJobRequest(http.MethodPost, "/routes/jobs", body)
+	assert.Equal(t, http.StatusAccepted, rec.Code)
+
+	select {
+	case job := <-received:
+		assert.Equal(t, jobDone, job.Status)
+		assert.Equal(t, 1, job.Completed)
+		assert.Len(t, job.Result.Routes, 1)
+	case <-time.After(5 * time.Second):
+		t.Fatal("webhook was not called")
+	}
+}
+
+func TestCreateJobReturns400WhenCallbackURLIsInvalid(t *testing.T) {
+	rec := mockJobRequest(http.MethodPost, "/routes/jobs", `{"src":"13.388860,52.517037","dst":["13.397634,52.529407"],"callbackUrl":"ftp://example.com"}`)
+
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Equal(t, `{"code":400,"message":"CallbackURL is not valid","error_code":"invalid_parameter"}`, rec.Body.String())
+}
+
+func TestCreateJobReturns400WhenCallbackURLIsInternal(t *testing.T) {
+	for _, callbackURL := range []string{"http://localhost/hook", "http://127.0.0.1:8080/hook", "http://10.1.2.3/hook", "http://169.254.169.254/latest", "http://[::1]/hook"} {
+		rec := mockJobRequest(http.MethodPost, "/routes/jobs", fmt.Sprintf(`{"src":"13.388860,52.517037","dst":["13.397634,52.529407"],"callbackUrl":"%s"}`, callbackURL))
+
+		assert.Equal(t, http.StatusBadRequest, rec.Code, callbackURL)
+		assert.Equal(t, `{"code":400,"message":"CallbackURL is not valid","error_code":"invalid_parameter"}`, rec.Body.String())
+	}
+}
+
+func TestCreateJobOnlyAcceptsAllowedCallbackHosts(t *testing.T) {
+	webhookAllowedHosts = []string{"hooks.example.com"}
+	defer func() { webhookAllowedHosts = nil }()
+
+	rec := mockJobRequest(http.MethodPost, "/routes/jobs", `{"src":"13.388860,52.517037","dst":["13.397634,52.529407"],"callbackUrl":"https://example.com/hook"}`)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+}
+
+func TestNotifyWebhookRefusesInternalAddresses(t *testing.T) {
+	webhookBackoff = time.Millisecond
+	defer func() { webhookBackoff = time.Second }()
+
+	var calls int32
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+	}))
+	defer receiver.Close()
+
+	// Stands in for a public name that resolves to an internal address
+	assert.NotNil(t, notifyWebhook(receiver.URL, Job{ID: "1", Status: jobDone}))
+	assert.Equal(t, int32(0), atomic.LoadInt32(&calls))
+}